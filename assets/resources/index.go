@@ -13,6 +13,9 @@ import (
 const (
 	AppID      = "com.pojtinger.felicitas.Multiplex"
 	AppVersion = "0.1.13"
+
+	// Name of the directory in the user's data directory which the history, bookmarks, delays and downloads are stored in
+	DataDirName = "multiplex"
 )
 
 //go:generate sh -c "blueprint-compiler batch-compile . . *.blp && glib-compile-resources *.gresource.xml"
@@ -32,6 +35,7 @@ var (
 	ResourceMenuPath            = path.Join(AppPath, "menu.ui")
 	ResourcePreferencesPath     = path.Join(AppPath, "preferences.ui")
 	ResourcePreparingPath       = path.Join(AppPath, "preparing.ui")
+	ResourceDownloadsPath       = path.Join(AppPath, "downloads.ui")
 	ResourceSubtitlesPath       = path.Join(AppPath, "subtitles.ui")
 	ResourceAudiotracksPath     = path.Join(AppPath, "audiotracks.ui")
 	ResourceErrorPath           = path.Join(AppPath, "error.ui")
@@ -49,6 +53,12 @@ const (
 	SchemaStorageKey = "storage"
 	SchemaMPVKey     = "mpv"
 
+	SchemaAudioLanguagesKey    = "audiolanguages"
+	SchemaSubtitleLanguagesKey = "subtitlelanguages"
+
+	SchemaSubtitleProviderURLKey = "subtitleproviderurl"
+	SchemaSubtitleProviderKeyKey = "subtitleproviderkey"
+
 	SchemaGatewayRemoteKey   = "gatewayremote"
 	SchemaGatewayURLKey      = "gatewayurl"
 	SchemaGatewayUsernameKey = "gatewayusername"
@@ -58,4 +68,14 @@ const (
 	SchemaWeronTimeoutKey    = "werontimeout"
 	SchemaWeronICEKey        = "weronice"
 	SchemaWeronForceRelayKey = "weronforcerelay"
+
+	SchemaShortcutsKey      = "shortcuts"
+	SchemaShareBookmarksKey = "sharebookmarks"
+
+	SchemaShareSubtitleDelayKey = "sharesubtitledelay"
+
+	SchemaRemoteControlKey      = "remotecontrol"
+	SchemaRemoteControlLANKey   = "remotecontrollan"
+	SchemaRemoteControlPortKey  = "remotecontrolport"
+	SchemaRemoteControlTokenKey = "remotecontroltoken"
 )
@@ -0,0 +1,422 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"codeberg.org/puregotk/puregotk/v4/gio"
+	v1 "github.com/pojntfx/htorrent/pkg/api/http/v1"
+	"github.com/pojntfx/htorrent/pkg/client"
+	"github.com/pojntfx/multiplex/assets/resources"
+	"github.com/pojntfx/multiplex/internal/session"
+	"github.com/pojntfx/multiplex/internal/utils"
+	api "github.com/pojntfx/multiplex/pkg/api/webrtc/v1"
+	mpvClient "github.com/pojntfx/multiplex/pkg/client"
+	"github.com/pojntfx/multiplex/pkg/links"
+	"github.com/pojntfx/multiplex/pkg/sources"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	headlessMonitorInterval  = time.Millisecond * 200
+	headlessProgressInterval = time.Second * 5
+)
+
+var (
+	errMediaNotInTorrent = errors.New("media is not part of this torrent")
+	errHeadlessLocalFile = errors.New("sessions of local files can't be joined in headless mode")
+)
+
+// headlessMedia is the media of a headless session
+type headlessMedia struct {
+	source  sources.Source
+	message *api.Magnet
+}
+
+// runHeadless hosts a session for a magnet link or joins the session of a stream code without opening any
+// windows, and keeps mpv in sync with the other peers until mpv exits or `ctx` is cancelled
+func runHeadless(ctx context.Context, settings *gio.Settings, opts options, manager *client.Manager, apiAddr, apiUsername, apiPassword string) error {
+	mpvCommand := settings.GetString(resources.SchemaMPVKey)
+	if strings.TrimSpace(mpvCommand) == "" {
+		info, err := mpvClient.DiscoverMPVExecutable()
+		if err != nil {
+			return err
+		}
+
+		mpvCommand = info.Command
+	} else if _, err := mpvClient.ProbeMPVExecutable(mpvCommand); err != nil {
+		return err
+	}
+
+	s, err := session.NewSession(session.NewConfig(settings), opts.join)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	var media *headlessMedia
+	if opts.join != "" {
+		media, err = joinHeadlessSession(ctx, s, opts.join)
+	} else {
+		media, err = hostHeadlessSession(ctx, s, manager, opts.magnet, opts.path)
+	}
+	if err != nil {
+		return err
+	}
+
+	ipcDir, err := os.MkdirTemp(os.TempDir(), "mpv-ipc")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(ipcDir)
+
+	ipcFile := filepath.Join(ipcDir, "mpv.sock")
+	configFile := filepath.Join(ipcDir, "mpv.conf")
+
+	// The gateway's credentials are only sent to the gateway, never to the servers of other sources
+	authorization := ""
+	if media.source.Type == sources.TypeTorrent {
+		authorization = "Basic " + base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%v:%v", apiUsername, apiPassword)))
+	}
+
+	if err := mpvClient.WriteMPVConfig(configFile, authorization); err != nil {
+		return err
+	}
+
+	streamURL, err := media.source.StreamURL(apiAddr, media.message.Path)
+	if err != nil {
+		return err
+	}
+
+	command, err := mpvClient.NewMPVCommand(mpvCommand, ipcFile, configFile, streamURL)
+	if err != nil {
+		return err
+	}
+	utils.AddSysProcAttr(command)
+
+	command.Stdout = os.Stdout
+	command.Stderr = os.Stderr
+
+	log.Info().
+		Str("command", mpvCommand).
+		Str("path", media.message.Path).
+		Msg("Starting mpv")
+
+	if err := command.Start(); err != nil {
+		return err
+	}
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- command.Wait()
+	}()
+
+	stopMPV := func() {
+		if err := utils.Kill(command.Process); err != nil {
+			log.Warn().
+				Err(err).
+				Msg("Could not stop mpv")
+		}
+
+		<-exited
+	}
+
+	if err := mpvClient.WaitForMPVIPC(ctx, ipcFile, exited); err != nil {
+		if errors.Is(err, mpvClient.ErrMPVQuit) {
+			return nil
+		}
+
+		if errors.Is(err, mpvClient.ErrMPVExitedBeforeReady) {
+			return err
+		}
+
+		stopMPV()
+
+		if errors.Is(err, context.Canceled) {
+			return nil
+		}
+
+		return err
+	}
+
+	// Whether playback should be running; if mpv is idle while it should be playing, it is buffering
+	playing := &atomic.Bool{}
+	connectedPeers := &atomic.Int32{}
+
+	setPaused := func(pause bool) {
+		playing.Store(!pause)
+
+		if pause {
+			log.Info().Msg("Pausing playback")
+		} else {
+			log.Info().Msg("Starting playback")
+		}
+
+		if err := mpvClient.SetMPVProperty(ipcFile, "pause", pause); err != nil {
+			log.Warn().
+				Err(err).
+				Msg("Could not set playback state")
+		}
+	}
+
+	getPosition := func() float64 {
+		var elapsed float64
+		if err := mpvClient.GetMPVProperty(ipcFile, "time-pos", &elapsed); err != nil {
+			log.Debug().
+				Err(err).
+				Msg("Could not get position")
+
+			return 0
+		}
+
+		return float64(time.Duration(elapsed * float64(time.Second)).Nanoseconds())
+	}
+
+	served := make(chan error, 1)
+	go func() {
+		served <- s.Serve(ctx, media.message, session.Callbacks{
+			OnPeer: func(connected bool) {
+				var peers int32
+				if connected {
+					peers = connectedPeers.Add(1)
+				} else {
+					peers = connectedPeers.Add(-1)
+				}
+
+				log.Info().
+					Bool("joined", connected).
+					Int32("peers", peers).
+					Msg("Peers changed")
+			},
+			OnPause: setPaused,
+			OnPosition: func(position float64) {
+				log.Info().
+					Dur("duration", time.Duration(position)).
+					Msg("Seeking")
+
+				if err := mpvClient.ExecuteMPVCommand(ipcFile, nil, "seek", time.Duration(position).Seconds(), "absolute"); err != nil {
+					log.Warn().
+						Err(err).
+						Msg("Could not seek")
+				}
+			},
+			OnBuffering: func(buffering bool) {
+				log.Info().
+					Bool("buffering", buffering).
+					Msg("Peer is buffering")
+
+				setPaused(buffering)
+
+				// Like in the controls window, playback should continue once the peer has finished buffering
+				playing.Store(true)
+			},
+			GetPosition: getPosition,
+		})
+	}()
+
+	monitor := time.NewTicker(headlessMonitorInterval)
+	defer monitor.Stop()
+
+	progress := time.NewTicker(headlessProgressInterval)
+	defer progress.Stop()
+
+	started := opts.join != ""
+	previouslyBuffered := false
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info().Msg("Stopping headless session")
+
+			stopMPV()
+
+			return nil
+
+		case err := <-served:
+			stopMPV()
+
+			return err
+
+		case err := <-exited:
+			if err != nil {
+				return err
+			}
+
+			log.Info().Msg("mpv exited, stopping headless session")
+
+			return nil
+
+		case <-monitor.C:
+			var (
+				duration float64
+				idle     bool
+			)
+			if err := mpvClient.GetMPVProperty(ipcFile, "duration", &duration); err != nil {
+				log.Trace().
+					Err(err).
+					Msg("Could not get duration")
+
+				continue
+			}
+
+			// Hosts start playback as soon as the media has loaded, peers follow the host
+			if !started && duration > 0 {
+				started = true
+
+				s.Pauses.Broadcast(false)
+				setPaused(false)
+			}
+
+			if err := mpvClient.GetMPVProperty(ipcFile, "core-idle", &idle); err != nil {
+				log.Trace().
+					Err(err).
+					Msg("Could not get playback state")
+
+				continue
+			}
+
+			if buffered := idle && playing.Load(); buffered != previouslyBuffered {
+				previouslyBuffered = buffered
+
+				log.Info().
+					Bool("buffering", buffered).
+					Msg("Buffering changed")
+
+				s.Buffering.Broadcast(buffered)
+				s.Pauses.Broadcast(buffered)
+				s.Positions.Broadcast(getPosition())
+			}
+
+		case <-progress.C:
+			logHeadlessProgress(manager, media, ipcFile, connectedPeers.Load(), playing.Load())
+		}
+	}
+}
+
+// hostHeadlessSession starts a new session for media in a torrent
+func hostHeadlessSession(ctx context.Context, s *session.Session, manager *client.Manager, magnet, path string) (*headlessMedia, error) {
+	log.Info().
+		Str("magnetLink", magnet).
+		Msg("Getting info for magnet link")
+
+	info, err := manager.GetInfo(magnet)
+	if err != nil {
+		return nil, err
+	}
+
+	if !slices.ContainsFunc(info.Files, func(f v1.File) bool {
+		return f.Path == path
+	}) {
+		return nil, fmt.Errorf("%w: %v", errMediaNotInTorrent, path)
+	}
+
+	if err := s.Open(ctx); err != nil {
+		return nil, err
+	}
+
+	source := sources.NewTorrentSource(magnet)
+
+	log.Info().
+		Str("streamCode", s.StreamCode()).
+		Str("inviteLink", links.NewJoinLink(s.StreamCode())).
+		Str("title", info.Name).
+		Str("path", path).
+		Msg("Hosting session")
+
+	return &headlessMedia{
+		source:  source,
+		message: source.Message(path, info.Name, info.Description, []api.Subtitle{}),
+	}, nil
+}
+
+// joinHeadlessSession waits for the first peer of a session and verifies the media which it is playing
+func joinHeadlessSession(ctx context.Context, s *session.Session, streamCode string) (*headlessMedia, error) {
+	if err := s.Open(ctx); err != nil {
+		return nil, err
+	}
+
+	log.Info().
+		Str("streamCode", streamCode).
+		Msg("Joining session for stream code, waiting for peers")
+
+	m, err := s.Join()
+	if err != nil {
+		return nil, err
+	}
+
+	source, err := sources.FromMessage(*m)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every peer selects their own copy of a local file, which requires a file chooser
+	if source.Type == sources.TypeFile {
+		return nil, errHeadlessLocalFile
+	}
+
+	if err := source.Verify(ctx, mpvClient.NewGatewayClient("", "", nil)); err != nil {
+		return nil, err
+	}
+
+	log.Info().
+		Str("title", m.Title).
+		Str("path", m.Path).
+		Msg("Joined session")
+
+	return &headlessMedia{
+		source:  source,
+		message: m,
+	}, nil
+}
+
+func logHeadlessProgress(manager *client.Manager, media *headlessMedia, ipcFile string, peers int32, playing bool) {
+	var elapsed, duration float64
+	if err := mpvClient.GetMPVProperty(ipcFile, "time-pos", &elapsed); err != nil {
+		log.Trace().
+			Err(err).
+			Msg("Could not get position")
+	}
+
+	if err := mpvClient.GetMPVProperty(ipcFile, "duration", &duration); err != nil {
+		log.Trace().
+			Err(err).
+			Msg("Could not get duration")
+	}
+
+	event := log.Info().
+		Dur("elapsed", time.Duration(elapsed*float64(time.Second))).
+		Dur("duration", time.Duration(duration*float64(time.Second))).
+		Bool("playing", playing).
+		Int32("peers", peers)
+
+	// Only torrents are fetched through the gateway, so there is no download progress for other sources
+	if media.source.Type == sources.TypeTorrent {
+		if mediaID, err := media.source.ID(); err == nil {
+			if metrics, err := manager.GetMetrics(); err == nil {
+				for _, t := range metrics {
+					if t.InfoHash != mediaID {
+						continue
+					}
+
+					for _, f := range t.Files {
+						if f.Path == media.message.Path {
+							event = event.
+								Int64("length", f.Length).
+								Int64("completed", f.Completed).
+								Int("seeders", t.Peers)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	event.Msg("Playing")
+}
@@ -0,0 +1,281 @@
+package automation
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+	"github.com/pojntfx/multiplex/internal/session"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	BusName    = "com.pojtinger.felicitas.Multiplex.Session"
+	ObjectPath = dbus.ObjectPath("/com/pojtinger/felicitas/Multiplex/Session")
+	Interface  = "com.pojtinger.felicitas.Multiplex.Session"
+
+	SignalSessionStarted = "SessionStarted" // SignalSessionStarted is emitted with the stream code of a session once it is being served
+	SignalSessionEnded   = "SessionEnded"   // SignalSessionEnded is emitted with the stream code of a session once it has been closed
+	SignalPeerJoined     = "PeerJoined"     // SignalPeerJoined is emitted with the stream code and the ID of a peer which connected
+	SignalPeerLeft       = "PeerLeft"       // SignalPeerLeft is emitted with the stream code and the ID of a peer which disconnected
+	SignalSynced         = "Synced"         // SignalSynced is emitted with the stream code, peer ID, type and value of a synced message
+
+	errorInvalidArgs = Interface + ".Error.InvalidArgs"
+)
+
+var (
+	ErrNameTaken = errors.New("could not acquire automation bus name")
+)
+
+// Handlers start and join sessions like the main window does; handlers which are nil are skipped
+type Handlers struct {
+	StartSession func(magnet, path string, streamOnly bool) error
+	JoinSession  func(streamCode string, streamOnly bool) error
+}
+
+// Server exposes the sessions of the app through a D-Bus interface, so that they can be automated
+type Server struct {
+	conn     *dbus.Conn
+	handlers Handlers
+	props    *prop.Properties
+
+	lock     sync.Mutex
+	sessions []*session.Session // Open sessions, the last one is the current session
+}
+
+// NewServer creates an automation server on a D-Bus connection, e.g. the session bus or a private bus for testing
+func NewServer(conn *dbus.Conn, handlers Handlers) *Server {
+	return &Server{
+		conn:     conn,
+		handlers: handlers,
+	}
+}
+
+// Open exports the automation interface and acquires its bus name
+func (s *Server) Open() error {
+	var err error
+	s.props, err = prop.Export(s.conn, ObjectPath, prop.Map{
+		Interface: {
+			"StreamCode":   {Value: "", Emit: prop.EmitTrue},
+			"Participants": {Value: []string{}, Emit: prop.EmitTrue},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := s.conn.Export(automation{s}, ObjectPath, Interface); err != nil {
+		return err
+	}
+
+	if err := s.conn.Export(introspect.NewIntrospectable(&introspect.Node{
+		Name: string(ObjectPath),
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{
+				Name:       Interface,
+				Methods:    introspect.Methods(automation{s}),
+				Properties: s.props.Introspection(Interface),
+				Signals: []introspect.Signal{
+					{
+						Name: SignalSessionStarted,
+						Args: []introspect.Arg{{Name: "StreamCode", Type: "s"}},
+					},
+					{
+						Name: SignalSessionEnded,
+						Args: []introspect.Arg{{Name: "StreamCode", Type: "s"}},
+					},
+					{
+						Name: SignalPeerJoined,
+						Args: []introspect.Arg{{Name: "StreamCode", Type: "s"}, {Name: "PeerID", Type: "s"}},
+					},
+					{
+						Name: SignalPeerLeft,
+						Args: []introspect.Arg{{Name: "StreamCode", Type: "s"}, {Name: "PeerID", Type: "s"}},
+					},
+					{
+						Name: SignalSynced,
+						Args: []introspect.Arg{{Name: "StreamCode", Type: "s"}, {Name: "PeerID", Type: "s"}, {Name: "Type", Type: "s"}, {Name: "Value", Type: "v"}},
+					},
+				},
+			},
+		},
+	}), ObjectPath, "org.freedesktop.DBus.Introspectable"); err != nil {
+		return err
+	}
+
+	reply, err := s.conn.RequestName(BusName, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		return err
+	}
+
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		return ErrNameTaken
+	}
+
+	return nil
+}
+
+// Close releases the bus name and stops exporting the automation interface
+func (s *Server) Close() error {
+	if _, err := s.conn.ReleaseName(BusName); err != nil {
+		return err
+	}
+
+	for _, iface := range []string{Interface, "org.freedesktop.DBus.Properties", "org.freedesktop.DBus.Introspectable"} {
+		if err := s.conn.Export(nil, ObjectPath, iface); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AddSession reports the events of a session which is being served until it is closed. It is safe to call
+// on a nil server, which is what windows have if there is no session bus.
+func (s *Server) AddSession(sess *session.Session) {
+	if s == nil {
+		return
+	}
+
+	events := sess.Events()
+	if events == nil {
+		return
+	}
+
+	s.lock.Lock()
+	s.sessions = append(s.sessions, sess)
+	s.lock.Unlock()
+
+	streamCode := sess.StreamCode()
+
+	s.refresh()
+	s.emit(SignalSessionStarted, streamCode)
+
+	go func() {
+		defer func() {
+			s.lock.Lock()
+			if i := slices.Index(s.sessions, sess); i >= 0 {
+				s.sessions = slices.Delete(s.sessions, i, i+1)
+			}
+			s.lock.Unlock()
+
+			s.refresh()
+			s.emit(SignalSessionEnded, streamCode)
+		}()
+
+		for event := range events.Ch() {
+			switch event.Type {
+			case session.EventPeerJoined:
+				s.refresh()
+				s.emit(SignalPeerJoined, streamCode, event.PeerID)
+
+			case session.EventPeerLeft:
+				s.refresh()
+				s.emit(SignalPeerLeft, streamCode, event.PeerID)
+
+			default:
+				value, err := syncedValue(event.Value)
+				if err != nil {
+					log.Debug().
+						Err(err).
+						Str("type", event.Type).
+						Msg("Could not encode synced value, skipping")
+
+					continue
+				}
+
+				s.emit(SignalSynced, streamCode, event.PeerID, event.Type, value)
+			}
+		}
+	}()
+}
+
+// refresh updates the properties from the current session
+func (s *Server) refresh() {
+	if s.props == nil {
+		return
+	}
+
+	s.lock.Lock()
+	streamCode := ""
+	participants := []string{}
+	if len(s.sessions) > 0 {
+		current := s.sessions[len(s.sessions)-1]
+
+		streamCode = current.StreamCode()
+		participants = current.Peers()
+	}
+	s.lock.Unlock()
+
+	if s.props.GetMust(Interface, "StreamCode") != streamCode {
+		s.props.SetMust(Interface, "StreamCode", streamCode)
+	}
+
+	if !slices.Equal(s.props.GetMust(Interface, "Participants").([]string), participants) {
+		s.props.SetMust(Interface, "Participants", participants)
+	}
+}
+
+func (s *Server) emit(signal string, args ...interface{}) {
+	if err := s.conn.Emit(ObjectPath, Interface+"."+signal, args...); err != nil {
+		log.Debug().
+			Err(err).
+			Str("signal", signal).
+			Msg("Could not emit signal")
+	}
+}
+
+// syncedValue converts the value of a synced message to a variant; positions are sent as nanoseconds and
+// messages such as bookmarks as JSON
+func syncedValue(value interface{}) (dbus.Variant, error) {
+	switch v := value.(type) {
+	case bool:
+		return dbus.MakeVariant(v), nil
+	case float64:
+		return dbus.MakeVariant(int64(v)), nil
+	default:
+		j, err := json.Marshal(v)
+		if err != nil {
+			return dbus.Variant{}, err
+		}
+
+		return dbus.MakeVariant(string(j)), nil
+	}
+}
+
+// automation implements the `com.pojtinger.felicitas.Multiplex.Session` interface
+type automation struct {
+	s *Server
+}
+
+// StartSession starts a new session for media in a torrent
+func (a automation) StartSession(magnet, path string, streamOnly bool) *dbus.Error {
+	if a.s.handlers.StartSession == nil {
+		return nil
+	}
+
+	if err := a.s.handlers.StartSession(magnet, path, streamOnly); err != nil {
+		return dbus.NewError(errorInvalidArgs, []interface{}{err.Error()})
+	}
+
+	return nil
+}
+
+// JoinSession joins the session of a stream code
+func (a automation) JoinSession(streamCode string, streamOnly bool) *dbus.Error {
+	if a.s.handlers.JoinSession == nil {
+		return nil
+	}
+
+	if err := a.s.handlers.JoinSession(streamCode, streamOnly); err != nil {
+		return dbus.NewError(errorInvalidArgs, []interface{}{err.Error()})
+	}
+
+	return nil
+}
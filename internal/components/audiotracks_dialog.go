@@ -22,9 +22,11 @@ type AudioTracksDialog struct {
 	cancelButton   *gtk.Button
 	okButton       *gtk.Button
 	selectionGroup *adw.PreferencesGroup
+	delayInput     *adw.SpinRow
 
 	cancelCallback func()
 	okCallback     func()
+	delayCallback  func(float64)
 }
 
 func NewAudioTracksDialog(transientFor *adw.ApplicationWindow) AudioTracksDialog {
@@ -54,6 +56,17 @@ func (a *AudioTracksDialog) SetOKCallback(callback func()) {
 	audioD.okCallback = callback
 }
 
+// SetDelay sets the audio delay in seconds; the delay callback is called if the value changes
+func (a *AudioTracksDialog) SetDelay(delay float64) {
+	audioD := (*AudioTracksDialog)(unsafe.Pointer(a.Widget.GetData(dataKeyGoInstance)))
+	audioD.delayInput.SetValue(delay)
+}
+
+func (a *AudioTracksDialog) SetDelayCallback(callback func(float64)) {
+	audioD := (*AudioTracksDialog)(unsafe.Pointer(a.Widget.GetData(dataKeyGoInstance)))
+	audioD.delayCallback = callback
+}
+
 func init() {
 	var classInit gobject.ClassInitFunc = func(tc *gobject.TypeClass, u uintptr) {
 		typeClass := (*gtk.WidgetClass)(unsafe.Pointer(tc))
@@ -62,6 +75,7 @@ func init() {
 		typeClass.BindTemplateChildFull("button_cancel", false, 0)
 		typeClass.BindTemplateChildFull("button_ok", false, 0)
 		typeClass.BindTemplateChildFull("audiotracks", false, 0)
+		typeClass.BindTemplateChildFull("delay_input", false, 0)
 
 		objClass := (*gobject.ObjectClass)(unsafe.Pointer(tc))
 
@@ -78,16 +92,19 @@ func init() {
 				cancelButton   gtk.Button
 				okButton       gtk.Button
 				selectionGroup adw.PreferencesGroup
+				delayInput     adw.SpinRow
 			)
 			parent.Widget.GetTemplateChild(gTypeAudioTracksDialog, "button_cancel").Cast(&cancelButton)
 			parent.Widget.GetTemplateChild(gTypeAudioTracksDialog, "button_ok").Cast(&okButton)
 			parent.Widget.GetTemplateChild(gTypeAudioTracksDialog, "audiotracks").Cast(&selectionGroup)
+			parent.Widget.GetTemplateChild(gTypeAudioTracksDialog, "delay_input").Cast(&delayInput)
 
 			a := &AudioTracksDialog{
 				Window:         parent,
 				cancelButton:   &cancelButton,
 				okButton:       &okButton,
 				selectionGroup: &selectionGroup,
+				delayInput:     &delayInput,
 			}
 
 			ctrl := gtk.NewEventControllerKey()
@@ -122,6 +139,13 @@ func init() {
 			}
 			okButton.ConnectClicked(&onOKClicked)
 
+			onDelayChanged := func(gtk.Adjustment) {
+				if a.delayCallback != nil {
+					a.delayCallback(delayInput.GetValue())
+				}
+			}
+			delayInput.GetAdjustment().ConnectValueChanged(&onDelayChanged)
+
 			var pinner runtime.Pinner
 			pinner.Pin(a)
 
@@ -7,15 +7,18 @@ import (
 	"errors"
 	"fmt"
 	"math"
-	"net"
-	"net/http"
 	"net/url"
 	"os"
 	"os/exec"
 	"os/signal"
+	"path"
 	"path/filepath"
 	"runtime"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"syscall"
 	"time"
@@ -30,19 +33,25 @@ import (
 	"codeberg.org/puregotk/puregotk/v4/glib"
 	"codeberg.org/puregotk/puregotk/v4/gobject"
 	"codeberg.org/puregotk/puregotk/v4/gtk"
-	"github.com/anacrolix/torrent"
-	"github.com/mitchellh/mapstructure"
+	"github.com/godbus/dbus/v5"
 	"github.com/pojntfx/htorrent/pkg/client"
 	"github.com/pojntfx/htorrent/pkg/server"
 	"github.com/pojntfx/multiplex/assets/resources"
+	"github.com/pojntfx/multiplex/internal/automation"
+	"github.com/pojntfx/multiplex/internal/downloads"
+	"github.com/pojntfx/multiplex/internal/mpris"
+	"github.com/pojntfx/multiplex/internal/remote"
+	"github.com/pojntfx/multiplex/internal/session"
+	"github.com/pojntfx/multiplex/internal/store"
 	"github.com/pojntfx/multiplex/internal/utils"
 	mpv "github.com/pojntfx/multiplex/pkg/api/sockets/v1"
 	api "github.com/pojntfx/multiplex/pkg/api/webrtc/v1"
 	mpvClient "github.com/pojntfx/multiplex/pkg/client"
-	"github.com/pojntfx/weron/pkg/wrtcconn"
+	"github.com/pojntfx/multiplex/pkg/links"
+	"github.com/pojntfx/multiplex/pkg/sources"
+	"github.com/pojntfx/multiplex/pkg/subtitles"
 	"github.com/rs/zerolog/log"
 	"github.com/teivah/broadcast"
-	"github.com/teris-io/shortid"
 )
 
 const (
@@ -50,6 +59,25 @@ const (
 	pauseIcon = "media-playback-pause-symbolic"
 
 	keycodeEscape = 66
+
+	seekStep   = time.Second * 10
+	volumeStep = 0.1
+
+	maxMPVRestarts       = 3
+	mpvRestartResetAfter = time.Second * 30
+
+	// If playback is further into the current chapter than this, jumping back restarts the chapter
+	chapterRestartThreshold = time.Second * 3
+
+	// Captures are stored in this directory in the storage location; it isn't translated so that it doesn't move when the language changes
+	capturesDirName = "Captures"
+
+	// The progress is saved periodically so that it isn't lost if Multiplex crashes
+	historySaveInterval = time.Second * 5
+
+	// Media is only offered to be continued if it has been watched for a while, but not almost until the end
+	resumeThreshold = time.Second * 30
+	resumeEndMargin = time.Second * 60
 )
 
 var (
@@ -67,6 +95,7 @@ type mediaWithPriorityAndID struct {
 	media
 	priority int
 	id       int
+	lang     string
 }
 
 type audioTrack struct {
@@ -74,6 +103,160 @@ type audioTrack struct {
 	id   int
 }
 
+// MediaDelays are the subtitle and audio delays (in seconds) to reapply when playing a file again
+type MediaDelays struct {
+	Subtitle float64 `json:"subtitle"`
+	Audio    float64 `json:"audio"`
+}
+
+// Bookmark is a named position in a media file
+type Bookmark struct {
+	Name     string  `json:"name"`
+	Position float64 `json:"position"` // Position in seconds
+}
+
+// WatchedMedia is the last position (in seconds) of a media file, used to continue watching it later
+type WatchedMedia struct {
+	Type      sources.Type `json:"type"`
+	Link      string       `json:"link"` // Magnet link, URL or local path to open the media again with
+	Title     string       `json:"title"`
+	Path      string       `json:"path"` // Path of the file in the torrent, or the name of the media
+	Position  float64      `json:"position"`
+	Duration  float64      `json:"duration"`
+	WatchedAt time.Time    `json:"watchedAt"`
+}
+
+func (m WatchedMedia) position() time.Duration {
+	return time.Duration(m.Position * float64(time.Second))
+}
+
+func (m WatchedMedia) duration() time.Duration {
+	return time.Duration(m.Duration * float64(time.Second))
+}
+
+// resumable returns whether the media was stopped somewhere in the middle
+func (m WatchedMedia) resumable() bool {
+	return m.position() > resumeThreshold && m.position() < m.duration()-resumeEndMargin
+}
+
+// NewHistoryStore creates the store for the watch history, which is shared by all windows
+func NewHistoryStore() *store.JSONStore[WatchedMedia] {
+	return store.NewJSONStore[WatchedMedia](filepath.Join(glib.GetUserDataDir(), resources.DataDirName, "history.json"))
+}
+
+// NewBookmarksStore creates the store for the bookmarks of each media file, which is shared by all windows
+func NewBookmarksStore() *store.JSONStore[[]Bookmark] {
+	return store.NewJSONStore[[]Bookmark](filepath.Join(glib.GetUserDataDir(), resources.DataDirName, "bookmarks.json"))
+}
+
+// NewDelaysStore creates the store for the delays of each media file, which is shared by all windows
+func NewDelaysStore() *store.JSONStore[MediaDelays] {
+	return store.NewJSONStore[MediaDelays](filepath.Join(glib.GetUserDataDir(), resources.DataDirName, "delays.json"))
+}
+
+// seekerMarks are the positions (in nanoseconds) which are marked on the seeker
+type seekerMarks struct {
+	sync.Mutex
+
+	chapters  []float64
+	bookmarks []float64
+	loopA     float64
+	loopB     float64
+}
+
+// playbackState is the last known state of mpv, used to restore it after a crash
+type playbackState struct {
+	sync.Mutex
+
+	position     float64
+	duration     float64
+	volume       float64
+	aid          string
+	sid          string
+	subtitleFile string
+	subDelay     float64
+	audioDelay   float64
+}
+
+func (s *playbackState) setPosition(position float64) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.position = position
+}
+
+func (s *playbackState) setDuration(duration float64) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.duration = duration
+}
+
+func (s *playbackState) setVolume(volume float64) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.volume = volume
+}
+
+func (s *playbackState) setAudioTrack(aid string) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.aid = aid
+}
+
+func (s *playbackState) setSubtitles(sid, subtitleFile string) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.sid = sid
+	s.subtitleFile = subtitleFile
+}
+
+func (s *playbackState) setDelays(subDelay, audioDelay float64) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.subDelay = subDelay
+	s.audioDelay = audioDelay
+}
+
+// mpvArgs returns the mpv arguments to restore the state with
+func (s *playbackState) mpvArgs() []string {
+	s.Lock()
+	defer s.Unlock()
+
+	args := []string{
+		"--start=" + strconv.FormatFloat(s.position, 'f', 3, 64),
+		"--volume=" + strconv.FormatFloat(s.volume, 'f', 0, 64),
+	}
+
+	if s.aid != "" {
+		args = append(args, "--aid="+s.aid)
+	}
+
+	if s.subDelay != 0 {
+		args = append(args, "--sub-delay="+strconv.FormatFloat(s.subDelay, 'f', 3, 64))
+	}
+
+	if s.audioDelay != 0 {
+		args = append(args, "--audio-delay="+strconv.FormatFloat(s.audioDelay, 'f', 3, 64))
+	}
+
+	if s.subtitleFile != "" {
+		args = append(args, "--sub-file="+s.subtitleFile, "--sub-visibility=yes")
+	} else if s.sid != "" {
+		args = append(args, "--sid="+s.sid)
+
+		if s.sid != "no" {
+			args = append(args, "--sub-visibility=yes")
+		}
+	}
+
+	return args
+}
+
 type ControlsWindow struct {
 	adw.ApplicationWindow
 
@@ -97,14 +280,32 @@ type ControlsWindow struct {
 	watchingWithTitleLabel  *gtk.Label
 	streamCodeInput         *gtk.Entry
 	copyStreamCodeButton    *gtk.Button
+	copyInviteLinkButton    *gtk.Button
+	bookmarksButton         *gtk.MenuButton
+	abLoopButton            *gtk.Button
+	bookmarkNameInput       *gtk.Entry
+	addBookmarkButton       *gtk.Button
+	bookmarksList           *gtk.ListBox
+	shareBookmarksSwitch    *gtk.Switch
+	captureButton           *gtk.MenuButton
+	screenshotButton        *gtk.Button
+	clipButton              *gtk.Button
+	clipStatusLabel         *gtk.Label
+	openCapturesButton      *gtk.Button
 
 	ctx                  context.Context
 	app                  *adw.Application
 	manager              *client.Manager
+	downloadManager      *downloads.Manager
+	automation           *automation.Server
+	remote               *remote.Server
+	remotePlayer         *remote.Player
 	apiAddr              string
 	apiUsername          string
 	apiPassword          string
-	magnetLink           string
+	gatewayClient        *mpvClient.GatewayClient
+	mediaClient          *mpvClient.GatewayClient
+	source               sources.Source
 	streamURL            string
 	settings             *gio.Settings
 	gateway              *server.Gateway
@@ -116,19 +317,24 @@ type ControlsWindow struct {
 	torrentReadme        string
 	ready                chan struct{}
 	cancelDownload       func()
-	adapter              *wrtcconn.Adapter
-	ids                  chan string
-	adapterCtx           context.Context
-	cancelAdapterCtx     func()
-	community            string
-	password             string
-	key                  string
-	bufferedMessages     []interface{}
-	bufferedPeer         *wrtcconn.Peer
-	bufferedDecoder      *json.Decoder
+	session              *session.Session
+	hosting              bool
+	history              *store.JSONStore[WatchedMedia]
+	bookmarksStore       *store.JSONStore[[]Bookmark]
+	delaysStore          *store.JSONStore[MediaDelays]
+	mpris                *mpris.Server
+	mprisConn            *dbus.Conn
+	commandLock          *sync.Mutex
 	command              *exec.Cmd
+	mpvExited            chan error
 	ipcFile              string
 	ipcDir               string
+	configFile           string
+	shortcutController   *gtk.ShortcutController
+	stopping             *atomic.Bool
+	lastState            *playbackState
+	marks                *seekerMarks
+	mediaID              string
 }
 
 func NewControlsWindow(
@@ -139,9 +345,15 @@ func NewControlsWindow(
 	selectedTorrentMedia,
 	torrentReadme string,
 	manager *client.Manager,
+	downloadManager *downloads.Manager,
+	history *store.JSONStore[WatchedMedia],
+	bookmarksStore *store.JSONStore[[]Bookmark],
+	delaysStore *store.JSONStore[MediaDelays],
+	automation *automation.Server,
+	remote *remote.Server,
 	apiAddr, apiUsername,
-	apiPassword,
-	magnetLink,
+	apiPassword string,
+	source sources.Source,
 	streamURL string,
 	settings *gio.Settings,
 	gateway *server.Gateway,
@@ -149,16 +361,7 @@ func NewControlsWindow(
 	tmpDir string,
 	ready chan struct{},
 	cancelDownload func(),
-	adapter *wrtcconn.Adapter,
-	ids chan string,
-	adapterCtx context.Context,
-	cancelAdapterCtx func(),
-	community,
-	password,
-	key string,
-	bufferedMessages []interface{},
-	bufferedPeer *wrtcconn.Peer,
-	bufferedDecoder *json.Decoder,
+	session *session.Session,
 ) (ControlsWindow, error) {
 	obj := gobject.NewObject(gTypeControlsWindow, "application", app)
 
@@ -169,10 +372,18 @@ func NewControlsWindow(
 	controlsW.ctx = ctx
 	controlsW.app = app
 	controlsW.manager = manager
+	controlsW.downloadManager = downloadManager
+	controlsW.history = history
+	controlsW.bookmarksStore = bookmarksStore
+	controlsW.delaysStore = delaysStore
+	controlsW.automation = automation
+	controlsW.remote = remote
 	controlsW.apiAddr = apiAddr
 	controlsW.apiUsername = apiUsername
 	controlsW.apiPassword = apiPassword
-	controlsW.magnetLink = magnetLink
+	controlsW.gatewayClient = mpvClient.NewGatewayClient(apiUsername, apiPassword, nil)
+	controlsW.mediaClient = mpvClient.NewGatewayClient("", "", nil)
+	controlsW.source = source
 	controlsW.streamURL = streamURL
 	controlsW.settings = settings
 	controlsW.gateway = gateway
@@ -184,16 +395,7 @@ func NewControlsWindow(
 	controlsW.torrentReadme = torrentReadme
 	controlsW.ready = ready
 	controlsW.cancelDownload = cancelDownload
-	controlsW.adapter = adapter
-	controlsW.ids = ids
-	controlsW.adapterCtx = adapterCtx
-	controlsW.cancelAdapterCtx = cancelAdapterCtx
-	controlsW.community = community
-	controlsW.password = password
-	controlsW.key = key
-	controlsW.bufferedMessages = bufferedMessages
-	controlsW.bufferedPeer = bufferedPeer
-	controlsW.bufferedDecoder = bufferedDecoder
+	controlsW.session = session
 
 	if err := controlsW.setup(); err != nil {
 		return v, err
@@ -220,27 +422,6 @@ func formatDuration(duration time.Duration) string {
 	return fmt.Sprintf("%02d:%02d:%02d", int(hours), int(minutes), int(seconds))
 }
 
-func getStreamURL(base string, magnet, path string) (string, error) {
-	baseURL, err := url.Parse(base)
-	if err != nil {
-		return "", err
-	}
-
-	streamSuffix, err := url.Parse("/stream")
-	if err != nil {
-		return "", err
-	}
-
-	stream := baseURL.ResolveReference(streamSuffix)
-
-	q := stream.Query()
-	q.Set("magnet", magnet)
-	q.Set("path", path)
-	stream.RawQuery = q.Encode()
-
-	return stream.String(), nil
-}
-
 func (c *ControlsWindow) setup() error {
 	controlsW := (*ControlsWindow)(unsafe.Pointer(c.Widget.GetData(dataKeyGoInstance)))
 
@@ -259,73 +440,26 @@ func (c *ControlsWindow) setup() error {
 
 	descriptionWindow.PreparingProgressBar().SetVisible(true)
 
-	if controlsW.community == "" || controlsW.password == "" || controlsW.key == "" {
-		sid, err := shortid.New(1, shortid.DefaultABC, uint64(time.Now().UnixNano()))
-		if err != nil {
-			return err
-		}
-
-		controlsW.community, err = sid.Generate()
-		if err != nil {
-			return err
-		}
-
-		controlsW.password, err = sid.Generate()
-		if err != nil {
-			return err
-		}
-
-		controlsW.key, err = sid.Generate()
-		if err != nil {
-			return err
-		}
-	}
-
-	if controlsW.adapter == nil {
-		controlsW.adapterCtx, controlsW.cancelAdapterCtx = context.WithCancel(context.Background())
-	}
-
-	u, err := url.Parse(controlsW.settings.GetString(resources.SchemaWeronURLKey))
+	mediaID, err := controlsW.source.ID()
 	if err != nil {
-		controlsW.cancelAdapterCtx()
 		return err
 	}
+	controlsW.mediaID = mediaID
 
-	q := u.Query()
-	q.Set("community", controlsW.community)
-	q.Set("password", controlsW.password)
-	u.RawQuery = q.Encode()
-
-	pauses := broadcast.NewRelay[bool]()
-	positions := broadcast.NewRelay[float64]()
-	buffering := broadcast.NewRelay[bool]()
-
-	if controlsW.adapter == nil {
-		controlsW.adapter = wrtcconn.NewAdapter(
-			u.String(),
-			controlsW.key,
-			strings.Split(controlsW.settings.GetString(resources.SchemaWeronICEKey), ","),
-			[]string{"multiplex/sync"},
-			&wrtcconn.AdapterConfig{
-				Timeout:    time.Duration(time.Second * time.Duration(controlsW.settings.GetInt64(resources.SchemaWeronTimeoutKey))),
-				ForceRelay: controlsW.settings.GetBoolean(resources.SchemaWeronForceRelayKey),
-				OnSignalerReconnect: func() {
-					log.Info().
-						Str("raddr", controlsW.settings.GetString(resources.SchemaWeronURLKey)).
-						Msg("Reconnecting to signaler")
-				},
-			},
-			controlsW.adapterCtx,
-		)
-
-		controlsW.ids, err = controlsW.adapter.Open()
+	// Sessions which have been joined are already connected, new sessions are started here
+	controlsW.hosting = controlsW.session == nil
+	if controlsW.hosting {
+		controlsW.session, err = session.NewSession(session.NewConfig(controlsW.settings), "")
 		if err != nil {
-			controlsW.cancelAdapterCtx()
+			return err
+		}
+
+		if err := controlsW.session.Open(controlsW.ctx); err != nil {
 			return err
 		}
 	}
 
-	controlsW.streamCodeInput.SetText(fmt.Sprintf("%v:%v:%v", controlsW.community, controlsW.password, controlsW.key))
+	controlsW.streamCodeInput.SetText(controlsW.session.StreamCode())
 
 	connectedPeers := new(int32)
 	syncWatchingWithLabel := func(connected bool) {
@@ -359,10 +493,15 @@ func (c *ControlsWindow) setup() error {
 	}
 	controlsW.copyStreamCodeButton.ConnectClicked(&onCopyStreamCode)
 
+	onCopyInviteLink := func(gtk.Button) {
+		controlsW.ApplicationWindow.GetClipboard().SetText(links.NewJoinLink(controlsW.streamCodeInput.GetText()))
+	}
+	controlsW.copyInviteLinkButton.ConnectClicked(&onCopyInviteLink)
+
 	onStopButton := func(gtk.Button) {
 		controlsW.ApplicationWindow.Close()
 
-		mainWindow := NewMainWindow(controlsW.ctx, controlsW.app, controlsW.manager, controlsW.apiAddr, controlsW.apiUsername, controlsW.apiPassword, controlsW.settings, controlsW.gateway, controlsW.cancel, controlsW.tmpDir)
+		mainWindow := NewMainWindow(controlsW.ctx, controlsW.app, controlsW.manager, controlsW.downloadManager, controlsW.history, controlsW.bookmarksStore, controlsW.delaysStore, controlsW.automation, controlsW.remote, controlsW.apiAddr, controlsW.apiUsername, controlsW.apiPassword, controlsW.settings, controlsW.gateway, controlsW.cancel, controlsW.tmpDir)
 
 		controlsW.app.AddWindow(&mainWindow.ApplicationWindow.Window)
 		mainWindow.SetVisible(true)
@@ -404,6 +543,14 @@ func (c *ControlsWindow) setup() error {
 
 	descriptionProgressBar := descriptionWindow.PreparingProgressBar()
 	progressBarTicker := time.NewTicker(time.Millisecond * 500)
+	if controlsW.source.Type != sources.TypeTorrent {
+		// Only torrents are fetched through the gateway, so there is no progress to show for other sources
+		progressBarTicker.Stop()
+
+		for _, progressBar := range []*gtk.ProgressBar{preparingWindow.ProgressBar(), descriptionProgressBar} {
+			progressBar.SetText(L("Opening media"))
+		}
+	}
 	go func() {
 		for range progressBarTicker.C {
 			metrics, err := controlsW.manager.GetMetrics()
@@ -418,13 +565,7 @@ func (c *ControlsWindow) setup() error {
 
 		l:
 			for _, t := range metrics {
-				selectedTorrent, err := torrent.TorrentSpecFromMagnetUri(controlsW.magnetLink)
-				if err != nil {
-					OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
-					return
-				}
-
-				if selectedTorrent.InfoHash.HexString() == t.InfoHash {
+				if controlsW.mediaID == t.InfoHash {
 					peers = t.Peers
 
 					for _, f := range t.Files {
@@ -461,12 +602,9 @@ func (c *ControlsWindow) setup() error {
 	})
 
 	onPrepCancel := func(gtk.Button) {
-		controlsW.adapter.Close()
-		controlsW.cancelAdapterCtx()
-
-		pauses.Close()
-		positions.Close()
-		buffering.Close()
+		controlsW.session.Close()
+		controlsW.closeMPRIS()
+		controlsW.closeRemote()
 
 		progressBarTicker.Stop()
 
@@ -476,7 +614,7 @@ func (c *ControlsWindow) setup() error {
 
 		preparingWindow.Close()
 
-		mainWindow := NewMainWindow(controlsW.ctx, controlsW.app, controlsW.manager, controlsW.apiAddr, controlsW.apiUsername, controlsW.apiPassword, controlsW.settings, controlsW.gateway, controlsW.cancel, controlsW.tmpDir)
+		mainWindow := NewMainWindow(controlsW.ctx, controlsW.app, controlsW.manager, controlsW.downloadManager, controlsW.history, controlsW.bookmarksStore, controlsW.delaysStore, controlsW.automation, controlsW.remote, controlsW.apiAddr, controlsW.apiUsername, controlsW.apiPassword, controlsW.settings, controlsW.gateway, controlsW.cancel, controlsW.tmpDir)
 
 		controlsW.app.AddWindow(&mainWindow.ApplicationWindow.Window)
 		mainWindow.SetVisible(true)
@@ -494,21 +632,30 @@ func (c *ControlsWindow) setup() error {
 
 	controlsW.ipcFile = filepath.Join(controlsW.ipcDir, "mpv.sock")
 
-	shell := []string{"sh", "-c"}
-	if runtime.GOOS == "windows" {
-		shell = []string{"cmd.exe", "/c", "start"}
+	controlsW.configFile = filepath.Join(controlsW.ipcDir, "mpv.conf")
+	// The gateway's credentials are only sent to the gateway, never to the servers of other sources
+	authorization := ""
+	if controlsW.source.Type == sources.TypeTorrent {
+		authorization = "Basic " + usernameAndPassword
 	}
-	commandLine := append(shell, fmt.Sprintf("%v '--no-sub-visibility' '--keep-open=always' '--no-osc' '--no-input-default-bindings' '--pause' '--input-ipc-server=%v' '--http-header-fields=Authorization: Basic %v' '%v'", controlsW.settings.GetString(resources.SchemaMPVKey), controlsW.ipcFile, usernameAndPassword, controlsW.streamURL))
 
-	controlsW.command = exec.Command(
-		commandLine[0],
-		commandLine[1:]...,
-	)
-	utils.AddSysProcAttr(controlsW.command)
+	if err := mpvClient.WriteMPVConfig(controlsW.configFile, authorization); err != nil {
+		return err
+	}
+
+	controlsW.lastState.setVolume(100)
+
+	controlsW.command, err = controlsW.newMPVCommand()
+	if err != nil {
+		return err
+	}
 
-	controlsW.command.Stdin = os.Stdin
-	controlsW.command.Stdout = os.Stdout
-	controlsW.command.Stderr = os.Stderr
+	var getMagnetLink func() string
+	if controlsW.source.Type == sources.TypeTorrent {
+		getMagnetLink = func() string {
+			return controlsW.source.Magnet
+		}
+	}
 
 	AddMainMenu(
 		controlsW.ctx,
@@ -518,14 +665,16 @@ func (c *ControlsWindow) setup() error {
 		controlsW.menuButton,
 		controlsW.overlay,
 		controlsW.gateway,
-		func() string {
-			return controlsW.magnetLink
-		},
+		controlsW.manager,
+		controlsW.downloadManager,
+		getMagnetLink,
 		func() {
 			controlsW.cancel()
 
-			if controlsW.command.Process != nil {
-				if err := controlsW.command.Process.Kill(); err != nil {
+			controlsW.stopping.Store(true)
+
+			if command := controlsW.currentCommand(); command.Process != nil {
+				if err := command.Process.Kill(); err != nil {
 					OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
 					return
 				}
@@ -535,6 +684,10 @@ func (c *ControlsWindow) setup() error {
 
 	controlsW.app.AddWindow(&controlsW.ApplicationWindow.Window)
 
+	controlsW.shortcutController = gtk.NewShortcutController()
+	controlsW.shortcutController.SetPropagationPhase(gtk.PhaseBubbleValue)
+	controlsW.ApplicationWindow.AddController(&controlsW.shortcutController.EventController)
+
 	s := make(chan os.Signal, 1)
 	signal.Notify(s, os.Interrupt, syscall.SIGTERM)
 	go func() {
@@ -548,27 +701,24 @@ func (c *ControlsWindow) setup() error {
 	onShow := func(gtk.Widget) {
 		preparingWindow.SetVisible(true)
 
-		go func() {
-			<-controlsW.ready
-
-			if err := controlsW.command.Start(); err != nil {
-				OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
-				return
-			}
-		}()
-
 		onCloseRequest := func(gtk.Window) bool {
-			controlsW.adapter.Close()
-			controlsW.cancelAdapterCtx()
+			controlsW.saveHistory()
 
-			pauses.Close()
-			positions.Close()
-			buffering.Close()
+			controlsW.session.Close()
+			controlsW.closeMPRIS()
+			controlsW.closeRemote()
 
 			progressBarTicker.Stop()
 
-			if controlsW.command.Process != nil {
-				if err := utils.Kill(controlsW.command.Process); err != nil {
+			controlsW.stopping.Store(true)
+
+			// Downloads continue in the background, so show where their progress can be followed
+			if controlsW.downloadManager.Active(store.MediaKey(controlsW.mediaID, controlsW.selectedTorrentMedia)) {
+				PresentDownloadsWindow(controlsW.ctx, controlsW.app, controlsW.manager, controlsW.downloadManager)
+			}
+
+			if command := controlsW.currentCommand(); command.Process != nil {
+				if err := utils.Kill(command.Process); err != nil {
 					OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
 					return false
 				}
@@ -586,25 +736,32 @@ func (c *ControlsWindow) setup() error {
 		go func() {
 			<-controlsW.ready
 
-			for {
-				sock, err := net.Dial("unix", controlsW.ipcFile)
-				if err == nil {
-					_ = sock.Close()
-					break
+			// The window can be closed while the media is still being downloaded
+			if controlsW.stopping.Load() {
+				return
+			}
+
+			if err := controlsW.startMPV(controlsW.currentCommand()); err != nil {
+				OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
+				return
+			}
+
+			if err := mpvClient.WaitForMPVIPC(controlsW.ctx, controlsW.ipcFile, controlsW.mpvExited); err != nil {
+				// Quitting mpv before it is ready closes the window, just like quitting it during playback
+				if errors.Is(err, mpvClient.ErrMPVQuit) {
+					runOnMainThread(controlsW.ApplicationWindow.Destroy)
+
+					return
 				}
 
-				time.Sleep(time.Millisecond * 100)
+				if !controlsW.stopping.Load() && !errors.Is(err, context.Canceled) {
+					OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
+				}
 
-				log.Error().
-					Str("path", controlsW.ipcFile).
-					Err(err).
-					Msg("Could not dial IPC socket, retrying in 100ms")
+				return
 			}
 
 			controlsW.setupPlaybackControls(
-				pauses,
-				positions,
-				buffering,
 				syncWatchingWithLabel,
 				subtitlesDialog,
 				audiotracksDialog,
@@ -619,10 +776,59 @@ func (c *ControlsWindow) setup() error {
 	return nil
 }
 
+func (c *ControlsWindow) newMPVCommand(extraArgs ...string) (*exec.Cmd, error) {
+	controlsW := (*ControlsWindow)(unsafe.Pointer(c.Widget.GetData(dataKeyGoInstance)))
+
+	command, err := mpvClient.NewMPVCommand(controlsW.settings.GetString(resources.SchemaMPVKey), controlsW.ipcFile, controlsW.configFile, controlsW.streamURL, extraArgs...)
+	if err != nil {
+		return nil, err
+	}
+	utils.AddSysProcAttr(command)
+
+	command.Stdin = os.Stdin
+	command.Stdout = os.Stdout
+	command.Stderr = os.Stderr
+
+	return command, nil
+}
+
+// startMPV starts `command` and makes it the current mpv process; if the window is being closed meanwhile,
+// the process is stopped again right away so that it doesn't outlive the window
+func (c *ControlsWindow) startMPV(command *exec.Cmd) error {
+	controlsW := (*ControlsWindow)(unsafe.Pointer(c.Widget.GetData(dataKeyGoInstance)))
+
+	controlsW.commandLock.Lock()
+	defer controlsW.commandLock.Unlock()
+
+	if err := command.Start(); err != nil {
+		return err
+	}
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- command.Wait()
+	}()
+
+	controlsW.command = command
+	controlsW.mpvExited = exited
+
+	if controlsW.stopping.Load() {
+		return utils.Kill(command.Process)
+	}
+
+	return nil
+}
+
+func (c *ControlsWindow) currentCommand() *exec.Cmd {
+	controlsW := (*ControlsWindow)(unsafe.Pointer(c.Widget.GetData(dataKeyGoInstance)))
+
+	controlsW.commandLock.Lock()
+	defer controlsW.commandLock.Unlock()
+
+	return controlsW.command
+}
+
 func (c *ControlsWindow) setupPlaybackControls(
-	pauses *broadcast.Relay[bool],
-	positions *broadcast.Relay[float64],
-	buffering *broadcast.Relay[bool],
 	syncWatchingWithLabel func(bool),
 	subtitlesDialog SubtitlesDialog,
 	audiotracksDialog AudioTracksDialog,
@@ -630,6 +836,10 @@ func (c *ControlsWindow) setupPlaybackControls(
 ) {
 	controlsW := (*ControlsWindow)(unsafe.Pointer(c.Widget.GetData(dataKeyGoInstance)))
 
+	pauses := controlsW.session.Pauses
+	positions := controlsW.session.Positions
+	buffering := controlsW.session.Buffering
+
 	startPlayback := func() {
 		controlsW.playButton.SetIconName(pauseIcon)
 
@@ -700,6 +910,7 @@ func (c *ControlsWindow) setupPlaybackControls(
 				},
 				id:       track.ID,
 				priority: 0,
+				lang:     track.Lang,
 			})
 		}
 	}
@@ -757,708 +968,2429 @@ func (c *ControlsWindow) setupPlaybackControls(
 		)
 	}
 
-	handlePeer := func(peer *wrtcconn.Peer, decoder *json.Decoder) {
-		defer func() {
-			log.Info().
-				Str("peerID", peer.PeerID).
-				Str("channel", peer.ChannelID).
-				Msg("Disconnected from peer")
+	onBookmark, onABLoop, getSharedState := controlsW.setupBookmarkControls(seekToPosition, positions, controlsW.session.Bookmarks, controlsW.session.Loops)
 
-			controlsW.headerbarSpinner.SetVisible(false)
+	onSubtitleDelay, getSharedDelay := controlsW.setupDelayControls(subtitlesDialog, audiotracksDialog, controlsW.session.SubtitleDelays)
 
-			syncWatchingWithLabel(false)
-		}()
+	controlsW.setupHistory(seekToPosition, positions)
 
-		log.Info().
-			Str("peerID", peer.PeerID).
-			Str("channel", peer.ChannelID).
-			Msg("Connected to peer")
+	s := []api.Subtitle{}
+	for _, subtitle := range controlsW.subtitles {
+		s = append(s, api.Subtitle{
+			Name: subtitle.name,
+			Size: subtitle.size,
+		})
+	}
 
-		syncWatchingWithLabel(true)
+	controlsW.automation.AddSession(controlsW.session)
 
-		encoder := json.NewEncoder(peer.Conn)
-		if decoder == nil {
-			decoder = json.NewDecoder(peer.Conn)
-		}
+	go func() {
+		if err := controlsW.session.Serve(
+			controlsW.ctx,
+			controlsW.source.Message(controlsW.selectedTorrentMedia, controlsW.torrentTitle, controlsW.torrentReadme, s),
+			session.Callbacks{
+				OnPeer: func(connected bool) {
+					if !connected {
+						controlsW.headerbarSpinner.SetVisible(false)
+					}
 
-		go func() {
-			pl := pauses.Listener(0)
-			defer pl.Close()
+					syncWatchingWithLabel(connected)
+				},
+				OnPause: func(pause bool) {
+					if pause {
+						pausePlayback()
+					} else {
+						startPlayback()
+					}
+				},
+				OnPosition: seekToPosition,
+				OnBuffering: func(b bool) {
+					if b {
+						controlsW.headerbarSpinner.SetVisible(true)
 
-			ol := positions.Listener(0)
-			defer ol.Close()
+						pausePlayback()
 
-			bl := buffering.Listener(0)
-			defer bl.Close()
+						controlsW.playButton.SetIconName(pauseIcon)
+					} else {
+						controlsW.headerbarSpinner.SetVisible(false)
 
-			for {
-				select {
-				case <-controlsW.ctx.Done():
-					return
-				case pause, ok := <-pl.Ch():
-					if !ok {
-						continue
+						startPlayback()
 					}
+				},
+				OnBookmark:      onBookmark,
+				OnABLoop:        onABLoop,
+				OnSubtitleDelay: onSubtitleDelay,
+				GetPosition: func() float64 {
+					var elapsedResponse mpv.ResponseFloat64
+					if err := mpvClient.ExecuteMPVRequest(controlsW.ipcFile, func(encoder *json.Encoder, decoder *json.Decoder) error {
+						if err := encoder.Encode(mpv.Request{[]interface{}{"get_property", "time-pos"}}); err != nil {
+							return err
+						}
 
-					if err := encoder.Encode(api.NewPause(pause)); err != nil {
-						log.Debug().
+						return decoder.Decode(&elapsedResponse)
+					}); err != nil {
+						log.Error().
 							Err(err).
-							Msg("Could not encode pause, stopping")
+							Msg("Could not parse JSON from socket")
 
-						return
-					}
-				case position, ok := <-ol.Ch():
-					if !ok {
-						continue
+						return 0
 					}
 
-					if err := encoder.Encode(api.NewPosition(position)); err != nil {
-						log.Debug().
-							Err(err).
-							Msg("Could not encode pause, stopping")
+					return float64((time.Duration(int64(elapsedResponse.Data)) * time.Second).Nanoseconds())
+				},
+				GetSharedState: func() []interface{} {
+					return append(getSharedState(), getSharedDelay()...)
+				},
+			},
+		); err != nil {
+			OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
+		}
+	}()
 
-						return
-					}
-				case buffering, ok := <-bl.Ch():
-					if !ok {
-						continue
-					}
+	if err := mpvClient.ExecuteMPVRequest(controlsW.ipcFile, func(encoder *json.Encoder, decoder *json.Decoder) error {
+		if err := encoder.Encode(mpv.Request{[]interface{}{"set_property", "volume", 100}}); err != nil {
+			return err
+		}
 
-					if err := encoder.Encode(api.NewBuffering(buffering)); err != nil {
-						log.Debug().
-							Err(err).
-							Msg("Could not encode buffering, stopping")
+		var successResponse mpv.ResponseSuccess
+		return decoder.Decode(&successResponse)
+	}); err != nil {
+		OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
+		return
+	}
 
-						return
-					}
-				}
-			}
-		}()
+	subtitleActivators := controlsW.setupSubtitleHandlers(subtracks, subtitlesDialog)
 
-		if err := encoder.Encode(api.NewPause(true)); err != nil {
-			log.Debug().
-				Err(err).
-				Msg("Could not encode pause, stopping")
+	audiotrackActivators := controlsW.setupAudioTrackHandlers(audiotracks, audiotracksDialog)
 
-			return
-		}
+	controlsW.selectPreferredTracks(subtracks, subtitleActivators, audiotracks, audiotrackActivators)
 
-		s := []api.Subtitle{}
-		for _, subtitle := range controlsW.subtitles {
-			s = append(s, api.Subtitle{
-				Name: subtitle.name,
-				Size: subtitle.size,
-			})
-		}
+	controlsW.setupSeekerHandlers(seekToPosition, positions, &seekerIsSeeking, &seekerIsUnderPointer)
 
-		if err := encoder.Encode(api.NewMagnetLink(controlsW.magnetLink, controlsW.selectedTorrentMedia, controlsW.torrentTitle, controlsW.torrentReadme, s)); err != nil {
-			log.Debug().
-				Err(err).
-				Msg("Could not encode magnet link, stopping")
+	controlsW.setupChapterControls(seekToPosition, positions)
 
-			return
-		}
+	controlsW.setupKeyboardShortcuts(seekToPosition, positions, &total)
 
-		var elapsedResponse mpv.ResponseFloat64
-		if err := mpvClient.ExecuteMPVRequest(controlsW.ipcFile, func(encoder *json.Encoder, decoder *json.Decoder) error {
-			if err := encoder.Encode(mpv.Request{[]interface{}{"get_property", "time-pos"}}); err != nil {
-				return err
-			}
+	controlsW.setupCaptureControls()
 
-			return decoder.Decode(&elapsedResponse)
-		}); err != nil {
-			log.Error().
-				Err(err).
-				Msg("Could not parse JSON from socket")
+	controlsW.setupMonitoringTicker(&total, &seekerIsSeeking, preparingWindow, pauses, buffering, positions)
 
-			return
-		}
+	controlsW.setupVolumeControls()
 
-		if elapsedResponse.Data != 0 {
-			elapsed, err := time.ParseDuration(fmt.Sprintf("%vs", int64(elapsedResponse.Data)))
-			if err != nil {
-				OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
-				return
-			}
+	controlsW.setupMediaControls(subtitlesDialog, audiotracksDialog)
 
-			positions.Broadcast(float64(elapsed.Nanoseconds()))
-		}
+	controlsW.setupFullscreenControl()
 
-		for {
-			var j interface{}
-			if len(controlsW.bufferedMessages) > 0 {
-				j = controlsW.bufferedMessages[len(controlsW.bufferedMessages)-1]
-				controlsW.bufferedMessages = controlsW.bufferedMessages[:len(controlsW.bufferedMessages)-1]
-			} else {
-				if err := decoder.Decode(&j); err != nil {
+	togglePlayback := func() {
+		if !controlsW.headerbarSpinner.GetVisible() {
+			if controlsW.playButton.GetIconName() == playIcon {
+				pauses.Broadcast(false)
+				startPlayback()
+				return
+			}
+
+			pauses.Broadcast(true)
+			pausePlayback()
+		}
+	}
+
+	onPlayClicked := func(gtk.Button) {
+		togglePlayback()
+	}
+	controlsW.playButton.ConnectClicked(&onPlayClicked)
+
+	togglePlaybackAction := gio.NewSimpleAction("togglePlayback", nil)
+	onTogglePlayback := func(action gio.SimpleAction, parameter uintptr) {
+		togglePlayback()
+	}
+	togglePlaybackAction.ConnectActivate(&onTogglePlayback)
+	controlsW.ApplicationWindow.AddAction(togglePlaybackAction)
+	controlsW.addShortcut("togglePlayback", "<Ctrl>space")
+
+	toggleFullscreenAction := gio.NewSimpleAction("toggleFullscreen", nil)
+	onToggleFullscreen := func(action gio.SimpleAction, parameter uintptr) {
+		// We call `.Activate` on the button here instead of the actual handler for
+		// toggling fullscreen mode so that we also change the fullscreen button's state
+		controlsW.fullscreenButton.Activate()
+	}
+	toggleFullscreenAction.ConnectActivate(&onToggleFullscreen)
+	controlsW.ApplicationWindow.AddAction(toggleFullscreenAction)
+	controlsW.addShortcut("toggleFullscreen", "F11")
+
+	controlsW.setupMPRIS(togglePlayback, seekToPosition, positions)
+
+	controlsW.setupRemote(togglePlayback, seekToPosition, positions, subtracks, subtitleActivators, audiotracks, audiotrackActivators)
+
+	go controlsW.superviseMPV(startPlayback, pauses, positions, buffering)
+
+	controlsW.playButton.GrabFocus()
+}
+
+// runOnMainThread calls `fn` on the main thread, which is required for anything that uses widgets
+func runOnMainThread(fn func()) {
+	sourceFn := glib.SourceFunc(func(uintptr) bool {
+		fn()
+
+		return false
+	})
+	glib.IdleAdd(&sourceFn, 0)
+}
+
+// setupMPRIS lets media keys and desktop media widgets control playback like the play button and seeker do;
+// without a session bus, playback can only be controlled from the window
+func (c *ControlsWindow) setupMPRIS(togglePlayback func(), seekToPosition func(float64), positions *broadcast.Relay[float64]) {
+	controlsW := (*ControlsWindow)(unsafe.Pointer(c.Widget.GetData(dataKeyGoInstance)))
+
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		log.Warn().
+			Err(err).
+			Msg("Could not connect to session bus, continuing without MPRIS")
+
+		return
+	}
+
+	isPlaying := func() bool {
+		return controlsW.playButton.GetIconName() == pauseIcon
+	}
+
+	// Handlers are called from the bus connection, so they control the window from the main thread
+	server := mpris.NewServer(conn, mpris.Handlers{
+		Raise: func() {
+			runOnMainThread(controlsW.ApplicationWindow.Present)
+		},
+		PlayPause: func() {
+			runOnMainThread(togglePlayback)
+		},
+		Play: func() {
+			runOnMainThread(func() {
+				if !isPlaying() {
+					togglePlayback()
+				}
+			})
+		},
+		Pause: func() {
+			runOnMainThread(func() {
+				if isPlaying() {
+					togglePlayback()
+				}
+			})
+		},
+		Stop: func() {
+			runOnMainThread(func() {
+				controlsW.stopButton.Activate()
+			})
+		},
+		Seek: func(position time.Duration) {
+			value := float64(position.Nanoseconds())
+
+			runOnMainThread(func() {
+				seekToPosition(value)
+				positions.Broadcast(value)
+			})
+		},
+		GetPosition: func() time.Duration {
+			var position float64
+			if err := mpvClient.GetMPVProperty(controlsW.ipcFile, "time-pos", &position); err != nil {
+				log.Error().
+					Err(err).
+					Msg("Could not get position to seek from")
+
+				return 0
+			}
+
+			return time.Duration(position * float64(time.Second))
+		},
+		SetVolume: func(volume float64) {
+			runOnMainThread(func() {
+				controlsW.volumeScale.SetValue(volume)
+			})
+		},
+	})
+
+	if err := server.Open(); err != nil {
+		log.Warn().
+			Err(err).
+			Msg("Could not export MPRIS interface, continuing without it")
+
+		_ = conn.Close()
+
+		return
+	}
+
+	log.Info().
+		Str("name", server.Name()).
+		Msg("Exported MPRIS interface")
+
+	controlsW.mpris = server
+	controlsW.mprisConn = conn
+}
+
+// setupRemote lets the web remote control playback like the play button, seeker, volume and track dialogs do
+func (c *ControlsWindow) setupRemote(
+	togglePlayback func(),
+	seekToPosition func(float64),
+	positions *broadcast.Relay[float64],
+	subtracks []mediaWithPriorityAndID,
+	subtitleActivators []gtk.CheckButton,
+	audiotracks []audioTrack,
+	audiotrackActivators []gtk.CheckButton,
+) {
+	controlsW := (*ControlsWindow)(unsafe.Pointer(c.Widget.GetData(dataKeyGoInstance)))
+
+	if controlsW.remote == nil {
+		return
+	}
+
+	// The tracks are listed in the same order as in the dialogs, so that their activators can be used to select them
+	audioNames := []string{L("None")}
+	for _, audiotrack := range audiotracks {
+		if strings.TrimSpace(audiotrack.lang) == "" {
+			audioNames = append(audioNames, L("Untitled Track"))
+		} else {
+			audioNames = append(audioNames, audiotrack.lang)
+		}
+	}
+
+	subtitleNames := []string{L("None")}
+	for _, file := range append(slices.Clone(subtracks), controlsW.subtitles...) {
+		switch {
+		case strings.TrimSpace(file.name) != "":
+			subtitleNames = append(subtitleNames, getDisplayPathWithoutRoot(file.name))
+		case strings.TrimSpace(file.lang) != "":
+			subtitleNames = append(subtitleNames, file.lang)
+		default:
+			subtitleNames = append(subtitleNames, L("Untitled Track"))
+		}
+	}
+
+	getTracks := func(names []string, activators []gtk.CheckButton) []remote.Track {
+		tracks := []remote.Track{}
+		for i, name := range names {
+			tracks = append(tracks, remote.Track{
+				Name:   name,
+				Active: i < len(activators) && activators[i].GetActive(),
+			})
+		}
+
+		return tracks
+	}
+
+	// Handlers are called from the connections of the web remote, so they control the window from the main thread
+	player := controlsW.remote.Attach(getDisplayPathWithoutRoot(controlsW.selectedTorrentMedia), remote.Handlers{
+		SetPaused: func(pause bool) {
+			runOnMainThread(func() {
+				if playing := controlsW.playButton.GetIconName() == pauseIcon; playing == pause {
+					togglePlayback()
+				}
+			})
+		},
+		Seek: func(position float64) {
+			runOnMainThread(func() {
+				seekToPosition(position)
+				positions.Broadcast(position)
+			})
+		},
+		SetVolume: func(volume float64) {
+			runOnMainThread(func() {
+				controlsW.volumeScale.SetValue(volume)
+			})
+		},
+		SelectAudio: func(index int) {
+			runOnMainThread(func() {
+				if index < len(audiotrackActivators) {
+					audiotrackActivators[index].Activate()
+				}
+			})
+		},
+		SelectSubtitles: func(index int) {
+			runOnMainThread(func() {
+				if index < len(subtitleActivators) {
+					subtitleActivators[index].Activate()
+				}
+			})
+		},
+	})
+	player.SetVolume(controlsW.volumeScale.GetValue())
+	player.SetTracks(getTracks(audioNames, audiotrackActivators), getTracks(subtitleNames, subtitleActivators))
+
+	controlsW.remotePlayer = player
+
+	// Tracks can be selected from the dialogs at any time, so they are refreshed until the window is closed
+	go func() {
+		t := time.NewTicker(time.Second)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-controlsW.ctx.Done():
+				return
+			case <-t.C:
+				if controlsW.stopping.Load() {
+					return
+				}
+
+				runOnMainThread(func() {
+					if controlsW.remotePlayer != player {
+						return
+					}
+
+					player.SetTracks(getTracks(audioNames, audiotrackActivators), getTracks(subtitleNames, subtitleActivators))
+				})
+			}
+		}
+	}()
+}
+
+func (c *ControlsWindow) closeRemote() {
+	controlsW := (*ControlsWindow)(unsafe.Pointer(c.Widget.GetData(dataKeyGoInstance)))
+
+	controlsW.remotePlayer.Detach()
+	controlsW.remotePlayer = nil
+}
+
+func (c *ControlsWindow) closeMPRIS() {
+	controlsW := (*ControlsWindow)(unsafe.Pointer(c.Widget.GetData(dataKeyGoInstance)))
+
+	if controlsW.mpris == nil {
+		return
+	}
+
+	if err := controlsW.mpris.Close(); err != nil {
+		log.Debug().
+			Err(err).
+			Msg("Could not close MPRIS interface")
+	}
+
+	if err := controlsW.mprisConn.Close(); err != nil {
+		log.Debug().
+			Err(err).
+			Msg("Could not close session bus connection")
+	}
+
+	controlsW.mpris = nil
+	controlsW.mprisConn = nil
+}
+
+// superviseMPV waits for mpv to exit and restarts it at the last known state if it crashed
+func (c *ControlsWindow) superviseMPV(startPlayback func(), pauses *broadcast.Relay[bool], positions *broadcast.Relay[float64], buffering *broadcast.Relay[bool]) {
+	controlsW := (*ControlsWindow)(unsafe.Pointer(c.Widget.GetData(dataKeyGoInstance)))
+
+	controlsW.commandLock.Lock()
+	exited := controlsW.mpvExited
+	controlsW.commandLock.Unlock()
+
+	restarts := 0
+	startedAt := time.Now()
+	err := <-exited
+	for {
+		if err == nil || controlsW.stopping.Load() || err.Error() == errKilled.Error() {
+			break
+		}
+
+		if time.Since(startedAt) > mpvRestartResetAfter {
+			restarts = 0
+		}
+
+		if restarts >= maxMPVRestarts {
+			OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
+			return
+		}
+		restarts++
+
+		wasPlaying := controlsW.playButton.GetIconName() == pauseIcon
+		args := controlsW.lastState.mpvArgs()
+
+		log.Warn().
+			Err(err).
+			Int("restart", restarts).
+			Strs("args", args).
+			Msg("mpv exited unexpectedly, restarting")
+
+		controlsW.headerbarSpinner.SetVisible(true)
+		buffering.Broadcast(true)
+		pauses.Broadcast(true)
+
+		command, cmdErr := controlsW.newMPVCommand(args...)
+		if cmdErr != nil {
+			OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, cmdErr)
+			return
+		}
+
+		if err := controlsW.startMPV(command); err != nil {
+			OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
+			return
+		}
+		startedAt = time.Now()
+
+		controlsW.commandLock.Lock()
+		exited = controlsW.mpvExited
+		controlsW.commandLock.Unlock()
+
+		// If mpv crashes again before it is ready, this counts as another crash; if it is quit, the window is closed
+		if err = mpvClient.WaitForMPVIPC(controlsW.ctx, controlsW.ipcFile, exited); err != nil {
+			if errors.Is(err, mpvClient.ErrMPVQuit) {
+				break
+			}
+
+			if errors.Is(err, mpvClient.ErrMPVExitedBeforeReady) {
+				continue
+			}
+
+			return
+		}
+
+		controlsW.headerbarSpinner.SetVisible(false)
+		buffering.Broadcast(false)
+
+		controlsW.lastState.Lock()
+		position := controlsW.lastState.position
+		controlsW.lastState.Unlock()
+
+		positions.Broadcast(float64(time.Duration(position * float64(time.Second)).Nanoseconds()))
+
+		// If the stream still needs to load, the monitoring ticker will announce buffering
+		if wasPlaying {
+			pauses.Broadcast(false)
+			startPlayback()
+		}
+
+		err = <-exited
+	}
+
+	controlsW.ApplicationWindow.Destroy()
+}
+
+func (c *ControlsWindow) setupSubtitleHandlers(subtracks []mediaWithPriorityAndID, subtitlesDialog SubtitlesDialog) []gtk.CheckButton {
+	controlsW := (*ControlsWindow)(unsafe.Pointer(c.Widget.GetData(dataKeyGoInstance)))
+
+	subtitleActivators := []gtk.CheckButton{}
+
+	// Subtitles extracted from archives in the torrent are cached for the rest of the session
+	var (
+		archivesLock      sync.Mutex
+		archiveActivators = map[string][]*gtk.CheckButton{}
+	)
+
+	addArchiveEntry := func(archive string, entry subtitles.ArchiveEntry) *gtk.CheckButton {
+		row := adw.NewActionRow()
+
+		activator := gtk.NewCheckButton()
+		activator.SetGroup(&subtitleActivators[len(subtitleActivators)-1])
+		subtitleActivators = append(subtitleActivators, *activator)
+
+		onArchiveEntryActivate := func(gtk.CheckButton) {
+			if !activator.GetActive() {
+				return
+			}
+
+			log.Info().
+				Str("archive", archive).
+				Str("entry", entry.Name).
+				Msg("Setting subtitles from archive")
+
+			subtitlesFile, err := os.Open(entry.Path)
+			if err != nil {
+				OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
+				return
+			}
+			defer subtitlesFile.Close()
+
+			loadedSubtitlesFile, err := utils.SetSubtitles(entry.Name, subtitlesFile, controlsW.tmpDir, controlsW.ipcFile, &subtitleActivators[0], subtitlesDialog.Overlay())
+			if err != nil {
+				OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
+				return
+			}
+
+			controlsW.lastState.setSubtitles("", loadedSubtitlesFile)
+		}
+		activator.ConnectToggled(&onArchiveEntryActivate)
+
+		row.SetUseMarkup(false)
+		row.SetTitle(entry.Name)
+		row.SetSubtitle(fmt.Sprintf(L("Subtitle from archive %v"), path.Base(archive)))
+
+		row.SetActivatable(true)
+
+		row.AddPrefix(&activator.Widget)
+		row.SetActivatableWidget(&activator.Widget)
+
+		subtitlesDialog.AddSubtitleTrack(row)
+
+		return activator
+	}
+
+	// selectArchiveEntry loads the subtitles from an archive if there is only one file in it, and otherwise
+	// asks the user to choose one of the listed files
+	selectArchiveEntry := func(activators []*gtk.CheckButton) {
+		var next *gtk.CheckButton
+		switch len(activators) {
+		case 0:
+			next = &subtitleActivators[0]
+
+			toast := adw.NewToast(L("This archive does not contain subtitles."))
+			subtitlesDialog.Overlay().AddToast(toast)
+		case 1:
+			next = activators[0]
+		default:
+			next = &subtitleActivators[0]
+
+			toast := adw.NewToast(L("Select one of the subtitles from this archive."))
+			subtitlesDialog.Overlay().AddToast(toast)
+		}
+
+		time.AfterFunc(time.Millisecond*100, func() {
+			next.SetActive(true)
+		})
+	}
+
+	for i, file := range append(
+		append([]mediaWithPriorityAndID{
+			{media: media{
+				name: L("None"),
+				size: 0,
+			},
+				priority: -1,
+			},
+		},
+			subtracks...,
+		), controlsW.subtitles...) {
+		row := adw.NewActionRow()
+
+		activator := gtk.NewCheckButton()
+
+		if len(subtitleActivators) > 0 {
+			activator.SetGroup(&subtitleActivators[i-1])
+			activator.SetActive(false)
+		} else {
+			activator.SetActive(true)
+		}
+		subtitleActivators = append(subtitleActivators, *activator)
+
+		m := file.name
+		p := file.priority
+		sid := file.id
+		j := i
+		onSubtitleActivate := func(gtk.CheckButton) {
+			defer func() {
+				if len(subtitleActivators) <= 1 {
+					activator.SetActive(true)
+				}
+			}()
+
+			if j == 0 {
+				log.Info().
+					Msg("Disabling subtitles")
+
+				if err := mpvClient.ExecuteMPVRequest(controlsW.ipcFile, func(encoder *json.Encoder, decoder *json.Decoder) error {
+					if err := encoder.Encode(mpv.Request{[]interface{}{"set_property", "sid", "no"}}); err != nil {
+						return err
+					}
+
+					var successResponse mpv.ResponseSuccess
+					return decoder.Decode(&successResponse)
+				}); err != nil {
+					OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
+					return
+				}
+
+				if err := mpvClient.ExecuteMPVRequest(controlsW.ipcFile, func(encoder *json.Encoder, decoder *json.Decoder) error {
+					if err := encoder.Encode(mpv.Request{[]interface{}{"set_property", "sub-visibility", "no"}}); err != nil {
+						return err
+					}
+
+					var successResponse mpv.ResponseSuccess
+					return decoder.Decode(&successResponse)
+				}); err != nil {
+					OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
+					return
+				}
+
+				controlsW.lastState.setSubtitles("no", "")
+
+				return
+			}
+
+			if p == 0 {
+				if err := mpvClient.ExecuteMPVRequest(controlsW.ipcFile, func(encoder *json.Encoder, decoder *json.Decoder) error {
 					log.Debug().
+						Msg("Setting subtitle ID")
+
+					if err := encoder.Encode(mpv.Request{[]interface{}{"set_property", "sid", sid}}); err != nil {
+						return err
+					}
+
+					var successResponse mpv.ResponseSuccess
+					return decoder.Decode(&successResponse)
+				}); err != nil {
+					OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
+					return
+				}
+
+				if err := mpvClient.ExecuteMPVRequest(controlsW.ipcFile, func(encoder *json.Encoder, decoder *json.Decoder) error {
+					if err := encoder.Encode(mpv.Request{[]interface{}{"set_property", "sub-visibility", "yes"}}); err != nil {
+						return err
+					}
+
+					var successResponse mpv.ResponseSuccess
+					return decoder.Decode(&successResponse)
+				}); err != nil {
+					OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
+					return
+				}
+
+				controlsW.lastState.setSubtitles(strconv.Itoa(sid), "")
+
+				return
+			}
+
+			go func() {
+				defer func() {
+					subtitlesDialog.DisableSpinner()
+					subtitlesDialog.EnableOKButton()
+				}()
+
+				subtitlesDialog.DisableOKButton()
+				subtitlesDialog.EnableSpinner()
+
+				isArchive := subtitles.IsArchive(m)
+				if isArchive {
+					archivesLock.Lock()
+					activators, ok := archiveActivators[m]
+					archivesLock.Unlock()
+
+					if ok {
+						selectArchiveEntry(activators)
+
+						return
+					}
+				}
+
+				streamURL, err := mpvClient.StreamURL(controlsW.apiAddr, controlsW.source.Magnet, m)
+				if err != nil {
+					OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
+					return
+				}
+
+				log.Info().
+					Str("streamURL", streamURL).
+					Msg("Downloading subtitles")
+
+				res, err := controlsW.gatewayClient.Open(controlsW.ctx, streamURL, 0)
+				if err != nil {
+					OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
+					return
+				}
+				defer res.Close()
+
+				if isArchive {
+					archiveDir, err := os.MkdirTemp(controlsW.tmpDir, "archive")
+					if err != nil {
+						OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
+						return
+					}
+
+					entries, err := subtitles.ExtractArchive(m, res, archiveDir)
+					if err != nil {
+						log.Warn().
+							Str("streamURL", streamURL).
+							Err(err).
+							Msg("Could not extract subtitles from archive")
+
+						toast := adw.NewToast(L("Could not extract subtitles from this archive."))
+						subtitlesDialog.Overlay().AddToast(toast)
+
+						time.AfterFunc(time.Millisecond*100, func() {
+							subtitleActivators[0].SetActive(true)
+						})
+
+						return
+					}
+
+					log.Info().
+						Str("streamURL", streamURL).
+						Int("entries", len(entries)).
+						Msg("Extracted subtitles from archive")
+
+					activators := []*gtk.CheckButton{}
+					for _, entry := range entries {
+						activators = append(activators, addArchiveEntry(m, entry))
+					}
+
+					archivesLock.Lock()
+					archiveActivators[m] = activators
+					archivesLock.Unlock()
+
+					selectArchiveEntry(activators)
+
+					return
+				}
+
+				log.Info().
+					Str("streamURL", streamURL).
+					Msg("Finished downloading subtitles")
+
+				subtitlesFile, err := utils.SetSubtitles(m, res, controlsW.tmpDir, controlsW.ipcFile, &subtitleActivators[0], subtitlesDialog.Overlay())
+				if err != nil {
+					OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
+					return
+				}
+
+				controlsW.lastState.setSubtitles("", subtitlesFile)
+			}()
+		}
+		activator.ConnectActivate(&onSubtitleActivate)
+
+		if i == 0 {
+			row.SetTitle(file.name)
+			row.SetSubtitle(L("Disable subtitles"))
+
+			activator.SetActive(true)
+		} else if file.priority == 0 {
+			row.SetTitle(getDisplayPathWithoutRoot(file.name))
+			row.SetSubtitle(L("Integrated subtitle"))
+		} else if file.priority == 1 && subtitles.IsArchive(file.name) {
+			row.SetTitle(getDisplayPathWithoutRoot(file.name))
+			row.SetSubtitle(L("Subtitle archive from torrent"))
+		} else if file.priority == 1 {
+			row.SetTitle(getDisplayPathWithoutRoot(file.name))
+			row.SetSubtitle(L("Subtitle from torrent"))
+		} else {
+			row.SetTitle(getDisplayPathWithoutRoot(file.name))
+			row.SetSubtitle(L("Extra file from torrent"))
+		}
+
+		row.SetActivatable(true)
+
+		row.AddPrefix(&activator.Widget)
+		row.SetActivatableWidget(&activator.Widget)
+
+		subtitlesDialog.AddSubtitleTrack(row)
+	}
+
+	onAddSubtitlesFromFileClicked := func(gtk.Button) {
+		filePicker := gtk.NewFileChooserNative(
+			L("Select subtitle file"),
+			&controlsW.ApplicationWindow.Window,
+			gtk.FileChooserActionOpenValue,
+			"",
+			"")
+		filePicker.SetModal(true)
+		onFilePickerResponse := func(dialog gtk.NativeDialog, responseId int32) {
+			if responseId == int32(gtk.ResponseAcceptValue) {
+				log.Info().
+					Str("path", filePicker.GetFile().GetPath()).
+					Msg("Setting subtitles")
+
+				m := filePicker.GetFile().GetPath()
+				subtitlesFile, err := os.Open(m)
+				if err != nil {
+					OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
+					return
+				}
+				defer subtitlesFile.Close()
+
+				loadedSubtitlesFile, err := utils.SetSubtitles(m, subtitlesFile, controlsW.tmpDir, controlsW.ipcFile, &subtitleActivators[0], subtitlesDialog.Overlay())
+				if err != nil {
+					OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
+					return
+				}
+
+				controlsW.lastState.setSubtitles("", loadedSubtitlesFile)
+
+				row := adw.NewActionRow()
+
+				activator := gtk.NewCheckButton()
+
+				activator.SetGroup(&subtitleActivators[len(subtitleActivators)-1])
+				subtitleActivators = append(subtitleActivators, *activator)
+
+				activator.SetActive(true)
+				onFileSubtitleActivate := func(gtk.CheckButton) {
+					m := filePicker.GetFile().GetPath()
+					subtitlesFile, err := os.Open(m)
+					if err != nil {
+						OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
+						return
+					}
+					defer subtitlesFile.Close()
+
+					loadedSubtitlesFile, err := utils.SetSubtitles(m, subtitlesFile, controlsW.tmpDir, controlsW.ipcFile, &subtitleActivators[0], subtitlesDialog.Overlay())
+					if err != nil {
+						OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
+						return
+					}
+
+					controlsW.lastState.setSubtitles("", loadedSubtitlesFile)
+				}
+				activator.ConnectActivate(&onFileSubtitleActivate)
+
+				row.SetTitle(filePicker.GetFile().GetBasename())
+				row.SetSubtitle(L("Manually added"))
+
+				row.SetActivatable(true)
+
+				row.AddPrefix(&activator.Widget)
+				row.SetActivatableWidget(&activator.Widget)
+
+				subtitlesDialog.AddSubtitleTrack(row)
+			}
+
+			filePicker.Destroy()
+		}
+		filePicker.ConnectResponse(&onFilePickerResponse)
+
+		filePicker.Show()
+	}
+	subtitlesDialog.SetAddFromFileCallback(func() {
+		onAddSubtitlesFromFileClicked(gtk.Button{})
+	})
+
+	// Search results are cached by their ID so that searching again doesn't add duplicate rows
+	var (
+		searchResultsLock sync.Mutex
+		searchResults     = map[string]struct{}{}
+	)
+
+	addSearchResult := func(provider subtitles.Provider, providerName string, result subtitles.SearchResult) {
+		row := adw.NewActionRow()
+
+		activator := gtk.NewCheckButton()
+		activator.SetGroup(&subtitleActivators[len(subtitleActivators)-1])
+		subtitleActivators = append(subtitleActivators, *activator)
+
+		onSearchResultActivate := func(gtk.CheckButton) {
+			if !activator.GetActive() {
+				return
+			}
+
+			go func() {
+				defer func() {
+					subtitlesDialog.DisableSpinner()
+					subtitlesDialog.EnableOKButton()
+				}()
+
+				subtitlesDialog.DisableOKButton()
+				subtitlesDialog.EnableSpinner()
+
+				log.Info().
+					Str("provider", providerName).
+					Str("id", result.ID).
+					Str("name", result.Name).
+					Msg("Downloading subtitles from provider")
+
+				body, err := provider.Download(controlsW.ctx, result)
+				if err != nil {
+					log.Warn().
+						Str("provider", providerName).
+						Str("id", result.ID).
 						Err(err).
-						Msg("Could not decode structure, skipping")
+						Msg("Could not download subtitles from provider")
+
+					toast := adw.NewToast(L("Could not download these subtitles."))
+					subtitlesDialog.Overlay().AddToast(toast)
+
+					time.AfterFunc(time.Millisecond*100, func() {
+						subtitleActivators[0].SetActive(true)
+					})
+
+					return
+				}
+				defer body.Close()
+
+				subtitlesFile, err := utils.SetSubtitles(result.Name, body, controlsW.tmpDir, controlsW.ipcFile, &subtitleActivators[0], subtitlesDialog.Overlay())
+				if err != nil {
+					OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
+					return
+				}
+
+				controlsW.lastState.setSubtitles("", subtitlesFile)
+			}()
+		}
+		activator.ConnectToggled(&onSearchResultActivate)
+
+		language := utils.LanguageName(result.Language)
+		if language == "" {
+			language = result.Language
+		}
+
+		row.SetUseMarkup(false)
+		row.SetTitle(result.Name)
+		if result.Release != "" {
+			row.SetSubtitle(fmt.Sprintf(L("%v subtitle for %v from %v"), language, result.Release, providerName))
+		} else {
+			row.SetSubtitle(fmt.Sprintf(L("%v subtitle from %v"), language, providerName))
+		}
+
+		row.SetActivatable(true)
+
+		row.AddPrefix(&activator.Widget)
+		row.SetActivatableWidget(&activator.Widget)
+
+		subtitlesDialog.AddSubtitleTrack(row)
+	}
+
+	// hashMedia computes the movie hash of the selected file with range requests to the gateway, so only the start
+	// and end of the file are fetched; this also works if the file is still being downloaded
+	hashMedia := func() (string, int64, error) {
+		switch controlsW.source.Type {
+		case sources.TypeURL:
+			return sources.HashURL(controlsW.ctx, controlsW.mediaClient, controlsW.source.URL)
+
+		case sources.TypeFile:
+			return sources.HashFile(controlsW.source.File)
+		}
+
+		streamURL, err := mpvClient.StreamURL(controlsW.apiAddr, controlsW.source.Magnet, controlsW.selectedTorrentMedia)
+		if err != nil {
+			return "", 0, err
+		}
+
+		r := mpvClient.NewHTTPReaderAt(controlsW.ctx, controlsW.gatewayClient, streamURL)
+
+		size, err := r.Size()
+		if err != nil {
+			return "", 0, err
+		}
+
+		hash, err := subtitles.Hash(r, size)
+
+		return hash, size, err
+	}
+
+	subtitlesDialog.SetSearchCallback(func() {
+		providerURL := controlsW.settings.GetString(resources.SchemaSubtitleProviderURLKey)
+		if strings.TrimSpace(providerURL) == "" {
+			toast := adw.NewToast(L("Set a subtitle provider in the preferences to search for subtitles."))
+			subtitlesDialog.Overlay().AddToast(toast)
+
+			return
+		}
+
+		providerName := providerURL
+		if u, err := url.Parse(providerURL); err == nil && u.Host != "" {
+			providerName = u.Host
+		}
+
+		provider := subtitles.NewHTTPProvider(
+			providerURL,
+			controlsW.settings.GetString(resources.SchemaSubtitleProviderKeyKey),
+			"Multiplex v"+resources.AppVersion,
+			nil,
+		)
+
+		// Preferred languages can be names or two- and three-letter codes, but the provider only accepts ISO 639-1 codes
+		languages := []string{}
+		for _, preference := range controlsW.settings.GetStrv(resources.SchemaSubtitleLanguagesKey) {
+			code := utils.LanguageCode(preference)
+			if code == "" {
+				log.Debug().
+					Str("language", preference).
+					Msg("Ignoring preferred subtitle language without an ISO 639-1 code")
+
+				continue
+			}
+
+			if !slices.Contains(languages, code) {
+				languages = append(languages, code)
+			}
+		}
+
+		go func() {
+			defer func() {
+				subtitlesDialog.DisableSpinner()
+				subtitlesDialog.EnableOKButton()
+				subtitlesDialog.EnableSearchButton()
+			}()
+
+			subtitlesDialog.DisableSearchButton()
+			subtitlesDialog.DisableOKButton()
+			subtitlesDialog.EnableSpinner()
+
+			hash, size, err := hashMedia()
+			if err != nil {
+				log.Warn().
+					Str("path", controlsW.selectedTorrentMedia).
+					Err(err).
+					Msg("Could not hash media")
+
+				toast := adw.NewToast(L("Could not search for subtitles for this file."))
+				subtitlesDialog.Overlay().AddToast(toast)
+
+				return
+			}
+
+			log.Info().
+				Str("provider", providerName).
+				Str("hash", hash).
+				Int64("size", size).
+				Strs("languages", languages).
+				Msg("Searching for subtitles")
+
+			results, err := provider.SearchByHash(controlsW.ctx, hash, size, languages)
+			if err != nil {
+				log.Warn().
+					Str("provider", providerName).
+					Err(err).
+					Msg("Could not search for subtitles")
+
+				toast := adw.NewToast(fmt.Sprintf(L("Could not search for subtitles on %v."), providerName))
+				subtitlesDialog.Overlay().AddToast(toast)
+
+				return
+			}
+
+			log.Info().
+				Str("provider", providerName).
+				Int("results", len(results)).
+				Msg("Found subtitles")
+
+			if len(results) == 0 {
+				toast := adw.NewToast(L("No subtitles found for this file."))
+				subtitlesDialog.Overlay().AddToast(toast)
+
+				return
+			}
+
+			added := 0
+			for _, result := range results {
+				searchResultsLock.Lock()
+				_, ok := searchResults[result.ID]
+				searchResults[result.ID] = struct{}{}
+				searchResultsLock.Unlock()
+
+				if ok {
+					continue
+				}
+
+				addSearchResult(provider, providerName, result)
+				added++
+			}
+
+			toast := adw.NewToast(fmt.Sprintf(L("Found %v new subtitles."), added))
+			subtitlesDialog.Overlay().AddToast(toast)
+		}()
+	})
+
+	return subtitleActivators
+}
+
+func (c *ControlsWindow) setupAudioTrackHandlers(audiotracks []audioTrack, audiotracksDialog AudioTracksDialog) []gtk.CheckButton {
+	controlsW := (*ControlsWindow)(unsafe.Pointer(c.Widget.GetData(dataKeyGoInstance)))
+
+	audiotrackActivators := []gtk.CheckButton{}
+
+	for i, audiotrack := range append(
+		[]audioTrack{
+			{
+				lang: L("None"),
+				id:   -1,
+			},
+		},
+		audiotracks...,
+	) {
+		row := adw.NewActionRow()
+
+		activator := gtk.NewCheckButton()
+
+		if len(audiotrackActivators) > 0 {
+			activator.SetGroup(&audiotrackActivators[i-1])
+			activator.SetActive(false)
+		} else {
+			activator.SetActive(true)
+		}
+		audiotrackActivators = append(audiotrackActivators, *activator)
+
+		a := audiotrack
+		j := i
+		onAudiotrackActivate := func(gtk.CheckButton) {
+			defer func() {
+				if len(audiotrackActivators) <= 1 {
+					activator.SetActive(true)
+				}
+			}()
+
+			if len(audiotrackActivators) <= 1 {
+				// Don't disable audio if the "None" track is the only one
+
+				return
+			}
+
+			if j == 0 {
+				log.Info().
+					Msg("Disabling audio track")
+
+				if err := mpvClient.ExecuteMPVRequest(controlsW.ipcFile, func(encoder *json.Encoder, decoder *json.Decoder) error {
+					if err := encoder.Encode(mpv.Request{[]interface{}{"set_property", "aid", "no"}}); err != nil {
+						return err
+					}
+
+					var successResponse mpv.ResponseSuccess
+					return decoder.Decode(&successResponse)
+				}); err != nil {
+					OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
+					return
+				}
+
+				controlsW.lastState.setAudioTrack("no")
+
+				return
+			}
+
+			if err := mpvClient.ExecuteMPVRequest(controlsW.ipcFile, func(encoder *json.Encoder, decoder *json.Decoder) error {
+				log.Debug().
+					Int("aid", a.id).
+					Msg("Setting audio ID")
+
+				if err := encoder.Encode(mpv.Request{[]interface{}{"set_property", "aid", a.id}}); err != nil {
+					return err
+				}
+
+				var successResponse mpv.ResponseSuccess
+				return decoder.Decode(&successResponse)
+			}); err != nil {
+				OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
+				return
+			}
+
+			controlsW.lastState.setAudioTrack(strconv.Itoa(a.id))
+		}
+		activator.ConnectActivate(&onAudiotrackActivate)
+
+		if j == 0 {
+			row.SetSubtitle(L("Disable audio"))
+		} else {
+			row.SetSubtitle(fmt.Sprintf(L("Track %v"), a.id))
+		}
+
+		if i == 1 {
+			activator.SetActive(true)
+		}
+
+		if strings.TrimSpace(a.lang) == "" {
+			row.SetTitle(L("Untitled Track"))
+		} else {
+			row.SetTitle(a.lang)
+		}
+
+		row.SetActivatable(true)
+
+		row.AddPrefix(&activator.Widget)
+		row.SetActivatableWidget(&activator.Widget)
+
+		audiotracksDialog.AddAudioTrack(row)
+	}
+
+	return audiotrackActivators
+}
+
+// selectPreferredTracks selects the audio track and subtitles which best match the preferred languages; embedded
+// subtitles are preferred over subtitle files from the torrent in the same language
+func (c *ControlsWindow) selectPreferredTracks(
+	subtracks []mediaWithPriorityAndID,
+	subtitleActivators []gtk.CheckButton,
+	audiotracks []audioTrack,
+	audiotrackActivators []gtk.CheckButton,
+) {
+	controlsW := (*ControlsWindow)(unsafe.Pointer(c.Widget.GetData(dataKeyGoInstance)))
+
+	audioLanguages := controlsW.settings.GetStrv(resources.SchemaAudioLanguagesKey)
+	if len(audioLanguages) > 0 {
+		best, bestRank := -1, -1
+		for i, audiotrack := range audiotracks {
+			if rank := utils.RankLanguage(audioLanguages, audiotrack.lang); rank != -1 && (bestRank == -1 || rank < bestRank) {
+				best, bestRank = i, rank
+			}
+		}
+
+		// The first activator is the one which disables audio
+		if best != -1 && best+1 < len(audiotrackActivators) {
+			log.Info().
+				Str("lang", audiotracks[best].lang).
+				Int("aid", audiotracks[best].id).
+				Msg("Selecting preferred audio track")
+
+			audiotrackActivators[best+1].Activate()
+		}
+	}
+
+	subtitleLanguages := controlsW.settings.GetStrv(resources.SchemaSubtitleLanguagesKey)
+	if len(subtitleLanguages) > 0 {
+		best, bestRank := -1, -1
+		for i, file := range append(slices.Clone(subtracks), controlsW.subtitles...) {
+			lang := file.lang
+			if file.priority != 0 {
+				if !subtitles.IsSubtitle(file.name) {
+					continue
+				}
+
+				lang = utils.FileLanguage(file.name)
+			}
+
+			if rank := utils.RankLanguage(subtitleLanguages, lang); rank != -1 && (bestRank == -1 || rank < bestRank) {
+				best, bestRank = i, rank
+			}
+		}
+
+		// The first activator is the one which disables subtitles
+		if best != -1 && best+1 < len(subtitleActivators) {
+			log.Info().
+				Int("index", best).
+				Msg("Selecting preferred subtitles")
+
+			subtitleActivators[best+1].Activate()
+		}
+	}
+}
 
-					return
-				}
-			}
+func (c *ControlsWindow) setupSeekerHandlers(seekToPosition func(float64), positions *broadcast.Relay[float64], seekerIsSeeking *bool, seekerIsUnderPointer *bool) {
+	controlsW := (*ControlsWindow)(unsafe.Pointer(c.Widget.GetData(dataKeyGoInstance)))
 
-			var message api.Message
-			if err := mapstructure.Decode(j, &message); err != nil {
-				log.Debug().
-					Err(err).
-					Msg("Could not decode message, skipping")
+	ctrl := gtk.NewEventControllerMotion()
+	onEnter := func(gtk.EventControllerMotion, float64, float64) {
+		*seekerIsUnderPointer = true
+	}
+	ctrl.ConnectEnter(&onEnter)
+	onLeave := func(gtk.EventControllerMotion) {
+		*seekerIsUnderPointer = false
+	}
+	ctrl.ConnectLeave(&onLeave)
+	controlsW.seeker.AddController(&ctrl.EventController)
 
-				continue
+	onChangeValue := func(r gtk.Range, scroll gtk.ScrollType, value float64) bool {
+		seekToPosition(value)
+		positions.Broadcast(value)
+		return true
+	}
+	controlsW.seeker.ConnectChangeValue(&onChangeValue)
+}
+
+// addShortcut adds the accelerators for a window action to the window instead of the application, preferring custom
+// ones from the settings. They are only handled after the focused widget had the chance to handle the key and never
+// while text is being entered, so that i.e. the arrow keys still move the seeker and letters can be typed into entries.
+func (c *ControlsWindow) addShortcut(actionName string, defaults ...string) {
+	controlsW := (*ControlsWindow)(unsafe.Pointer(c.Widget.GetData(dataKeyGoInstance)))
+
+	accels := getAccelsForAction(controlsW.settings, actionName, defaults)
+	setWindowAccelsForAction("win."+actionName, accels)
+
+	onDestroy := glib.DestroyNotify(func(data uintptr) {})
+
+	for _, accel := range accels {
+		// Only shortcuts which would otherwise type text are ignored while typing, so that i.e. `<Ctrl>d` still works
+		var (
+			key  uint32
+			mods gdk.ModifierType
+		)
+		typesText := gtk.AcceleratorParse(accel, &key, &mods) && mods&(gdk.ControlMaskValue|gdk.AltMaskValue|gdk.SuperMaskValue|gdk.MetaMaskValue) == 0
+
+		onShortcut := gtk.ShortcutFunc(func(widget uintptr, args *glib.Variant, data uintptr) bool {
+			if typesText && controlsW.isEditingText() {
+				return false
 			}
 
-			log.Info().Interface("message", message).Msg("Decoded message")
+			return controlsW.ApplicationWindow.ActivateActionVariant("win."+actionName, nil)
+		})
+		action := gtk.NewCallbackAction(&onShortcut, 0, &onDestroy)
 
-			switch message.Type {
-			case api.TypePause:
-				var p api.Pause
-				if err := mapstructure.Decode(j, &p); err != nil {
-					log.Debug().
-						Err(err).
-						Msg("Could not decode pause, skipping")
+		controlsW.shortcutController.AddShortcut(gtk.NewShortcut(gtk.ShortcutTriggerParseString(accel), &action.ShortcutAction))
+	}
+}
 
-					continue
-				}
+// isEditingText returns whether the focused widget is one that text can be entered into
+func (c *ControlsWindow) isEditingText() bool {
+	controlsW := (*ControlsWindow)(unsafe.Pointer(c.Widget.GetData(dataKeyGoInstance)))
 
-				if p.Pause {
-					if pausePlayback != nil {
-						pausePlayback()
-					}
-				} else {
-					if startPlayback != nil {
-						startPlayback()
-					}
-				}
-			case api.TypePosition:
-				var p api.Position
-				if err := mapstructure.Decode(j, &p); err != nil {
-					log.Debug().
-						Err(err).
-						Msg("Could not decode position, skipping")
+	focus := controlsW.ApplicationWindow.GetFocus()
+	if focus == nil {
+		return false
+	}
 
-					continue
+	return gobject.TypeCheckInstanceIsA((*gobject.TypeInstance)(unsafe.Pointer(focus.GoPointer())), gtk.EditableGLibType())
+}
+
+func (c *ControlsWindow) setupKeyboardShortcuts(seekToPosition func(float64), positions *broadcast.Relay[float64], total *time.Duration) {
+	controlsW := (*ControlsWindow)(unsafe.Pointer(c.Widget.GetData(dataKeyGoInstance)))
+
+	seekRelative := func(offset time.Duration) {
+		var position float64
+		if err := mpvClient.GetMPVProperty(controlsW.ipcFile, "time-pos", &position); err != nil {
+			log.Error().
+				Err(err).
+				Msg("Could not get position to seek from")
+
+			return
+		}
+
+		target := time.Duration(position*float64(time.Second)) + offset
+		if target < 0 {
+			target = 0
+		}
+
+		if *total > 0 && target > *total {
+			target = *total
+		}
+
+		value := float64(target.Nanoseconds())
+
+		seekToPosition(value)
+		positions.Broadcast(value)
+	}
+
+	changeVolume := func(offset float64) {
+		controlsW.volumeScale.SetValue(math.Max(0, math.Min(1, controlsW.volumeScale.GetValue()+offset)))
+	}
+
+	cycleTrack := func(property string, direction string) (string, error) {
+		if err := mpvClient.ExecuteMPVCommand(controlsW.ipcFile, nil, "cycle", property, direction); err != nil {
+			return "", err
+		}
+
+		// mpv returns `false` instead of a track ID if the track is disabled
+		var id any
+		if err := mpvClient.GetMPVProperty(controlsW.ipcFile, property, &id); err != nil {
+			return "", err
+		}
+
+		if value, ok := id.(float64); ok {
+			return strconv.Itoa(int(value)), nil
+		}
+
+		return "no", nil
+	}
+
+	cycleAudioTrack := func(direction string) {
+		aid, err := cycleTrack("aid", direction)
+		if err != nil {
+			OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
+			return
+		}
+
+		log.Info().
+			Str("aid", aid).
+			Msg("Cycled audio track")
+
+		controlsW.lastState.setAudioTrack(aid)
+
+		toast := adw.NewToast(L("Audio disabled"))
+		if aid != "no" {
+			toast = adw.NewToast(fmt.Sprintf(L("Audio track %v"), aid))
+		}
+		controlsW.overlay.AddToast(toast)
+	}
+
+	actions := []struct {
+		name     string
+		accels   []string
+		activate func()
+	}{
+		{
+			name:     "seekBackward",
+			accels:   []string{"Left"},
+			activate: func() { seekRelative(-seekStep) },
+		},
+		{
+			name:     "seekForward",
+			accels:   []string{"Right"},
+			activate: func() { seekRelative(seekStep) },
+		},
+		{
+			name:     "increaseVolume",
+			accels:   []string{"Up"},
+			activate: func() { changeVolume(volumeStep) },
+		},
+		{
+			name:     "decreaseVolume",
+			accels:   []string{"Down"},
+			activate: func() { changeVolume(-volumeStep) },
+		},
+		{
+			name:   "toggleMute",
+			accels: []string{"m"},
+			// We call `.Activate` on the button here instead of the actual handler for
+			// toggling mute so that the volume scale and icons are updated as well
+			activate: func() { controlsW.volumeMuteButton.Activate() },
+		},
+		{
+			name:     "previousAudioTrack",
+			accels:   []string{"bracketleft"},
+			activate: func() { cycleAudioTrack("down") },
+		},
+		{
+			name:     "nextAudioTrack",
+			accels:   []string{"bracketright"},
+			activate: func() { cycleAudioTrack("up") },
+		},
+		{
+			name:   "cycleSubtitles",
+			accels: []string{"j"},
+			activate: func() {
+				sid, err := cycleTrack("sid", "up")
+				if err != nil {
+					OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
+					return
 				}
 
-				if seekToPosition != nil {
-					seekToPosition(p.Position)
+				// Subtitles are hidden by default, so show them when switching to a track
+				if err := mpvClient.SetMPVProperty(controlsW.ipcFile, "sub-visibility", sid != "no"); err != nil {
+					OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
+					return
 				}
-			case api.TypeMagnet:
-				var m api.Magnet
-				if err := mapstructure.Decode(j, &m); err != nil {
-					log.Debug().
-						Err(err).
-						Msg("Could not decode magnet, skipping")
 
-					continue
+				log.Info().
+					Str("sid", sid).
+					Msg("Cycled subtitles")
+
+				controlsW.lastState.setSubtitles(sid, "")
+
+				toast := adw.NewToast(L("Subtitles disabled"))
+				if sid != "no" {
+					toast = adw.NewToast(fmt.Sprintf(L("Subtitle track %v"), sid))
+				}
+				controlsW.overlay.AddToast(toast)
+			},
+		},
+		{
+			name:   "toggleSubtitles",
+			accels: []string{"v"},
+			activate: func() {
+				if err := mpvClient.ExecuteMPVCommand(controlsW.ipcFile, nil, "cycle", "sub-visibility"); err != nil {
+					OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
+					return
 				}
+			},
+		},
+	}
+
+	for _, a := range actions {
+		activate := a.activate
+
+		action := gio.NewSimpleAction(a.name, nil)
+		onActivate := func(action gio.SimpleAction, parameter uintptr) {
+			activate()
+		}
+		action.ConnectActivate(&onActivate)
+		controlsW.ApplicationWindow.AddAction(action)
+		controlsW.addShortcut(a.name, a.accels...)
+	}
+}
+
+// updateSeekerMarks redraws the chapter, bookmark and A-B loop marks on the seeker
+func (c *ControlsWindow) updateSeekerMarks() {
+	controlsW := (*ControlsWindow)(unsafe.Pointer(c.Widget.GetData(dataKeyGoInstance)))
+
+	controlsW.marks.Lock()
+	defer controlsW.marks.Unlock()
+
+	controlsW.seeker.ClearMarks()
+
+	for _, chapter := range controlsW.marks.chapters {
+		controlsW.seeker.AddMark(chapter, gtk.PosBottomValue, "")
+	}
+
+	for _, bookmark := range controlsW.marks.bookmarks {
+		controlsW.seeker.AddMark(bookmark, gtk.PosTopValue, "")
+	}
+
+	for _, loop := range []float64{controlsW.marks.loopA, controlsW.marks.loopB} {
+		if loop >= 0 {
+			controlsW.seeker.AddMark(loop, gtk.PosTopValue, "")
+		}
+	}
+}
+
+// setupBookmarkControls sets up bookmarks and A-B loops; the returned functions handle bookmarks and
+// loops received from peers and return the messages to send to new peers
+func (c *ControlsWindow) setupBookmarkControls(
+	seekToPosition func(float64),
+	positions *broadcast.Relay[float64],
+	bookmarks *broadcast.Relay[*api.Bookmark],
+	loops *broadcast.Relay[*api.ABLoop],
+) (func(*api.Bookmark), func(*api.ABLoop), func() []interface{}) {
+	controlsW := (*ControlsWindow)(unsafe.Pointer(c.Widget.GetData(dataKeyGoInstance)))
+
+	controlsW.settings.Bind(resources.SchemaShareBookmarksKey, &controlsW.shareBookmarksSwitch.Object, "active", gio.GSettingsBindDefaultValue)
+
+	bookmarksStore := controlsW.bookmarksStore
+	mediaKey := store.MediaKey(controlsW.mediaID, controlsW.selectedTorrentMedia)
+
+	var (
+		bookmarksLock    sync.Mutex
+		currentBookmarks []Bookmark
+		rows             []*adw.ActionRow
+	)
+	currentBookmarks, _ = bookmarksStore.Get(mediaKey)
+
+	saveBookmarks := func() {
+		bookmarksLock.Lock()
+		bs := slices.Clone(currentBookmarks)
+		bookmarksLock.Unlock()
+
+		if err := bookmarksStore.Set(mediaKey, bs); err != nil {
+			log.Warn().
+				Err(err).
+				Msg("Could not save bookmarks")
+
+			toast := adw.NewToast(L("Could not save bookmarks."))
+			controlsW.overlay.AddToast(toast)
+		}
+	}
+
+	seekTo := func(position float64) {
+		value := float64(time.Duration(position * float64(time.Second)).Nanoseconds())
+
+		seekToPosition(value)
+		positions.Broadcast(value)
+	}
+
+	var refreshBookmarks func()
+	refreshBookmarks = func() {
+		bookmarksLock.Lock()
+		bs := slices.Clone(currentBookmarks)
+		bookmarksLock.Unlock()
+
+		sort.Slice(bs, func(i, j int) bool {
+			return bs[i].Position < bs[j].Position
+		})
+
+		for _, row := range rows {
+			controlsW.bookmarksList.Remove(&row.Widget)
+		}
+		rows = []*adw.ActionRow{}
+
+		bookmarkMarks := []float64{}
+		for _, b := range bs {
+			entry := b
 
+			row := adw.NewActionRow()
+			row.SetUseMarkup(false)
+			row.SetTitle(entry.Name)
+			row.SetSubtitle(formatDuration(time.Duration(entry.Position * float64(time.Second))))
+			row.SetActivatable(true)
+
+			onActivated := func(adw.ActionRow) {
 				log.Info().
-					Str("magnet", m.Magnet).
-					Str("path", m.Path).
-					Msg("Got magnet link")
-			case api.TypeBuffering:
-				var b api.Buffering
-				if err := mapstructure.Decode(j, &b); err != nil {
-					log.Debug().
-						Err(err).
-						Msg("Could not decode buffering, skipping")
+					Str("name", entry.Name).
+					Float64("position", entry.Position).
+					Msg("Jumping to bookmark")
+
+				seekTo(entry.Position)
+			}
+			row.ConnectActivated(&onActivated)
+
+			deleteButton := gtk.NewButtonFromIconName("user-trash-symbolic")
+			deleteButton.AddCssClass("flat")
+			deleteButton.SetValign(gtk.AlignCenterValue)
+			deleteButton.SetTooltipText(L("Delete Bookmark"))
+
+			onDelete := func(gtk.Button) {
+				bookmarksLock.Lock()
+				currentBookmarks = slices.DeleteFunc(currentBookmarks, func(candidate Bookmark) bool {
+					return candidate == entry
+				})
+				bookmarksLock.Unlock()
+
+				saveBookmarks()
+				refreshBookmarks()
+			}
+			deleteButton.ConnectClicked(&onDelete)
+
+			row.AddSuffix(&deleteButton.Widget)
+
+			controlsW.bookmarksList.Append(&row.Widget)
+			rows = append(rows, row)
+
+			bookmarkMarks = append(bookmarkMarks, float64(time.Duration(entry.Position*float64(time.Second)).Nanoseconds()))
+		}
+
+		controlsW.bookmarksList.SetVisible(len(bs) > 0)
+
+		controlsW.marks.Lock()
+		controlsW.marks.bookmarks = bookmarkMarks
+		controlsW.marks.Unlock()
+
+		controlsW.updateSeekerMarks()
+	}
+
+	// addBookmark returns false if an equivalent bookmark already exists
+	addBookmark := func(b Bookmark) bool {
+		bookmarksLock.Lock()
+		defer bookmarksLock.Unlock()
+
+		for _, candidate := range currentBookmarks {
+			if candidate.Name == b.Name && math.Abs(candidate.Position-b.Position) < 1 {
+				return false
+			}
+		}
+
+		currentBookmarks = append(currentBookmarks, b)
+
+		return true
+	}
+
+	onAddBookmark := func() {
+		var position float64
+		if err := mpvClient.GetMPVProperty(controlsW.ipcFile, "time-pos", &position); err != nil {
+			OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
+			return
+		}
+
+		name := strings.TrimSpace(controlsW.bookmarkNameInput.GetText())
+		if name == "" {
+			name = fmt.Sprintf(L("Bookmark at %v"), formatDuration(time.Duration(position*float64(time.Second))))
+		}
+
+		log.Info().
+			Str("name", name).
+			Float64("position", position).
+			Msg("Adding bookmark")
+
+		if !addBookmark(Bookmark{
+			Name:     name,
+			Position: position,
+		}) {
+			return
+		}
+
+		controlsW.bookmarkNameInput.SetText("")
+
+		saveBookmarks()
+		refreshBookmarks()
+
+		if controlsW.settings.GetBoolean(resources.SchemaShareBookmarksKey) {
+			bookmarks.Broadcast(api.NewBookmark(name, float64(time.Duration(position*float64(time.Second)).Nanoseconds())))
+		}
+	}
+
+	onAddBookmarkClicked := func(gtk.Button) {
+		onAddBookmark()
+	}
+	controlsW.addBookmarkButton.ConnectClicked(&onAddBookmarkClicked)
+
+	onBookmarkNameActivate := func(gtk.Entry) {
+		onAddBookmark()
+	}
+	controlsW.bookmarkNameInput.ConnectActivate(&onBookmarkNameActivate)
+
+	updateLoopButton := func() {
+		controlsW.marks.Lock()
+		a, b := controlsW.marks.loopA, controlsW.marks.loopB
+		controlsW.marks.Unlock()
+
+		if a < 0 {
+			controlsW.abLoopButton.SetLabel(L("Set Loop Start"))
+		} else if b < 0 {
+			controlsW.abLoopButton.SetLabel(L("Set Loop End"))
+		} else {
+			controlsW.abLoopButton.SetLabel(L("Clear Loop"))
+		}
+	}
+
+	// applyABLoop sets the loop points (in nanoseconds, negative to unset) in mpv
+	applyABLoop := func(a, b float64) {
+		for _, point := range []struct {
+			property string
+			value    float64
+		}{
+			{"ab-loop-a", a},
+			{"ab-loop-b", b},
+		} {
+			var value interface{} = "no"
+			if point.value >= 0 {
+				value = time.Duration(point.value).Seconds()
+			}
+
+			if err := mpvClient.SetMPVProperty(controlsW.ipcFile, point.property, value); err != nil {
+				OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
+				return
+			}
+		}
+
+		log.Info().
+			Float64("a", a).
+			Float64("b", b).
+			Msg("Setting A-B loop")
+
+		controlsW.marks.Lock()
+		controlsW.marks.loopA = a
+		controlsW.marks.loopB = b
+		controlsW.marks.Unlock()
+
+		controlsW.updateSeekerMarks()
+		updateLoopButton()
+	}
+
+	toggleABLoop := func() {
+		var position float64
+		if err := mpvClient.GetMPVProperty(controlsW.ipcFile, "time-pos", &position); err != nil {
+			OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
+			return
+		}
+		current := float64(time.Duration(position * float64(time.Second)).Nanoseconds())
+
+		controlsW.marks.Lock()
+		a, b := controlsW.marks.loopA, controlsW.marks.loopB
+		controlsW.marks.Unlock()
+
+		if a < 0 {
+			a = current
+		} else if b < 0 {
+			if current < a {
+				a, b = current, a
+			} else {
+				b = current
+			}
+		} else {
+			a, b = -1, -1
+		}
+
+		applyABLoop(a, b)
+		loops.Broadcast(api.NewABLoop(a, b))
+	}
+
+	onABLoopClicked := func(gtk.Button) {
+		toggleABLoop()
+	}
+	controlsW.abLoopButton.ConnectClicked(&onABLoopClicked)
+
+	toggleABLoopAction := gio.NewSimpleAction("toggleABLoop", nil)
+	onToggleABLoop := func(action gio.SimpleAction, parameter uintptr) {
+		toggleABLoop()
+	}
+	toggleABLoopAction.ConnectActivate(&onToggleABLoop)
+	controlsW.ApplicationWindow.AddAction(toggleABLoopAction)
+	controlsW.addShortcut("toggleABLoop", "l")
+
+	addBookmarkAction := gio.NewSimpleAction("addBookmark", nil)
+	onAddBookmarkAction := func(action gio.SimpleAction, parameter uintptr) {
+		controlsW.bookmarksButton.Popup()
+		controlsW.bookmarkNameInput.GrabFocus()
+	}
+	addBookmarkAction.ConnectActivate(&onAddBookmarkAction)
+	controlsW.ApplicationWindow.AddAction(addBookmarkAction)
+	controlsW.addShortcut("addBookmark", "<Ctrl>d")
+
+	refreshBookmarks()
+
+	onBookmark := func(b *api.Bookmark) {
+		if !controlsW.settings.GetBoolean(resources.SchemaShareBookmarksKey) {
+			return
+		}
+
+		if !addBookmark(Bookmark{
+			Name:     b.Name,
+			Position: time.Duration(b.Position).Seconds(),
+		}) {
+			return
+		}
+
+		saveBookmarks()
+		refreshBookmarks()
+
+		toast := adw.NewToast(fmt.Sprintf(L("Someone shared the bookmark \"%v\"."), b.Name))
+		controlsW.overlay.AddToast(toast)
+	}
+
+	onABLoop := func(l *api.ABLoop) {
+		applyABLoop(l.A, l.B)
+	}
+
+	getSharedState := func() []interface{} {
+		messages := []interface{}{}
+
+		controlsW.marks.Lock()
+		a, b := controlsW.marks.loopA, controlsW.marks.loopB
+		controlsW.marks.Unlock()
+
+		if a >= 0 || b >= 0 {
+			messages = append(messages, api.NewABLoop(a, b))
+		}
+
+		if controlsW.settings.GetBoolean(resources.SchemaShareBookmarksKey) {
+			bookmarksLock.Lock()
+			for _, bookmark := range currentBookmarks {
+				messages = append(messages, api.NewBookmark(bookmark.Name, float64(time.Duration(bookmark.Position*float64(time.Second)).Nanoseconds())))
+			}
+			bookmarksLock.Unlock()
+		}
+
+		return messages
+	}
 
-					continue
-				}
+	return onBookmark, onABLoop, getSharedState
+}
 
-				if b.Buffering {
-					controlsW.headerbarSpinner.SetVisible(true)
+// setupHistory saves the progress periodically and offers to continue from the last position. Peers which joined
+// follow the position of the session, so only the host is offered to continue, which then moves its peers along.
+func (c *ControlsWindow) setupHistory(seekToPosition func(float64), positions *broadcast.Relay[float64]) {
+	controlsW := (*ControlsWindow)(unsafe.Pointer(c.Widget.GetData(dataKeyGoInstance)))
 
-					if pausePlayback != nil {
-						pausePlayback()
-					}
+	if last, ok := controlsW.history.Get(store.MediaKey(controlsW.mediaID, controlsW.selectedTorrentMedia)); ok && controlsW.hosting && last.resumable() {
+		toast := adw.NewToast(fmt.Sprintf(L("You stopped watching at %v."), formatDuration(last.position())))
+		toast.SetButtonLabel(L("Continue"))
+		// The preparing window can still cover the toast, so it is kept until it is dismissed
+		toast.SetTimeout(0)
 
-					controlsW.playButton.SetIconName(pauseIcon)
-				} else {
-					controlsW.headerbarSpinner.SetVisible(false)
+		onContinue := func(adw.Toast) {
+			position := float64(last.position().Nanoseconds())
 
-					if startPlayback != nil {
-						startPlayback()
-					}
-				}
-			}
+			log.Info().
+				Dur("position", last.position()).
+				Msg("Continuing from last position")
+
+			seekToPosition(position)
+			positions.Broadcast(position)
 		}
-	}
+		toast.ConnectButtonClicked(&onContinue)
 
-	if controlsW.bufferedPeer != nil {
-		go handlePeer(controlsW.bufferedPeer, controlsW.bufferedDecoder)
+		controlsW.overlay.AddToast(toast)
 	}
 
 	go func() {
-		for {
-			select {
-			case <-controlsW.ctx.Done():
-				if err := controlsW.ctx.Err(); err != context.Canceled {
-					OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
-					return
-				}
+		t := time.NewTicker(historySaveInterval)
+		defer t.Stop()
 
+		for range t.C {
+			if controlsW.stopping.Load() {
 				return
-			case rid := <-controlsW.ids:
-				log.Info().
-					Str("raddr", controlsW.settings.GetString(resources.SchemaWeronURLKey)).
-					Str("id", rid).
-					Msg("Reconnecting to signaler")
-			case peer := <-controlsW.adapter.Accept():
-				go handlePeer(peer, nil)
 			}
+
+			controlsW.saveHistory()
 		}
 	}()
+}
 
-	if err := mpvClient.ExecuteMPVRequest(controlsW.ipcFile, func(encoder *json.Encoder, decoder *json.Decoder) error {
-		if err := encoder.Encode(mpv.Request{[]interface{}{"set_property", "volume", 100}}); err != nil {
-			return err
-		}
+// saveHistory records the last known position of the media; nothing is recorded before mpv knows its duration
+func (c *ControlsWindow) saveHistory() {
+	controlsW := (*ControlsWindow)(unsafe.Pointer(c.Widget.GetData(dataKeyGoInstance)))
 
-		var successResponse mpv.ResponseSuccess
-		return decoder.Decode(&successResponse)
-	}); err != nil {
-		OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
+	if controlsW.history == nil {
 		return
 	}
 
-	controlsW.setupSubtitleHandlers(subtracks, subtitlesDialog)
+	controlsW.lastState.Lock()
+	position := controlsW.lastState.position
+	duration := controlsW.lastState.duration
+	controlsW.lastState.Unlock()
 
-	controlsW.setupAudioTrackHandlers(audiotracks, audiotracksDialog)
+	if duration <= 0 {
+		return
+	}
 
-	controlsW.setupSeekerHandlers(seekToPosition, positions, &seekerIsSeeking, &seekerIsUnderPointer)
+	link := controlsW.source.Magnet
+	switch controlsW.source.Type {
+	case sources.TypeURL:
+		link = controlsW.source.URL
+	case sources.TypeFile:
+		link = controlsW.source.File
+	}
 
-	controlsW.setupMonitoringTicker(&total, &seekerIsSeeking, preparingWindow, pauses, buffering, positions)
+	if err := controlsW.history.Set(store.MediaKey(controlsW.mediaID, controlsW.selectedTorrentMedia), WatchedMedia{
+		Type:      controlsW.source.Type,
+		Link:      link,
+		Title:     controlsW.torrentTitle,
+		Path:      controlsW.selectedTorrentMedia,
+		Position:  position,
+		Duration:  duration,
+		WatchedAt: time.Now(),
+	}); err != nil {
+		log.Warn().
+			Err(err).
+			Msg("Could not save watch history")
+	}
+}
 
-	controlsW.setupVolumeControls()
+// setupDelayControls sets up the subtitle and audio delays and restores the saved ones; the returned functions
+// handle subtitle delays received from peers and return the messages to send to new peers
+func (c *ControlsWindow) setupDelayControls(
+	subtitlesDialog SubtitlesDialog,
+	audiotracksDialog AudioTracksDialog,
+	subtitleDelays *broadcast.Relay[*api.SubtitleDelay],
+) (func(*api.SubtitleDelay), func() []interface{}) {
+	controlsW := (*ControlsWindow)(unsafe.Pointer(c.Widget.GetData(dataKeyGoInstance)))
 
-	controlsW.setupMediaControls(subtitlesDialog, audiotracksDialog)
+	controlsW.settings.Bind(resources.SchemaShareSubtitleDelayKey, &subtitlesDialog.ShareDelaySwitch().Object, "active", gio.GSettingsBindDefaultValue)
 
-	controlsW.setupFullscreenControl()
+	delaysStore := controlsW.delaysStore
+	mediaKey := store.MediaKey(controlsW.mediaID, controlsW.selectedTorrentMedia)
 
-	togglePlayback := func() {
-		if !controlsW.headerbarSpinner.GetVisible() {
-			if controlsW.playButton.GetIconName() == playIcon {
-				pauses.Broadcast(false)
-				startPlayback()
-				return
-			}
+	var (
+		delaysLock sync.Mutex
+		delays     MediaDelays // Delays which are currently applied
+		saved      MediaDelays // Delays which were chosen locally; delays set by peers are only applied, not saved
 
-			pauses.Broadcast(true)
-			pausePlayback()
+		// Set while applying a delay which was not chosen locally so that it doesn't get sent to peers or saved.
+		// Delays are only ever applied on the main thread, so this doesn't need to be synchronized.
+		applyingRemoteDelay bool
+	)
+	saved, _ = delaysStore.Get(mediaKey)
+
+	setDelay := func(property string, delay float64, update func(*MediaDelays)) {
+		if err := mpvClient.SetMPVProperty(controlsW.ipcFile, property, delay); err != nil {
+			OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
+
+			return
 		}
-	}
 
-	onPlayClicked := func(gtk.Button) {
-		togglePlayback()
-	}
-	controlsW.playButton.ConnectClicked(&onPlayClicked)
+		log.Info().
+			Str("property", property).
+			Float64("delay", delay).
+			Msg("Setting delay")
+
+		delaysLock.Lock()
+		update(&delays)
+		current := delays
+		if !applyingRemoteDelay {
+			update(&saved)
+		}
+		persisted := saved
+		delaysLock.Unlock()
 
-	togglePlaybackAction := gio.NewSimpleAction("togglePlayback", nil)
-	onTogglePlayback := func(action gio.SimpleAction, parameter uintptr) {
-		togglePlayback()
-	}
-	togglePlaybackAction.ConnectActivate(&onTogglePlayback)
-	controlsW.ApplicationWindow.AddAction(togglePlaybackAction)
-	controlsW.app.SetAccelsForAction("win.togglePlayback", []string{"<Ctrl>space"})
+		controlsW.lastState.setDelays(current.Subtitle, current.Audio)
 
-	toggleFullscreenAction := gio.NewSimpleAction("toggleFullscreen", nil)
-	onToggleFullscreen := func(action gio.SimpleAction, parameter uintptr) {
-		// We call `.Activate` on the button here instead of the actual handler for
-		// toggling fullscreen mode so that we also change the fullscreen button's state
-		controlsW.fullscreenButton.Activate()
+		if applyingRemoteDelay {
+			return
+		}
+
+		var err error
+		if persisted == (MediaDelays{}) {
+			err = delaysStore.Delete(mediaKey)
+		} else {
+			err = delaysStore.Set(mediaKey, persisted)
+		}
+
+		if err != nil {
+			log.Warn().
+				Err(err).
+				Msg("Could not save delays")
+		}
 	}
-	toggleFullscreenAction.ConnectActivate(&onToggleFullscreen)
-	controlsW.ApplicationWindow.AddAction(toggleFullscreenAction)
-	controlsW.app.SetAccelsForAction("win.toggleFullscreen", []string{"F11"})
 
-	go func() {
-		if err := controlsW.command.Wait(); err != nil && err.Error() != errKilled.Error() {
-			OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
-			return
+	subtitlesDialog.SetDelayCallback(func(delay float64) {
+		setDelay("sub-delay", delay, func(d *MediaDelays) {
+			d.Subtitle = delay
+		})
+
+		if !applyingRemoteDelay && controlsW.settings.GetBoolean(resources.SchemaShareSubtitleDelayKey) {
+			subtitleDelays.Broadcast(api.NewSubtitleDelay(delay))
 		}
+	})
 
-		controlsW.ApplicationWindow.Destroy()
-	}()
+	audiotracksDialog.SetDelayCallback(func(delay float64) {
+		setDelay("audio-delay", delay, func(d *MediaDelays) {
+			d.Audio = delay
+		})
+	})
 
-	controlsW.playButton.GrabFocus()
-}
+	if restored := saved; restored != (MediaDelays{}) {
+		log.Info().
+			Float64("subtitle", restored.Subtitle).
+			Float64("audio", restored.Audio).
+			Msg("Restoring saved delays")
 
-func (c *ControlsWindow) setupSubtitleHandlers(subtracks []mediaWithPriorityAndID, subtitlesDialog SubtitlesDialog) {
-	controlsW := (*ControlsWindow)(unsafe.Pointer(c.Widget.GetData(dataKeyGoInstance)))
+		sourceFn := glib.SourceFunc(func(uintptr) bool {
+			// Saved delays are specific to this copy of the media, so they aren't shared
+			applyingRemoteDelay = true
+			subtitlesDialog.SetDelay(restored.Subtitle)
+			applyingRemoteDelay = false
 
-	subtitleActivators := []gtk.CheckButton{}
+			audiotracksDialog.SetDelay(restored.Audio)
 
-	for i, file := range append(
-		append([]mediaWithPriorityAndID{
-			{media: media{
-				name: L("None"),
-				size: 0,
-			},
-				priority: -1,
-			},
-		},
-			subtracks...,
-		), controlsW.subtitles...) {
-		row := adw.NewActionRow()
+			return false
+		})
+		glib.IdleAdd(&sourceFn, 0)
+	}
 
-		activator := gtk.NewCheckButton()
+	onSubtitleDelay := func(d *api.SubtitleDelay) {
+		if !controlsW.settings.GetBoolean(resources.SchemaShareSubtitleDelayKey) {
+			return
+		}
 
-		if len(subtitleActivators) > 0 {
-			activator.SetGroup(&subtitleActivators[i-1])
-			activator.SetActive(false)
-		} else {
-			activator.SetActive(true)
+		delaysLock.Lock()
+		current := delays.Subtitle
+		delaysLock.Unlock()
+
+		if current == d.Delay {
+			return
 		}
-		subtitleActivators = append(subtitleActivators, *activator)
 
-		m := file.name
-		p := file.priority
-		sid := file.id
-		j := i
-		onSubtitleActivate := func(gtk.CheckButton) {
-			defer func() {
-				if len(subtitleActivators) <= 1 {
-					activator.SetActive(true)
-				}
-			}()
+		sourceFn := glib.SourceFunc(func(uintptr) bool {
+			applyingRemoteDelay = true
+			subtitlesDialog.SetDelay(d.Delay)
+			applyingRemoteDelay = false
 
-			if j == 0 {
-				log.Info().
-					Msg("Disabling subtitles")
+			toast := adw.NewToast(fmt.Sprintf(L("Someone set the subtitle delay to %.1f seconds."), d.Delay))
+			controlsW.overlay.AddToast(toast)
 
-				if err := mpvClient.ExecuteMPVRequest(controlsW.ipcFile, func(encoder *json.Encoder, decoder *json.Decoder) error {
-					if err := encoder.Encode(mpv.Request{[]interface{}{"set_property", "sid", "no"}}); err != nil {
-						return err
-					}
+			return false
+		})
+		glib.IdleAdd(&sourceFn, 0)
+	}
 
-					var successResponse mpv.ResponseSuccess
-					return decoder.Decode(&successResponse)
-				}); err != nil {
-					OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
-					return
-				}
+	getSharedDelay := func() []interface{} {
+		if !controlsW.settings.GetBoolean(resources.SchemaShareSubtitleDelayKey) {
+			return []interface{}{}
+		}
 
-				if err := mpvClient.ExecuteMPVRequest(controlsW.ipcFile, func(encoder *json.Encoder, decoder *json.Decoder) error {
-					if err := encoder.Encode(mpv.Request{[]interface{}{"set_property", "sub-visibility", "no"}}); err != nil {
-						return err
-					}
+		delaysLock.Lock()
+		defer delaysLock.Unlock()
 
-					var successResponse mpv.ResponseSuccess
-					return decoder.Decode(&successResponse)
-				}); err != nil {
-					OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
-					return
-				}
+		if delays.Subtitle == 0 {
+			return []interface{}{}
+		}
 
-				return
-			}
+		return []interface{}{api.NewSubtitleDelay(delays.Subtitle)}
+	}
 
-			if p == 0 {
-				if err := mpvClient.ExecuteMPVRequest(controlsW.ipcFile, func(encoder *json.Encoder, decoder *json.Decoder) error {
-					log.Debug().
-						Msg("Setting subtitle ID")
+	return onSubtitleDelay, getSharedDelay
+}
 
-					if err := encoder.Encode(mpv.Request{[]interface{}{"set_property", "sid", sid}}); err != nil {
-						return err
-					}
+// getCaptureName returns a file name for a capture of the media at `position` (in seconds)
+func (c *ControlsWindow) getCaptureName(position float64, extension string) string {
+	controlsW := (*ControlsWindow)(unsafe.Pointer(c.Widget.GetData(dataKeyGoInstance)))
 
-					var successResponse mpv.ResponseSuccess
-					return decoder.Decode(&successResponse)
-				}); err != nil {
-					OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
-					return
-				}
+	title := strings.TrimSuffix(path.Base(controlsW.selectedTorrentMedia), path.Ext(controlsW.selectedTorrentMedia))
 
-				if err := mpvClient.ExecuteMPVRequest(controlsW.ipcFile, func(encoder *json.Encoder, decoder *json.Decoder) error {
-					if err := encoder.Encode(mpv.Request{[]interface{}{"set_property", "sub-visibility", "yes"}}); err != nil {
-						return err
-					}
+	// Characters which are reserved on at least one of the supported platforms
+	title = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`<>:"/\|?*`, r) || r < 32 {
+			return '_'
+		}
 
-					var successResponse mpv.ResponseSuccess
-					return decoder.Decode(&successResponse)
-				}); err != nil {
-					OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
-					return
-				}
+		return r
+	}, title)
 
-				return
-			}
+	return fmt.Sprintf(
+		"%v %v (%v)%v",
+		title,
+		strings.ReplaceAll(formatDuration(time.Duration(position*float64(time.Second))), ":", "-"),
+		time.Now().Format("2006-01-02 15-04-05"),
+		extension,
+	)
+}
 
-			go func() {
-				defer func() {
-					subtitlesDialog.DisableSpinner()
-					subtitlesDialog.EnableOKButton()
-				}()
+func (c *ControlsWindow) setupCaptureControls() {
+	controlsW := (*ControlsWindow)(unsafe.Pointer(c.Widget.GetData(dataKeyGoInstance)))
 
-				subtitlesDialog.DisableOKButton()
-				subtitlesDialog.EnableSpinner()
+	capturesDir := filepath.Join(controlsW.settings.GetString(resources.SchemaStorageKey), capturesDirName, controlsW.mediaID)
 
-				streamURL, err := getStreamURL(controlsW.apiAddr, controlsW.magnetLink, m)
-				if err != nil {
-					OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
-					return
-				}
+	onOpenCaptures := func(gtk.Button) {
+		if err := os.MkdirAll(capturesDir, os.ModePerm); err != nil {
+			OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
 
-				log.Info().
-					Str("streamURL", streamURL).
-					Msg("Downloading subtitles")
+			return
+		}
 
-				hc := &http.Client{}
+		if _, err := gio.AppInfoLaunchDefaultForUri(fmt.Sprintf("file://%v", capturesDir), nil); err != nil {
+			OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
 
-				req, err := http.NewRequest(http.MethodGet, streamURL, http.NoBody)
-				if err != nil {
-					OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
-					return
-				}
-				req.SetBasicAuth(controlsW.apiUsername, controlsW.apiPassword)
+			return
+		}
+	}
+	controlsW.openCapturesButton.ConnectClicked(&onOpenCaptures)
 
-				res, err := hc.Do(req)
-				if err != nil {
-					OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
-					return
-				}
-				if res.Body != nil {
-					defer res.Body.Close()
-				}
-				if res.StatusCode != http.StatusOK {
-					OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, errors.New(res.Status))
-					return
-				}
+	takeScreenshot := func() {
+		var position float64
+		if err := mpvClient.GetMPVProperty(controlsW.ipcFile, "time-pos", &position); err != nil {
+			OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
 
-				log.Info().
-					Str("streamURL", streamURL).
-					Msg("Finished downloading subtitles")
+			return
+		}
 
-				if err := utils.SetSubtitles(m, res.Body, controlsW.tmpDir, controlsW.ipcFile, &subtitleActivators[0], subtitlesDialog.Overlay()); err != nil {
-					OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
-					return
-				}
-			}()
+		if err := os.MkdirAll(capturesDir, os.ModePerm); err != nil {
+			OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
+
+			return
 		}
-		activator.ConnectActivate(&onSubtitleActivate)
 
-		if i == 0 {
-			row.SetTitle(file.name)
-			row.SetSubtitle(L("Disable subtitles"))
+		screenshotFile := filepath.Join(capturesDir, controlsW.getCaptureName(position, ".png"))
+
+		log.Info().
+			Str("path", screenshotFile).
+			Msg("Taking screenshot")
+
+		if err := mpvClient.ExecuteMPVCommand(controlsW.ipcFile, nil, "screenshot-to-file", screenshotFile, "video"); err != nil {
+			OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
 
-			activator.SetActive(true)
-		} else if file.priority == 0 {
-			row.SetTitle(getDisplayPathWithoutRoot(file.name))
-			row.SetSubtitle(L("Integrated subtitle"))
-		} else if file.priority == 1 {
-			row.SetTitle(getDisplayPathWithoutRoot(file.name))
-			row.SetSubtitle(L("Subtitle from torrent"))
-		} else {
-			row.SetTitle(getDisplayPathWithoutRoot(file.name))
-			row.SetSubtitle(L("Extra file from torrent"))
+			return
 		}
 
-		row.SetActivatable(true)
+		toast := adw.NewToast(fmt.Sprintf(L("Saved screenshot to %v."), filepath.Base(screenshotFile)))
+		controlsW.overlay.AddToast(toast)
+	}
 
-		row.AddPrefix(&activator.Widget)
-		row.SetActivatableWidget(&activator.Widget)
+	onScreenshot := func(gtk.Button) {
+		takeScreenshot()
+	}
+	controlsW.screenshotButton.ConnectClicked(&onScreenshot)
 
-		subtitlesDialog.AddSubtitleTrack(row)
+	clipStart := -1.0
+
+	resetClip := func() {
+		clipStart = -1
+
+		controlsW.clipButton.SetLabel(L("Set Clip Start"))
+		controlsW.clipStatusLabel.SetVisible(false)
 	}
 
-	onAddSubtitlesFromFileClicked := func(gtk.Button) {
-		filePicker := gtk.NewFileChooserNative(
-			L("Select subtitle file"),
-			&controlsW.ApplicationWindow.Window,
-			gtk.FileChooserActionOpenValue,
-			"",
-			"")
-		filePicker.SetModal(true)
-		onFilePickerResponse := func(dialog gtk.NativeDialog, responseId int32) {
-			if responseId == int32(gtk.ResponseAcceptValue) {
-				log.Info().
-					Str("path", filePicker.GetFile().GetPath()).
-					Msg("Setting subtitles")
+	saveClip := func(start, end float64) {
+		if err := os.MkdirAll(capturesDir, os.ModePerm); err != nil {
+			OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
 
-				m := filePicker.GetFile().GetPath()
-				subtitlesFile, err := os.Open(m)
-				if err != nil {
-					OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
-					return
-				}
-				defer subtitlesFile.Close()
+			return
+		}
 
-				if err := utils.SetSubtitles(m, subtitlesFile, controlsW.tmpDir, controlsW.ipcFile, &subtitleActivators[0], subtitlesDialog.Overlay()); err != nil {
-					OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
-					return
-				}
+		clipFile := filepath.Join(capturesDir, controlsW.getCaptureName(start, ".mkv"))
 
-				row := adw.NewActionRow()
+		// If the media has been downloaded, `streamURL` points to the local file, so the clip is encoded from disk
+		command, err := mpvClient.NewMPVEncodeCommand(controlsW.ctx, controlsW.settings.GetString(resources.SchemaMPVKey), controlsW.configFile, controlsW.streamURL, clipFile, start, end)
+		if err != nil {
+			OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
 
-				activator := gtk.NewCheckButton()
+			return
+		}
+		utils.AddSysProcAttr(command)
 
-				activator.SetGroup(&subtitleActivators[len(subtitleActivators)-1])
-				subtitleActivators = append(subtitleActivators, *activator)
+		command.Stdout = os.Stdout
+		command.Stderr = os.Stderr
 
-				activator.SetActive(true)
-				onFileSubtitleActivate := func(gtk.CheckButton) {
-					m := filePicker.GetFile().GetPath()
-					subtitlesFile, err := os.Open(m)
-					if err != nil {
-						OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
-						return
-					}
-					defer subtitlesFile.Close()
+		log.Info().
+			Str("path", clipFile).
+			Float64("start", start).
+			Float64("end", end).
+			Msg("Saving clip")
 
-					if err := utils.SetSubtitles(m, subtitlesFile, controlsW.tmpDir, controlsW.ipcFile, &subtitleActivators[0], subtitlesDialog.Overlay()); err != nil {
-						OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
-						return
-					}
+		toast := adw.NewToast(L("Saving clip ..."))
+		controlsW.overlay.AddToast(toast)
+
+		go func() {
+			if err := command.Run(); err != nil {
+				if controlsW.ctx.Err() != nil {
+					return
 				}
-				activator.ConnectActivate(&onFileSubtitleActivate)
 
-				row.SetTitle(filePicker.GetFile().GetBasename())
-				row.SetSubtitle(L("Manually added"))
+				log.Warn().
+					Err(err).
+					Str("path", clipFile).
+					Msg("Could not save clip")
 
-				row.SetActivatable(true)
+				_ = os.Remove(clipFile)
 
-				row.AddPrefix(&activator.Widget)
-				row.SetActivatableWidget(&activator.Widget)
+				toast := adw.NewToast(L("Could not save clip."))
+				controlsW.overlay.AddToast(toast)
 
-				subtitlesDialog.AddSubtitleTrack(row)
+				return
 			}
 
-			filePicker.Destroy()
-		}
-		filePicker.ConnectResponse(&onFilePickerResponse)
+			log.Info().
+				Str("path", clipFile).
+				Msg("Saved clip")
 
-		filePicker.Show()
+			toast := adw.NewToast(fmt.Sprintf(L("Saved clip to %v."), filepath.Base(clipFile)))
+			controlsW.overlay.AddToast(toast)
+		}()
 	}
-	subtitlesDialog.SetAddFromFileCallback(func() {
-		onAddSubtitlesFromFileClicked(gtk.Button{})
-	})
-}
 
-func (c *ControlsWindow) setupAudioTrackHandlers(audiotracks []audioTrack, audiotracksDialog AudioTracksDialog) {
-	controlsW := (*ControlsWindow)(unsafe.Pointer(c.Widget.GetData(dataKeyGoInstance)))
+	toggleClip := func() {
+		var position float64
+		if err := mpvClient.GetMPVProperty(controlsW.ipcFile, "time-pos", &position); err != nil {
+			OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
 
-	audiotrackActivators := []gtk.CheckButton{}
+			return
+		}
 
-	for i, audiotrack := range append(
-		[]audioTrack{
-			{
-				lang: L("None"),
-				id:   -1,
-			},
-		},
-		audiotracks...,
-	) {
-		row := adw.NewActionRow()
+		if clipStart < 0 {
+			clipStart = position
 
-		activator := gtk.NewCheckButton()
+			controlsW.clipButton.SetLabel(L("Set Clip End and Save"))
+			controlsW.clipStatusLabel.SetLabel(fmt.Sprintf(L("Clip starts at %v"), formatDuration(time.Duration(position*float64(time.Second)))))
+			controlsW.clipStatusLabel.SetVisible(true)
 
-		if len(audiotrackActivators) > 0 {
-			activator.SetGroup(&audiotrackActivators[i-1])
-			activator.SetActive(false)
-		} else {
-			activator.SetActive(true)
+			return
 		}
-		audiotrackActivators = append(audiotrackActivators, *activator)
 
-		a := audiotrack
-		j := i
-		onAudiotrackActivate := func(gtk.CheckButton) {
-			defer func() {
-				if len(audiotrackActivators) <= 1 {
-					activator.SetActive(true)
-				}
-			}()
+		start, end := clipStart, position
+		if end < start {
+			start, end = end, start
+		}
 
-			if len(audiotrackActivators) <= 1 {
-				// Don't disable audio if the "None" track is the only one
+		resetClip()
 
-				return
-			}
+		if end-start < 1 {
+			toast := adw.NewToast(L("Clip is too short."))
+			controlsW.overlay.AddToast(toast)
 
-			if j == 0 {
-				log.Info().
-					Msg("Disabling audio track")
+			return
+		}
 
-				if err := mpvClient.ExecuteMPVRequest(controlsW.ipcFile, func(encoder *json.Encoder, decoder *json.Decoder) error {
-					if err := encoder.Encode(mpv.Request{[]interface{}{"set_property", "aid", "no"}}); err != nil {
-						return err
-					}
+		saveClip(start, end)
+	}
 
-					var successResponse mpv.ResponseSuccess
-					return decoder.Decode(&successResponse)
-				}); err != nil {
-					OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
-					return
-				}
+	onClip := func(gtk.Button) {
+		toggleClip()
+	}
+	controlsW.clipButton.ConnectClicked(&onClip)
+
+	actions := []struct {
+		name     string
+		accels   []string
+		activate func()
+	}{
+		{
+			name:     "takeScreenshot",
+			accels:   []string{"s"},
+			activate: takeScreenshot,
+		},
+		{
+			name:     "toggleClip",
+			accels:   []string{"c"},
+			activate: toggleClip,
+		},
+	}
 
-				return
-			}
+	for _, a := range actions {
+		activate := a.activate
 
-			if err := mpvClient.ExecuteMPVRequest(controlsW.ipcFile, func(encoder *json.Encoder, decoder *json.Decoder) error {
-				log.Debug().
-					Int("aid", a.id).
-					Msg("Setting audio ID")
+		action := gio.NewSimpleAction(a.name, nil)
+		onActivate := func(action gio.SimpleAction, parameter uintptr) {
+			activate()
+		}
+		action.ConnectActivate(&onActivate)
+		controlsW.ApplicationWindow.AddAction(action)
+		controlsW.addShortcut(a.name, a.accels...)
+	}
+}
 
-				if err := encoder.Encode(mpv.Request{[]interface{}{"set_property", "aid", a.id}}); err != nil {
-					return err
-				}
+func (c *ControlsWindow) setupChapterControls(seekToPosition func(float64), positions *broadcast.Relay[float64]) {
+	controlsW := (*ControlsWindow)(unsafe.Pointer(c.Widget.GetData(dataKeyGoInstance)))
 
-				var successResponse mpv.ResponseSuccess
-				return decoder.Decode(&successResponse)
-			}); err != nil {
-				OpenErrorDialog(controlsW.ctx, &controlsW.ApplicationWindow, err)
-				return
+	var (
+		chaptersLock sync.Mutex
+		chapters     []mpv.ResponseChapterDescription
+	)
+
+	jumpToChapter := func(offset int) {
+		chaptersLock.Lock()
+		cs := chapters
+		chaptersLock.Unlock()
+
+		if len(cs) == 0 {
+			return
+		}
+
+		var position float64
+		if err := mpvClient.GetMPVProperty(controlsW.ipcFile, "time-pos", &position); err != nil {
+			log.Error().
+				Err(err).
+				Msg("Could not get position to jump to chapter from")
+
+			return
+		}
+
+		current := -1
+		for i, chapter := range cs {
+			if chapter.Time <= position {
+				current = i
 			}
 		}
-		activator.ConnectActivate(&onAudiotrackActivate)
 
-		if j == 0 {
-			row.SetSubtitle(L("Disable audio"))
-		} else {
-			row.SetSubtitle(fmt.Sprintf(L("Track %v"), a.id))
+		target := current + offset
+		if offset < 0 && current >= 0 && position-cs[current].Time > chapterRestartThreshold.Seconds() {
+			target = current
 		}
 
-		if i == 1 {
-			activator.SetActive(true)
+		if target < 0 {
+			target = 0
 		}
 
-		if strings.TrimSpace(a.lang) == "" {
-			row.SetTitle(L("Untitled Track"))
-		} else {
-			row.SetTitle(a.lang)
+		if target >= len(cs) {
+			return
 		}
 
-		row.SetActivatable(true)
+		log.Info().
+			Int("chapter", target).
+			Str("title", cs[target].Title).
+			Msg("Jumping to chapter")
 
-		row.AddPrefix(&activator.Widget)
-		row.SetActivatableWidget(&activator.Widget)
+		value := float64(time.Duration(cs[target].Time * float64(time.Second)).Nanoseconds())
 
-		audiotracksDialog.AddAudioTrack(row)
+		seekToPosition(value)
+		positions.Broadcast(value)
 	}
-}
 
-func (c *ControlsWindow) setupSeekerHandlers(seekToPosition func(float64), positions *broadcast.Relay[float64], seekerIsSeeking *bool, seekerIsUnderPointer *bool) {
-	controlsW := (*ControlsWindow)(unsafe.Pointer(c.Widget.GetData(dataKeyGoInstance)))
-
-	ctrl := gtk.NewEventControllerMotion()
-	onEnter := func(gtk.EventControllerMotion, float64, float64) {
-		*seekerIsUnderPointer = true
+	previousChapterAction := gio.NewSimpleAction("previousChapter", nil)
+	previousChapterAction.SetEnabled(false)
+	onPreviousChapter := func(action gio.SimpleAction, parameter uintptr) {
+		jumpToChapter(-1)
 	}
-	ctrl.ConnectEnter(&onEnter)
-	onLeave := func(gtk.EventControllerMotion) {
-		*seekerIsUnderPointer = false
+	previousChapterAction.ConnectActivate(&onPreviousChapter)
+	controlsW.ApplicationWindow.AddAction(previousChapterAction)
+	controlsW.addShortcut("previousChapter", "Page_Up")
+
+	nextChapterAction := gio.NewSimpleAction("nextChapter", nil)
+	nextChapterAction.SetEnabled(false)
+	onNextChapter := func(action gio.SimpleAction, parameter uintptr) {
+		jumpToChapter(1)
 	}
-	ctrl.ConnectLeave(&onLeave)
-	controlsW.seeker.AddController(&ctrl.EventController)
+	nextChapterAction.ConnectActivate(&onNextChapter)
+	controlsW.ApplicationWindow.AddAction(nextChapterAction)
+	controlsW.addShortcut("nextChapter", "Page_Down")
 
-	onChangeValue := func(r gtk.Range, scroll gtk.ScrollType, value float64) bool {
-		seekToPosition(value)
-		positions.Broadcast(value)
-		return true
-	}
-	controlsW.seeker.ConnectChangeValue(&onChangeValue)
+	// The chapter list is only available once the media has been loaded
+	go func() {
+		t := time.NewTicker(time.Second)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-controlsW.ctx.Done():
+				return
+			case <-t.C:
+			}
+
+			if controlsW.stopping.Load() {
+				return
+			}
+
+			var duration float64
+			if err := mpvClient.GetMPVProperty(controlsW.ipcFile, "duration", &duration); err != nil || duration <= 0 {
+				continue
+			}
+
+			cs := []mpv.ResponseChapterDescription{}
+			if err := mpvClient.GetMPVProperty(controlsW.ipcFile, "chapter-list", &cs); err != nil {
+				log.Debug().
+					Err(err).
+					Msg("Could not get chapter list")
+
+				return
+			}
+
+			log.Debug().
+				Int("chapters", len(cs)).
+				Msg("Got chapter list")
+
+			chaptersLock.Lock()
+			chapters = cs
+			chaptersLock.Unlock()
+
+			chapterMarks := []float64{}
+			for _, chapter := range cs {
+				chapterMarks = append(chapterMarks, float64(time.Duration(chapter.Time*float64(time.Second)).Nanoseconds()))
+			}
+
+			controlsW.marks.Lock()
+			controlsW.marks.chapters = chapterMarks
+			controlsW.marks.Unlock()
+
+			controlsW.updateSeekerMarks()
+
+			previousChapterAction.SetEnabled(len(cs) > 0)
+			nextChapterAction.SetEnabled(len(cs) > 0)
+
+			return
+		}
+	}()
 }
 
 func (c *ControlsWindow) setupMonitoringTicker(total *time.Duration, seekerIsSeeking *bool, preparingWindow PreparingWindow, pauses *broadcast.Relay[bool], buffering *broadcast.Relay[bool], positions *broadcast.Relay[float64]) {
@@ -1520,6 +3452,20 @@ func (c *ControlsWindow) setupMonitoringTicker(total *time.Duration, seekerIsSee
 				return
 			}
 
+			if *total != 0 {
+				controlsW.lastState.setPosition(elapsedResponse.Data)
+				controlsW.lastState.setDuration(durationResponse.Data)
+			}
+
+			if controlsW.mpris != nil {
+				controlsW.mpris.SetMedia(controlsW.mediaID, getDisplayPathWithoutRoot(controlsW.selectedTorrentMedia), controlsW.torrentTitle, *total)
+				controlsW.mpris.SetPosition(time.Duration(elapsedResponse.Data * float64(time.Second)))
+				controlsW.mpris.SetPlaying(controlsW.playButton.GetIconName() == pauseIcon)
+			}
+
+			controlsW.remotePlayer.SetPosition(elapsedResponse.Data*float64(time.Second), float64(total.Nanoseconds()))
+			controlsW.remotePlayer.SetPlaying(controlsW.playButton.GetIconName() == pauseIcon)
+
 			var pausedResponse mpv.ResponseBool
 			if err := mpvClient.ExecuteMPVRequest(controlsW.ipcFile, func(encoder *json.Encoder, decoder *json.Decoder) error {
 				if err := encoder.Encode(mpv.Request{[]interface{}{"get_property", "core-idle"}}); err != nil {
@@ -1615,6 +3561,14 @@ func (c *ControlsWindow) setupVolumeControls() {
 			controlsW.volumeMuteButton.SetIconName("audio-volume-high-symbolic")
 		}
 
+		controlsW.lastState.setVolume(value * 100)
+
+		if controlsW.mpris != nil {
+			controlsW.mpris.SetVolume(value)
+		}
+
+		controlsW.remotePlayer.SetVolume(value)
+
 		if err := mpvClient.ExecuteMPVRequest(controlsW.ipcFile, func(encoder *json.Encoder, decoder *json.Decoder) error {
 
 			log.Info().
@@ -1679,6 +3633,8 @@ func (c *ControlsWindow) setupMediaControls(subtitlesDialog SubtitlesDialog, aud
 			return
 		}
 
+		controlsW.lastState.setSubtitles("no", "")
+
 		subtitlesDialog.Close()
 	}
 	subtitlesDialog.SetCancelCallback(func() {
@@ -1774,6 +3730,18 @@ func init() {
 		typeClass.BindTemplateChildFull("watching_with_title_label", false, 0)
 		typeClass.BindTemplateChildFull("stream_code_input", false, 0)
 		typeClass.BindTemplateChildFull("copy_stream_code_button", false, 0)
+		typeClass.BindTemplateChildFull("copy_invite_link_button", false, 0)
+		typeClass.BindTemplateChildFull("bookmarks_button", false, 0)
+		typeClass.BindTemplateChildFull("ab_loop_button", false, 0)
+		typeClass.BindTemplateChildFull("bookmark_name_input", false, 0)
+		typeClass.BindTemplateChildFull("add_bookmark_button", false, 0)
+		typeClass.BindTemplateChildFull("bookmarks_list", false, 0)
+		typeClass.BindTemplateChildFull("share_bookmarks_switch", false, 0)
+		typeClass.BindTemplateChildFull("capture_button", false, 0)
+		typeClass.BindTemplateChildFull("screenshot_button", false, 0)
+		typeClass.BindTemplateChildFull("clip_button", false, 0)
+		typeClass.BindTemplateChildFull("clip_status_label", false, 0)
+		typeClass.BindTemplateChildFull("open_captures_button", false, 0)
 
 		objClass := (*gobject.ObjectClass)(unsafe.Pointer(tc))
 
@@ -1807,6 +3775,18 @@ func init() {
 				watchingWithTitleLabel  gtk.Label
 				streamCodeInput         gtk.Entry
 				copyStreamCodeButton    gtk.Button
+				copyInviteLinkButton    gtk.Button
+				bookmarksButton         gtk.MenuButton
+				abLoopButton            gtk.Button
+				bookmarkNameInput       gtk.Entry
+				addBookmarkButton       gtk.Button
+				bookmarksList           gtk.ListBox
+				shareBookmarksSwitch    gtk.Switch
+				captureButton           gtk.MenuButton
+				screenshotButton        gtk.Button
+				clipButton              gtk.Button
+				clipStatusLabel         gtk.Label
+				openCapturesButton      gtk.Button
 			)
 			parent.Widget.GetTemplateChild(gTypeControlsWindow, "toast_overlay").Cast(&overlay)
 			parent.Widget.GetTemplateChild(gTypeControlsWindow, "button_headerbar_title").Cast(&buttonHeaderbarTitle)
@@ -1828,6 +3808,18 @@ func init() {
 			parent.Widget.GetTemplateChild(gTypeControlsWindow, "watching_with_title_label").Cast(&watchingWithTitleLabel)
 			parent.Widget.GetTemplateChild(gTypeControlsWindow, "stream_code_input").Cast(&streamCodeInput)
 			parent.Widget.GetTemplateChild(gTypeControlsWindow, "copy_stream_code_button").Cast(&copyStreamCodeButton)
+			parent.Widget.GetTemplateChild(gTypeControlsWindow, "copy_invite_link_button").Cast(&copyInviteLinkButton)
+			parent.Widget.GetTemplateChild(gTypeControlsWindow, "bookmarks_button").Cast(&bookmarksButton)
+			parent.Widget.GetTemplateChild(gTypeControlsWindow, "ab_loop_button").Cast(&abLoopButton)
+			parent.Widget.GetTemplateChild(gTypeControlsWindow, "bookmark_name_input").Cast(&bookmarkNameInput)
+			parent.Widget.GetTemplateChild(gTypeControlsWindow, "add_bookmark_button").Cast(&addBookmarkButton)
+			parent.Widget.GetTemplateChild(gTypeControlsWindow, "bookmarks_list").Cast(&bookmarksList)
+			parent.Widget.GetTemplateChild(gTypeControlsWindow, "share_bookmarks_switch").Cast(&shareBookmarksSwitch)
+			parent.Widget.GetTemplateChild(gTypeControlsWindow, "capture_button").Cast(&captureButton)
+			parent.Widget.GetTemplateChild(gTypeControlsWindow, "screenshot_button").Cast(&screenshotButton)
+			parent.Widget.GetTemplateChild(gTypeControlsWindow, "clip_button").Cast(&clipButton)
+			parent.Widget.GetTemplateChild(gTypeControlsWindow, "clip_status_label").Cast(&clipStatusLabel)
+			parent.Widget.GetTemplateChild(gTypeControlsWindow, "open_captures_button").Cast(&openCapturesButton)
 
 			c := &ControlsWindow{
 				ApplicationWindow:       parent,
@@ -1851,6 +3843,25 @@ func init() {
 				watchingWithTitleLabel:  &watchingWithTitleLabel,
 				streamCodeInput:         &streamCodeInput,
 				copyStreamCodeButton:    &copyStreamCodeButton,
+				copyInviteLinkButton:    &copyInviteLinkButton,
+				bookmarksButton:         &bookmarksButton,
+				abLoopButton:            &abLoopButton,
+				bookmarkNameInput:       &bookmarkNameInput,
+				addBookmarkButton:       &addBookmarkButton,
+				bookmarksList:           &bookmarksList,
+				shareBookmarksSwitch:    &shareBookmarksSwitch,
+				captureButton:           &captureButton,
+				screenshotButton:        &screenshotButton,
+				clipButton:              &clipButton,
+				clipStatusLabel:         &clipStatusLabel,
+				openCapturesButton:      &openCapturesButton,
+				stopping:                &atomic.Bool{},
+				commandLock:             &sync.Mutex{},
+				lastState:               &playbackState{},
+				marks: &seekerMarks{
+					loopA: -1,
+					loopB: -1,
+				},
 			}
 
 			var pinner runtime.Pinner
@@ -0,0 +1,367 @@
+package components
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"time"
+	"unsafe"
+
+	"codeberg.org/puregotk/puregotk/v4/adw"
+	"codeberg.org/puregotk/puregotk/v4/gdk"
+	"codeberg.org/puregotk/puregotk/v4/gio"
+	"codeberg.org/puregotk/puregotk/v4/glib"
+	"codeberg.org/puregotk/puregotk/v4/gobject"
+	"codeberg.org/puregotk/puregotk/v4/gtk"
+	. "github.com/pojntfx/go-gettext/pkg/i18n"
+	"github.com/pojntfx/htorrent/pkg/client"
+	"github.com/pojntfx/multiplex/assets/resources"
+	"github.com/pojntfx/multiplex/internal/downloads"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	downloadsEmptyPageName = "empty_page"
+	downloadsListPageName  = "list_page"
+
+	downloadsRefreshInterval = time.Second
+)
+
+var (
+	gTypeDownloadsWindow gobject.Type
+
+	// There is only one downloads window, which is presented again if it is opened from another window
+	openDownloadsWindow *DownloadsWindow
+)
+
+type downloadRow struct {
+	row          *adw.ActionRow
+	progressBar  *gtk.ProgressBar
+	pauseButton  *gtk.Button
+	resumeButton *gtk.Button
+	cancelButton *gtk.Button
+	openButton   *gtk.Button
+}
+
+type DownloadsWindow struct {
+	adw.ApplicationWindow
+
+	stack          *gtk.Stack
+	downloadsGroup *adw.PreferencesGroup
+
+	ctx             context.Context
+	manager         *client.Manager
+	downloadManager *downloads.Manager
+	rows            map[string]*downloadRow
+	stopRefreshing  func()
+}
+
+// PresentDownloadsWindow opens the downloads window, or brings it to the front if it is already open
+func PresentDownloadsWindow(ctx context.Context, app *adw.Application, manager *client.Manager, downloadManager *downloads.Manager) {
+	if openDownloadsWindow != nil {
+		openDownloadsWindow.Present()
+
+		return
+	}
+
+	var a gtk.Application
+	app.Cast(&a)
+
+	obj := gobject.NewObject(gTypeDownloadsWindow, "application", a)
+
+	var v DownloadsWindow
+	obj.Cast(&v)
+
+	downloadsW := (*DownloadsWindow)(unsafe.Pointer(v.Widget.GetData(dataKeyGoInstance)))
+	downloadsW.ctx = ctx
+	downloadsW.manager = manager
+	downloadsW.downloadManager = downloadManager
+
+	app.AddWindow(&downloadsW.ApplicationWindow.Window)
+
+	openDownloadsWindow = downloadsW
+
+	refreshCtx, cancel := context.WithCancel(ctx)
+	downloadsW.stopRefreshing = cancel
+
+	downloadsW.refresh(downloadManager.List(), map[string]int{})
+	go downloadsW.refreshPeriodically(refreshCtx)
+
+	downloadsW.Present()
+}
+
+// refreshPeriodically fetches the status of the downloads and the peers of their torrents in the background,
+// and then updates the rows in the main loop
+func (d *DownloadsWindow) refreshPeriodically(ctx context.Context) {
+	ticker := time.NewTicker(downloadsRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		statuses := d.downloadManager.List()
+
+		peers := map[string]int{}
+		metrics, err := d.manager.GetMetrics()
+		if err != nil {
+			log.Debug().
+				Err(err).
+				Msg("Could not get metrics, continuing without peers")
+		}
+
+		for _, t := range metrics {
+			peers[t.InfoHash] = t.Peers
+		}
+
+		sourceFn := glib.SourceFunc(func(uintptr) bool {
+			if ctx.Err() == nil {
+				d.refresh(statuses, peers)
+			}
+
+			return false
+		})
+		glib.IdleAdd(&sourceFn, 0)
+	}
+}
+
+func formatDownloadSize(bytes int64) string {
+	return fmt.Sprintf(L("%v MB"), bytes/1000/1000)
+}
+
+func getDownloadStatusText(status downloads.Status, peers int) string {
+	switch status.State {
+	case downloads.StateDownloading:
+		text := fmt.Sprintf(L("%v/%v, %.1f MB/s, %v peers"), formatDownloadSize(status.Written), formatDownloadSize(status.Size), status.Speed/1000/1000, peers)
+		if eta := status.ETA(); eta >= 0 {
+			text += fmt.Sprintf(L(", %v remaining"), formatDuration(eta))
+		}
+
+		return text
+
+	case downloads.StateComplete:
+		return fmt.Sprintf(L("Complete (%v)"), formatDownloadSize(status.Size))
+
+	case downloads.StateFailed:
+		return fmt.Sprintf(L("Failed at %v/%v: %v"), formatDownloadSize(status.Written), formatDownloadSize(status.Size), status.Err)
+
+	default:
+		return fmt.Sprintf(L("Paused at %v/%v"), formatDownloadSize(status.Written), formatDownloadSize(status.Size))
+	}
+}
+
+func (d *DownloadsWindow) addRow(status downloads.Status) *downloadRow {
+	key := status.Key()
+
+	r := &downloadRow{
+		row:          adw.NewActionRow(),
+		progressBar:  gtk.NewProgressBar(),
+		pauseButton:  gtk.NewButtonFromIconName("media-playback-pause-symbolic"),
+		resumeButton: gtk.NewButtonFromIconName("media-playback-start-symbolic"),
+		cancelButton: gtk.NewButtonFromIconName("process-stop-symbolic"),
+		openButton:   gtk.NewButtonFromIconName("folder-open-symbolic"),
+	}
+
+	r.row.SetUseMarkup(false)
+	r.row.SetTitle(getDisplayPathWithoutRoot(status.Path))
+	r.row.SetTooltipText(status.Title)
+
+	r.progressBar.SetValign(gtk.AlignCenterValue)
+	r.progressBar.SetSizeRequest(80, -1)
+	r.row.AddSuffix(&r.progressBar.Widget)
+
+	for _, button := range []struct {
+		button  *gtk.Button
+		tooltip string
+		onClick func()
+	}{
+		{
+			r.pauseButton,
+			L("Pause Download"),
+			func() {
+				go d.downloadManager.Pause(key)
+			},
+		},
+		{
+			r.resumeButton,
+			L("Resume Download"),
+			func() {
+				go func() {
+					if err := d.downloadManager.Resume(key); err != nil {
+						runOnMainThread(func() {
+							OpenErrorDialog(d.ctx, &d.ApplicationWindow, err)
+						})
+					}
+				}()
+			},
+		},
+		{
+			r.cancelButton,
+			L("Cancel Download"),
+			func() {
+				go func() {
+					if err := d.downloadManager.Cancel(key); err != nil {
+						runOnMainThread(func() {
+							OpenErrorDialog(d.ctx, &d.ApplicationWindow, err)
+						})
+					}
+				}()
+			},
+		},
+		{
+			r.openButton,
+			L("Open Folder"),
+			func() {
+				if _, err := gio.AppInfoLaunchDefaultForUri(fmt.Sprintf("file://%v", filepath.Dir(status.File)), nil); err != nil {
+					OpenErrorDialog(d.ctx, &d.ApplicationWindow, err)
+				}
+			},
+		},
+	} {
+		onClick := button.onClick
+		onClicked := func(gtk.Button) {
+			onClick()
+		}
+
+		button.button.AddCssClass("flat")
+		button.button.SetValign(gtk.AlignCenterValue)
+		button.button.SetTooltipText(button.tooltip)
+		button.button.ConnectClicked(&onClicked)
+
+		r.row.AddSuffix(&button.button.Widget)
+	}
+
+	d.rows[key] = r
+	d.downloadsGroup.Add(&r.row.PreferencesRow.Widget)
+
+	return r
+}
+
+func (d *DownloadsWindow) refresh(statuses []downloads.Status, peers map[string]int) {
+	keys := map[string]struct{}{}
+	for _, status := range statuses {
+		key := status.Key()
+		keys[key] = struct{}{}
+
+		r, ok := d.rows[key]
+		if !ok {
+			r = d.addRow(status)
+		}
+
+		r.row.SetSubtitle(getDownloadStatusText(status, peers[status.InfoHash]))
+
+		if status.Size > 0 {
+			r.progressBar.SetFraction(float64(status.Written) / float64(status.Size))
+		}
+
+		r.pauseButton.SetVisible(status.State == downloads.StateDownloading)
+		r.resumeButton.SetVisible(status.State == downloads.StatePaused || status.State == downloads.StateFailed)
+		r.openButton.SetVisible(status.State == downloads.StateComplete)
+
+		if status.State == downloads.StateComplete {
+			r.progressBar.SetVisible(false)
+			r.cancelButton.SetIconName("user-trash-symbolic")
+			r.cancelButton.SetTooltipText(L("Remove from List"))
+		}
+	}
+
+	for key, r := range d.rows {
+		if _, ok := keys[key]; !ok {
+			d.downloadsGroup.Remove(&r.row.PreferencesRow.Widget)
+
+			delete(d.rows, key)
+		}
+	}
+
+	if len(d.rows) > 0 {
+		d.stack.SetVisibleChildName(downloadsListPageName)
+	} else {
+		d.stack.SetVisibleChildName(downloadsEmptyPageName)
+	}
+}
+
+func init() {
+	var classInit gobject.ClassInitFunc = func(tc *gobject.TypeClass, u uintptr) {
+		typeClass := (*gtk.WidgetClass)(unsafe.Pointer(tc))
+		typeClass.SetTemplateFromResource(resources.ResourceDownloadsPath)
+
+		typeClass.BindTemplateChildFull("downloads_stack", false, 0)
+		typeClass.BindTemplateChildFull("downloads_group", false, 0)
+
+		objClass := (*gobject.ObjectClass)(unsafe.Pointer(tc))
+
+		objClass.OverrideConstructed(func(o *gobject.Object) {
+			parentObjClass := (*gobject.ObjectClass)(unsafe.Pointer(tc.PeekParent()))
+			parentObjClass.GetConstructed()(o)
+
+			var parent adw.ApplicationWindow
+			o.Cast(&parent)
+
+			parent.InitTemplate()
+
+			var (
+				stack          gtk.Stack
+				downloadsGroup adw.PreferencesGroup
+			)
+			parent.Widget.GetTemplateChild(gTypeDownloadsWindow, "downloads_stack").Cast(&stack)
+			parent.Widget.GetTemplateChild(gTypeDownloadsWindow, "downloads_group").Cast(&downloadsGroup)
+
+			d := &DownloadsWindow{
+				ApplicationWindow: parent,
+				stack:             &stack,
+				downloadsGroup:    &downloadsGroup,
+				rows:              map[string]*downloadRow{},
+			}
+
+			ctrl := gtk.NewEventControllerKey()
+			parent.AddController(&ctrl.EventController)
+
+			onCloseRequest := func(gtk.Window) bool {
+				if d.stopRefreshing != nil {
+					d.stopRefreshing()
+				}
+
+				if openDownloadsWindow == d {
+					openDownloadsWindow = nil
+				}
+
+				return false
+			}
+			parent.ConnectCloseRequest(&onCloseRequest)
+
+			onKeyReleased := func(ctrl gtk.EventControllerKey, keyval, keycode uint32, state gdk.ModifierType) {
+				if keycode == keycodeEscape {
+					parent.Close()
+				}
+			}
+			ctrl.ConnectKeyReleased(&onKeyReleased)
+
+			var pinner runtime.Pinner
+			pinner.Pin(d)
+
+			onCleanup := glib.DestroyNotify(func(data uintptr) {
+				pinner.Unpin()
+			})
+			o.SetDataFull(dataKeyGoInstance, uintptr(unsafe.Pointer(d)), &onCleanup)
+		})
+	}
+
+	var instanceInit gobject.InstanceInitFunc = func(ti *gobject.TypeInstance, tc *gobject.TypeClass) {}
+
+	var parentQuery gobject.TypeQuery
+	gobject.NewTypeQuery(adw.ApplicationWindowGLibType(), &parentQuery)
+
+	gTypeDownloadsWindow = gobject.TypeRegisterStaticSimple(
+		parentQuery.Type,
+		"MultiplexDownloadsWindow",
+		parentQuery.ClassSize,
+		&classInit,
+		parentQuery.InstanceSize,
+		&instanceInit,
+		0,
+	)
+}
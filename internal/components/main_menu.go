@@ -9,8 +9,10 @@ import (
 	"codeberg.org/puregotk/puregotk/v4/gio"
 	"codeberg.org/puregotk/puregotk/v4/gtk"
 	. "github.com/pojntfx/go-gettext/pkg/i18n"
+	"github.com/pojntfx/htorrent/pkg/client"
 	"github.com/pojntfx/htorrent/pkg/server"
 	"github.com/pojntfx/multiplex/assets/resources"
+	"github.com/pojntfx/multiplex/internal/downloads"
 	"github.com/pojntfx/multiplex/internal/utils"
 )
 
@@ -24,6 +26,8 @@ func AddMainMenu(
 	menuButton *gtk.MenuButton,
 	overlay *adw.ToastOverlay,
 	gateway *server.Gateway,
+	manager *client.Manager,
+	downloadManager *downloads.Manager,
 	getMagnetLink func() string,
 	cancel func(),
 ) (*PreferencesDialog, *adw.EntryRow) {
@@ -62,6 +66,13 @@ func AddMainMenu(
 	openDownloadsAction.ConnectActivate(&onOpenDownloads)
 	window.AddAction(openDownloadsAction)
 
+	downloadsAction := gio.NewSimpleAction(downloadsActionName, nil)
+	onDownloads := func(action gio.SimpleAction, parameter uintptr) {
+		PresentDownloadsWindow(ctx, app, manager, downloadManager)
+	}
+	downloadsAction.ConnectActivate(&onDownloads)
+	window.AddAction(downloadsAction)
+
 	if getMagnetLink != nil {
 		copyMagnetLinkAction := gio.NewSimpleAction(copyMagnetLinkActionName, nil)
 		onCopyMagnetLink := func(action gio.SimpleAction, parameter uintptr) {
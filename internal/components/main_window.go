@@ -2,18 +2,16 @@ package components
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
-	"net/http"
+	"maps"
 	"net/url"
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"sort"
 	"strings"
-	"time"
 	"unicode"
 	"unicode/utf8"
 	"unsafe"
@@ -21,24 +19,32 @@ import (
 	. "github.com/pojntfx/go-gettext/pkg/i18n"
 
 	"codeberg.org/puregotk/puregotk/v4/adw"
+	"codeberg.org/puregotk/puregotk/v4/gdk"
 	"codeberg.org/puregotk/puregotk/v4/gio"
 	"codeberg.org/puregotk/puregotk/v4/glib"
 	"codeberg.org/puregotk/puregotk/v4/gobject"
 	"codeberg.org/puregotk/puregotk/v4/gtk"
-	"github.com/anacrolix/torrent"
-	"github.com/mitchellh/mapstructure"
 	"github.com/pojntfx/htorrent/pkg/client"
 	"github.com/pojntfx/htorrent/pkg/server"
 	"github.com/pojntfx/multiplex/assets/resources"
-	api "github.com/pojntfx/multiplex/pkg/api/webrtc/v1"
+	"github.com/pojntfx/multiplex/internal/automation"
+	"github.com/pojntfx/multiplex/internal/downloads"
+	"github.com/pojntfx/multiplex/internal/remote"
+	"github.com/pojntfx/multiplex/internal/session"
+	"github.com/pojntfx/multiplex/internal/store"
 	mpvClient "github.com/pojntfx/multiplex/pkg/client"
-	"github.com/pojntfx/weron/pkg/wrtcconn"
+	"github.com/pojntfx/multiplex/pkg/links"
+	"github.com/pojntfx/multiplex/pkg/sources"
+	"github.com/pojntfx/multiplex/pkg/subtitles"
+	"github.com/pojntfx/multiplex/pkg/torrents"
 	"github.com/rs/zerolog/log"
 	"github.com/rymdport/portal/openuri"
 )
 
 var (
 	gTypeMainWindow gobject.Type
+
+	errMediaNotInTorrent = errors.New("selected media is not part of the torrent")
 )
 
 const (
@@ -49,9 +55,12 @@ const (
 	mpvFlathubURL = "https://flathub.org/apps/details/io.mpv.Mpv"
 	mpvWebsiteURL = "https://mpv.io/installation/"
 
+	maxRecentlyWatched = 5
+
 	preferencesActionName      = "preferences"
 	applyPreferencesActionName = "applypreferences"
 	openDownloadsActionName    = "opendownloads"
+	downloadsActionName        = "downloads"
 	copyMagnetLinkActionName   = "copymagnetlink"
 
 	responseDownloadFlathub     = "download-flathub"
@@ -78,19 +87,31 @@ type MainWindow struct {
 	streamPopover                  *gtk.Popover
 	mediaInfoDisplay               *gtk.Box
 	mediaInfoButton                *gtk.Button
-
-	ctx         context.Context
-	app         *adw.Application
-	manager     *client.Manager
-	apiAddr     string
-	apiUsername string
-	apiPassword string
-	settings    *gio.Settings
-	gateway     *server.Gateway
-	cancel      func()
-	tmpDir      string
-
-	magnetLink           string
+	unfinishedDownloadsGroup       *adw.PreferencesGroup
+	recentlyWatchedGroup           *adw.PreferencesGroup
+	openFileButton                 *gtk.Button
+
+	ctx             context.Context
+	app             *adw.Application
+	manager         *client.Manager
+	downloadManager *downloads.Manager
+	history         *store.JSONStore[WatchedMedia]
+	bookmarksStore  *store.JSONStore[[]Bookmark]
+	delaysStore     *store.JSONStore[MediaDelays]
+	automation      *automation.Server
+	remote          *remote.Server
+	apiAddr         string
+	apiUsername     string
+	apiPassword     string
+	settings        *gio.Settings
+
+	gatewayClient *mpvClient.GatewayClient
+	mediaClient   *mpvClient.GatewayClient
+	gateway       *server.Gateway
+	cancel        func()
+	tmpDir        string
+
+	source               sources.Source
 	torrentTitle         string
 	torrentMedia         []media
 	torrentReadme        string
@@ -99,16 +120,12 @@ type MainWindow struct {
 	activators           []*gtk.CheckButton
 	mediaRows            []*adw.ActionRow
 	subtitles            []mediaWithPriorityAndID
-	community            string
-	password             string
-	key                  string
-	bufferedMessages     []interface{}
-	bufferedPeer         *wrtcconn.Peer
-	bufferedDecoder      *json.Decoder
-	adapter              *wrtcconn.Adapter
-	ids                  chan string
-	adapterCtx           context.Context
-	cancelAdapterCtx     func()
+	session              *session.Session
+	resumeMedia          string
+	unfinishedDownloads  []*adw.ActionRow
+	recentlyWatched      []*adw.ActionRow
+	autoplay             bool
+	autoplayStreamOnly   bool
 
 	descriptionWindow DescriptionWindow
 	warningDialog     WarningDialog
@@ -120,6 +137,12 @@ func NewMainWindow(
 	ctx context.Context,
 	app *adw.Application,
 	manager *client.Manager,
+	downloadManager *downloads.Manager,
+	history *store.JSONStore[WatchedMedia],
+	bookmarksStore *store.JSONStore[[]Bookmark],
+	delaysStore *store.JSONStore[MediaDelays],
+	automation *automation.Server,
+	remote *remote.Server,
 	apiAddr, apiUsername, apiPassword string,
 	settings *gio.Settings,
 	gateway *server.Gateway,
@@ -136,9 +159,17 @@ func NewMainWindow(
 	v.ctx = ctx
 	v.app = app
 	v.manager = manager
+	v.downloadManager = downloadManager
+	v.history = history
+	v.bookmarksStore = bookmarksStore
+	v.delaysStore = delaysStore
+	v.automation = automation
+	v.remote = remote
 	v.apiAddr = apiAddr
 	v.apiUsername = apiUsername
 	v.apiPassword = apiPassword
+	v.gatewayClient = mpvClient.NewGatewayClient(apiUsername, apiPassword, nil)
+	v.mediaClient = mpvClient.NewGatewayClient("", "", nil)
 	v.settings = settings
 	v.gateway = gateway
 	v.cancel = cancel
@@ -156,6 +187,8 @@ func NewMainWindow(
 		v.menuButton,
 		v.overlay,
 		gateway,
+		manager,
+		downloadManager,
 		nil,
 		cancel,
 	)
@@ -163,6 +196,9 @@ func NewMainWindow(
 	v.stack.SetVisibleChildName(welcomePageName)
 	v.app.GetStyleManager().SetColorScheme(adw.ColorSchemeDefaultValue)
 
+	v.refreshUnfinishedDownloads()
+	v.refreshRecentlyWatched()
+
 	return v
 }
 
@@ -181,6 +217,30 @@ func (w *MainWindow) setupSignalHandlers() {
 	}
 	w.magnetLinkEntry.ConnectActivate(&onMagnetLinkEntrySubmit)
 
+	onOpenFile := w.onOpenFile
+	w.openFileButton.ConnectClicked(&onOpenFile)
+
+	// Torrent and media files can also be dropped onto the window
+	dropTarget := gtk.NewDropTarget(gio.FileGLibType(), gdk.ActionCopyValue)
+	onDrop := func(_ gtk.DropTarget, value uintptr, x, y float64) bool {
+		obj := (*gobject.Value)(unsafe.Pointer(value)).GetObject()
+		if obj == nil {
+			return false
+		}
+		defer obj.Unref()
+
+		file := gio.FileBase{Ptr: obj.Ptr}
+
+		path := file.GetPath()
+		if path == "" {
+			return false
+		}
+
+		return w.OpenFile(path)
+	}
+	dropTarget.ConnectDrop(&onDrop)
+	w.ApplicationWindow.AddController(&dropTarget.EventController)
+
 	onNextButton := func(gtk.Button) {
 		w.onNext()
 	}
@@ -221,6 +281,35 @@ func (w *MainWindow) onNext() {
 		go func() {
 			magnetLinkOrStreamCode := w.magnetLinkEntry.GetText()
 			u, err := url.Parse(magnetLinkOrStreamCode)
+
+			// Invite links contain either a stream code or a magnet link and the media to preselect
+			if err == nil && u != nil && u.Scheme == links.Scheme {
+				link, err := links.Parse(magnetLinkOrStreamCode)
+				if err != nil {
+					log.Warn().
+						Str("link", magnetLinkOrStreamCode).
+						Err(err).
+						Msg("Could not parse link")
+
+					toast := adw.NewToast(L("This link is invalid."))
+
+					w.overlay.AddToast(toast)
+
+					return
+				}
+
+				switch link.Action {
+				case links.ActionJoin:
+					magnetLinkOrStreamCode = link.StreamCode
+
+				case links.ActionPlay:
+					magnetLinkOrStreamCode = link.Magnet
+					w.resumeMedia = link.Path
+				}
+
+				u, err = url.Parse(magnetLinkOrStreamCode)
+			}
+
 			if err == nil && u != nil && u.Scheme == "magnet" {
 				w.isNewSession = true
 
@@ -246,6 +335,8 @@ func (w *MainWindow) onNext() {
 
 					w.overlay.AddToast(toast)
 
+					w.autoplay = false
+
 					w.headerbarSpinner.SetVisible(false)
 					w.magnetLinkEntry.SetSensitive(true)
 
@@ -334,6 +425,11 @@ func (w *MainWindow) onNext() {
 					}
 					activator.ConnectActivate(&onActivate)
 
+					if m == w.resumeMedia {
+						activator.SetActive(true)
+						onActivate(*activator)
+					}
+
 					row.SetTitle(getDisplayPathWithoutRoot(file.name))
 					if file.priority == 0 {
 						row.SetSubtitle(fmt.Sprintf(L("Media (%v MB)"), file.size/1000/1000))
@@ -368,7 +464,28 @@ func (w *MainWindow) onNext() {
 
 				w.stack.SetVisibleChildName(mediaPageName)
 
-				w.magnetLink = w.magnetLinkEntry.GetText()
+				requestedMedia := w.resumeMedia
+
+				w.source = sources.NewTorrentSource(magnetLinkOrStreamCode)
+				w.resumeMedia = ""
+
+				if w.autoplay {
+					if w.selectedTorrentMedia == requestedMedia {
+						w.onNext()
+					} else {
+						w.autoplay = false
+
+						toast := adw.NewToast(fmt.Sprintf(L("%v is not part of this torrent."), requestedMedia))
+
+						w.overlay.AddToast(toast)
+					}
+				}
+
+				return
+			}
+
+			if err == nil && u != nil && (u.Scheme == "http" || u.Scheme == "https" || u.Scheme == "file") {
+				w.openMedia(u)
 
 				return
 			}
@@ -380,142 +497,62 @@ func (w *MainWindow) onNext() {
 
 				w.isNewSession = false
 
-				streamCodeParts := strings.Split(magnetLinkOrStreamCode, ":")
-				if len(streamCodeParts) < 3 {
+				w.session, err = session.NewSession(session.NewConfig(w.settings), magnetLinkOrStreamCode)
+				if err != nil {
 					toast := adw.NewToast(L("This stream code is invalid."))
 
 					w.overlay.AddToast(toast)
 
 					return
 				}
-				w.community, w.password, w.key = streamCodeParts[0], streamCodeParts[1], streamCodeParts[2]
 
-				wu, err := url.Parse(w.settings.GetString(resources.SchemaWeronURLKey))
-				if err != nil {
+				w.headerbarSpinner.SetVisible(true)
+				w.magnetLinkEntry.SetSensitive(false)
+
+				if err := w.session.Open(w.ctx); err != nil {
 					OpenErrorDialog(w.ctx, &w.ApplicationWindow, err)
 
 					return
 				}
 
-				w.headerbarSpinner.SetVisible(true)
-				w.magnetLinkEntry.SetSensitive(false)
+				receivedMagnetLink, err := w.session.Join()
+				if err != nil {
+					w.session.Close()
 
-				q := wu.Query()
-				q.Set("community", streamCodeParts[0])
-				q.Set("password", streamCodeParts[1])
-				wu.RawQuery = q.Encode()
-
-				w.adapterCtx, w.cancelAdapterCtx = context.WithCancel(context.Background())
-
-				w.adapter = wrtcconn.NewAdapter(
-					wu.String(),
-					streamCodeParts[2],
-					strings.Split(w.settings.GetString(resources.SchemaWeronICEKey), ","),
-					[]string{"multiplex/sync"},
-					&wrtcconn.AdapterConfig{
-						Timeout:    time.Duration(time.Second * time.Duration(w.settings.GetInt64(resources.SchemaWeronTimeoutKey))),
-						ForceRelay: w.settings.GetBoolean(resources.SchemaWeronForceRelayKey),
-						OnSignalerReconnect: func() {
-							log.Info().
-								Str("raddr", w.settings.GetString(resources.SchemaWeronURLKey)).
-								Msg("Reconnecting to signaler")
-						},
-					},
-					w.adapterCtx,
-				)
+					if errors.Is(err, context.Canceled) || errors.Is(err, session.ErrPeerDisconnected) {
+						log.Debug().
+							Err(err).
+							Msg("Stopped joining session")
 
-				w.ids, err = w.adapter.Open()
-				if err != nil {
-					w.cancelAdapterCtx()
+						return
+					}
 
 					OpenErrorDialog(w.ctx, &w.ApplicationWindow, err)
 
 					return
 				}
 
-				var receivedMagnetLink api.Magnet
-			l:
-				for {
-					select {
-					case <-w.ctx.Done():
-						if err := w.ctx.Err(); err != context.Canceled {
-							OpenErrorDialog(w.ctx, &w.ApplicationWindow, err)
+				w.source, err = sources.FromMessage(*receivedMagnetLink)
+				if err != nil {
+					log.Warn().
+						Str("source", receivedMagnetLink.Source).
+						Err(err).
+						Msg("Could not join session, its media is not supported")
+
+					w.session.Close()
 
-							w.adapter.Close()
-							w.cancelAdapterCtx()
+					toast := adw.NewToast(L("The media of this session can't be played."))
 
-							return
-						}
+					w.overlay.AddToast(toast)
 
-						w.adapter.Close()
-						w.cancelAdapterCtx()
+					w.autoplay = false
 
-						return
-					case rid := <-w.ids:
-						log.Info().
-							Str("raddr", w.settings.GetString(resources.SchemaWeronURLKey)).
-							Str("id", rid).
-							Msg("Reconnecting to signaler")
-					case peer := <-w.adapter.Accept():
-						log.Info().
-							Str("peerID", peer.PeerID).
-							Str("channel", peer.ChannelID).
-							Msg("Connected to peer")
-
-						w.bufferedPeer = peer
-						w.bufferedDecoder = json.NewDecoder(peer.Conn)
-
-						for {
-							var j interface{}
-							if err := w.bufferedDecoder.Decode(&j); err != nil {
-								log.Debug().
-									Err(err).
-									Msg("Could not decode structure, skipping")
-
-								w.adapter.Close()
-								w.cancelAdapterCtx()
-
-								return
-							}
-
-							var message api.Message
-							if err := mapstructure.Decode(j, &message); err != nil {
-								log.Debug().
-									Err(err).
-									Msg("Could not decode message, skipping")
-
-								continue
-							}
-
-							log.Info().Interface("message", message).Msg("Decoded message")
-
-							switch message.Type {
-							case api.TypeMagnet:
-								var m api.Magnet
-								if err := mapstructure.Decode(j, &m); err != nil {
-									log.Debug().
-										Err(err).
-										Msg("Could not decode magnet, skipping")
-
-									continue
-								}
-
-								log.Info().
-									Str("magnet", m.Magnet).
-									Str("path", m.Path).
-									Msg("Got magnet link")
-
-								receivedMagnetLink = m
-
-								break l
-							default:
-								w.bufferedMessages = append(w.bufferedMessages, j)
-							}
-						}
-					}
+					w.headerbarSpinner.SetVisible(false)
+					w.magnetLinkEntry.SetSensitive(true)
+
+					return
 				}
 
-				w.magnetLink = receivedMagnetLink.Magnet
 				w.torrentTitle = receivedMagnetLink.Title
 				w.torrentReadme = receivedMagnetLink.Description
 				w.selectedTorrentMedia = receivedMagnetLink.Path
@@ -552,7 +589,11 @@ func (w *MainWindow) onNext() {
 				w.buttonHeaderbarSubtitle.SetLabel(getDisplayPathWithoutRoot(w.selectedTorrentMedia))
 				w.descriptionWindow.HeaderbarSubtitle().SetLabel(getDisplayPathWithoutRoot(w.selectedTorrentMedia))
 
+				w.refreshDownloadAndPlayButton()
+
 				w.stack.SetVisibleChildName(readyPageName)
+
+				w.playIfRequested()
 			}()
 		}()
 	case mediaPageName:
@@ -563,7 +604,48 @@ func (w *MainWindow) onNext() {
 		w.buttonHeaderbarSubtitle.SetLabel(getDisplayPathWithoutRoot(w.selectedTorrentMedia))
 		w.descriptionWindow.HeaderbarSubtitle().SetLabel(getDisplayPathWithoutRoot(w.selectedTorrentMedia))
 
+		w.refreshDownloadAndPlayButton()
+
 		w.stack.SetVisibleChildName(readyPageName)
+
+		w.playIfRequested()
+	}
+}
+
+// Play starts a session for a magnet link or joins the session of a stream code, and plays its media without
+// asking once it is ready. For magnet links, `path` selects the media; if it is empty, it has to be selected manually.
+func (w *MainWindow) Play(magnetLinkOrStreamCode, path string, streamOnly bool) bool {
+	u, err := url.Parse(magnetLinkOrStreamCode)
+	isMagnet := err == nil && u.Scheme == "magnet"
+
+	w.resumeMedia = path
+	w.autoplay = !isMagnet || path != ""
+	w.autoplayStreamOnly = streamOnly
+
+	if !w.OpenLink(magnetLinkOrStreamCode) {
+		w.resumeMedia = ""
+		w.autoplay = false
+
+		return false
+	}
+
+	return true
+}
+
+// playIfRequested plays the media once the ready page is shown if this was requested with `Play`; requesting
+// it, e.g. on the command line, confirms the right to stream the media
+func (w *MainWindow) playIfRequested() {
+	if !w.autoplay {
+		return
+	}
+	w.autoplay = false
+
+	w.rightsConfirmationButton.SetActive(true)
+
+	if w.autoplayStreamOnly {
+		w.onStreamWithoutDownloading(gtk.Button{})
+	} else {
+		w.onDownloadAndPlay(adw.SplitButton{})
 	}
 }
 
@@ -576,6 +658,9 @@ func (w *MainWindow) onPrevious(gtk.Button) {
 		w.mediaInfoDisplay.SetVisible(true)
 		w.mediaInfoButton.SetVisible(false)
 
+		w.refreshUnfinishedDownloads()
+		w.refreshRecentlyWatched()
+
 		w.stack.SetVisibleChildName(welcomePageName)
 	case readyPageName:
 		w.nextButton.SetVisible(true)
@@ -583,23 +668,12 @@ func (w *MainWindow) onPrevious(gtk.Button) {
 		w.buttonHeaderbarSubtitle.SetVisible(false)
 		w.descriptionWindow.HeaderbarSubtitle().SetVisible(false)
 
-		if !w.isNewSession {
-			if w.adapter != nil {
-				w.adapter.Close()
+		// Only torrents have a media page, so sessions of other sources go back to the start
+		if !w.isNewSession || w.source.Type != sources.TypeTorrent {
+			if w.session != nil {
+				w.session.Close()
 			}
-
-			if w.cancelAdapterCtx != nil {
-				w.cancelAdapterCtx()
-			}
-
-			w.adapter = nil
-			w.ids = nil
-			w.adapterCtx = nil
-			w.cancelAdapterCtx = nil
-
-			w.community = ""
-			w.password = ""
-			w.key = ""
+			w.session = nil
 
 			w.previousButton.SetVisible(false)
 			w.nextButton.SetSensitive(true)
@@ -607,6 +681,9 @@ func (w *MainWindow) onPrevious(gtk.Button) {
 			w.mediaInfoDisplay.SetVisible(true)
 			w.mediaInfoButton.SetVisible(false)
 
+			w.refreshUnfinishedDownloads()
+			w.refreshRecentlyWatched()
+
 			w.stack.SetVisibleChildName(welcomePageName)
 
 			return
@@ -617,24 +694,23 @@ func (w *MainWindow) onPrevious(gtk.Button) {
 }
 
 func (w *MainWindow) onDownloadAndPlay(adw.SplitButton) {
-	w.ApplicationWindow.Close()
-	w.refreshSubtitles()
-
-	streamURL, err := getStreamURL(w.apiAddr, w.magnetLink, w.selectedTorrentMedia)
-	if err != nil {
-		OpenErrorDialog(w.ctx, &w.ApplicationWindow, err)
+	// Only torrents are downloaded, other sources are always played directly
+	if w.source.Type != sources.TypeTorrent {
+		w.onPlay()
 
 		return
 	}
 
-	selectedTorrent, err := torrent.TorrentSpecFromMagnetUri(w.magnetLink)
+	w.refreshSubtitles()
+
+	infoHash, err := w.source.ID()
 	if err != nil {
 		OpenErrorDialog(w.ctx, &w.ApplicationWindow, err)
 
 		return
 	}
 
-	dstFile := filepath.Join(w.settings.GetString(resources.SchemaStorageKey), L("Manual Downloads"), selectedTorrent.InfoHash.HexString(), w.selectedTorrentMedia)
+	dstFile := filepath.Join(w.settings.GetString(resources.SchemaStorageKey), L("Manual Downloads"), infoHash, w.selectedTorrentMedia)
 
 	if err := os.MkdirAll(filepath.Dir(dstFile), os.ModePerm); err != nil {
 		OpenErrorDialog(w.ctx, &w.ApplicationWindow, err)
@@ -644,87 +720,563 @@ func (w *MainWindow) onDownloadAndPlay(adw.SplitButton) {
 
 	ctxDownload, cancel := context.WithCancel(context.Background())
 	ready := make(chan struct{})
-	if _, err := NewControlsWindow(w.ctx, w.app, w.torrentTitle, w.subtitles, w.selectedTorrentMedia, w.torrentReadme, w.manager, w.apiAddr, w.apiUsername, w.apiPassword, w.magnetLink, dstFile, w.settings, w.gateway, w.cancel, w.tmpDir, ready, cancel, w.adapter, w.ids, w.adapterCtx, w.cancelAdapterCtx, w.community, w.password, w.key, w.bufferedMessages, w.bufferedPeer, w.bufferedDecoder); err != nil {
+
+	key := store.MediaKey(infoHash, w.selectedTorrentMedia)
+
+	// Stopping playback before the download is complete pauses the download, so that it can be resumed later
+	cancelDownload := func() {
+		cancel()
+
+		go w.downloadManager.Pause(key)
+	}
+
+	controlsW, err := NewControlsWindow(w.ctx, w.app, w.torrentTitle, w.subtitles, w.selectedTorrentMedia, w.torrentReadme, w.manager, w.downloadManager, w.history, w.bookmarksStore, w.delaysStore, w.automation, w.remote, w.apiAddr, w.apiUsername, w.apiPassword, w.source, dstFile, w.settings, w.gateway, w.cancel, w.tmpDir, ready, cancelDownload, w.session)
+	if err != nil {
 		OpenErrorDialog(w.ctx, &w.ApplicationWindow, err)
 
 		return
 	}
 
+	// The main window is only closed once the controls window is open, so that errors
+	// are always shown on a window which is still open
+	w.ApplicationWindow.Close()
+
 	go func() {
-		log.Info().
-			Str("streamURL", streamURL).
-			Msg("Downloading media")
+		// The expected size is taken from the torrent's metadata so that truncated downloads are never played
+		info, err := w.manager.GetInfo(w.source.Magnet)
+		if err != nil {
+			OpenErrorDialog(w.ctx, &controlsW.ApplicationWindow, err)
 
-		hc := &http.Client{}
+			return
+		}
 
-		req, err := http.NewRequest(http.MethodGet, streamURL, http.NoBody)
-		if err != nil {
-			if err == context.Canceled {
-				return
+		manifest := downloads.Manifest{
+			MagnetLink: w.source.Magnet,
+			InfoHash:   infoHash,
+			Title:      w.torrentTitle,
+			Path:       w.selectedTorrentMedia,
+			File:       dstFile,
+			Size:       -1,
+		}
+		for _, file := range info.Files {
+			if file.Path == w.selectedTorrentMedia {
+				manifest.Size = file.Length
+
+				break
 			}
+		}
 
-			OpenErrorDialog(w.ctx, &w.ApplicationWindow, err)
+		if manifest.Size < 0 {
+			OpenErrorDialog(w.ctx, &controlsW.ApplicationWindow, errMediaNotInTorrent)
 
 			return
 		}
-		req.SetBasicAuth(w.apiUsername, w.apiPassword)
 
-		res, err := hc.Do(req.WithContext(ctxDownload))
-		if err != nil {
-			if err == context.Canceled {
+		done := w.downloadManager.Done(key)
+		if err := w.downloadManager.Start(manifest); err != nil {
+			OpenErrorDialog(w.ctx, &controlsW.ApplicationWindow, err)
+
+			return
+		}
+
+		select {
+		case <-ctxDownload.Done():
+			return
+		case err := <-done:
+			if err != nil {
+				// The download can be retried from the downloads window, which also shows why it failed
+				sourceFn := glib.SourceFunc(func(uintptr) bool {
+					PresentDownloadsWindow(w.ctx, w.app, w.manager, w.downloadManager)
+
+					controlsW.ApplicationWindow.Close()
+
+					return false
+				})
+				glib.IdleAdd(&sourceFn, 0)
+
 				return
 			}
+		}
 
-			OpenErrorDialog(w.ctx, &w.ApplicationWindow, err)
+		close(ready)
+	}()
+}
 
-			return
+// refreshDownloadAndPlayButton offers to resume the download of the selected media if it has been started before
+func (w *MainWindow) refreshDownloadAndPlayButton() {
+	switch w.source.Type {
+	case sources.TypeURL:
+		w.downloadAndPlayButton.SetLabel(L("Play"))
+
+		return
+
+	case sources.TypeFile:
+		if w.source.File == "" {
+			w.downloadAndPlayButton.SetLabel(L("Select Local Copy and Play"))
+		} else {
+			w.downloadAndPlayButton.SetLabel(L("Play"))
 		}
-		if res.Body != nil {
-			defer res.Body.Close()
+
+		return
+	}
+
+	w.downloadAndPlayButton.SetLabel(L("Download and Play"))
+
+	infoHash, err := w.source.ID()
+	if err != nil {
+		return
+	}
+
+	manifest, ok := w.downloadManager.Get(store.MediaKey(infoHash, w.selectedTorrentMedia))
+	if ok && !manifest.Complete && manifest.Progress() > 0 {
+		w.downloadAndPlayButton.SetLabel(L("Resume Download"))
+	}
+}
+
+// refreshUnfinishedDownloads lists the downloads which were stopped before they were complete on the welcome page
+func (w *MainWindow) refreshUnfinishedDownloads() {
+	for _, row := range w.unfinishedDownloads {
+		w.unfinishedDownloadsGroup.Remove(&row.PreferencesRow.Widget)
+	}
+	w.unfinishedDownloads = []*adw.ActionRow{}
+
+	for _, status := range w.downloadManager.List() {
+		if status.State == downloads.StateComplete {
+			continue
 		}
-		if res.StatusCode != http.StatusOK {
-			if err == context.Canceled {
-				return
-			}
 
-			OpenErrorDialog(w.ctx, &w.ApplicationWindow, errors.New(res.Status))
+		manifest := status.Manifest
+		key := manifest.Key()
 
-			return
+		row := adw.NewActionRow()
+
+		row.SetUseMarkup(false)
+		row.SetTitle(getDisplayPathWithoutRoot(manifest.Path))
+		row.SetSubtitle(fmt.Sprintf(L("%v (%v of %v MB)"), manifest.Title, status.Written/1000/1000, manifest.Size/1000/1000))
+
+		removeButton := gtk.NewButtonFromIconName("user-trash-symbolic")
+		removeButton.AddCssClass("flat")
+		removeButton.SetValign(gtk.AlignCenterValue)
+		removeButton.SetTooltipText(L("Remove Download"))
+
+		onRemove := func(gtk.Button) {
+			go func() {
+				err := w.downloadManager.Cancel(key)
+
+				runOnMainThread(func() {
+					if err != nil {
+						OpenErrorDialog(w.ctx, &w.ApplicationWindow, err)
+
+						return
+					}
+
+					w.refreshUnfinishedDownloads()
+				})
+			}()
 		}
+		removeButton.ConnectClicked(&onRemove)
 
-		f, err := os.Create(dstFile)
-		if err != nil {
-			if err == context.Canceled {
-				return
-			}
+		resumeButton := gtk.NewButtonFromIconName("media-playback-start-symbolic")
+		resumeButton.AddCssClass("flat")
+		resumeButton.SetValign(gtk.AlignCenterValue)
+		resumeButton.SetTooltipText(L("Resume Download"))
 
-			OpenErrorDialog(w.ctx, &w.ApplicationWindow, err)
+		onResume := func(gtk.Button) {
+			w.resumeMedia = manifest.Path
+			w.magnetLinkEntry.SetText(manifest.MagnetLink)
 
-			return
+			w.onNext()
 		}
-		defer f.Close()
+		resumeButton.ConnectClicked(&onResume)
+
+		row.AddSuffix(&removeButton.Widget)
+		row.AddSuffix(&resumeButton.Widget)
+
+		w.unfinishedDownloads = append(w.unfinishedDownloads, row)
+		w.unfinishedDownloadsGroup.Add(&row.PreferencesRow.Widget)
+	}
+
+	w.unfinishedDownloadsGroup.SetVisible(len(w.unfinishedDownloads) > 0)
+}
+
+// refreshRecentlyWatched lists the media which was played last on the welcome page so that it can be continued
+func (w *MainWindow) refreshRecentlyWatched() {
+	for _, row := range w.recentlyWatched {
+		w.recentlyWatchedGroup.Remove(&row.PreferencesRow.Widget)
+	}
+	w.recentlyWatched = []*adw.ActionRow{}
+
+	entries := w.history.Entries()
+	keys := slices.SortedFunc(maps.Keys(entries), func(a, b string) int {
+		return entries[b].WatchedAt.Compare(entries[a].WatchedAt)
+	})
+
+	for _, key := range keys[:min(len(keys), maxRecentlyWatched)] {
+		entry := entries[key]
+
+		row := adw.NewActionRow()
+
+		row.SetUseMarkup(false)
+		row.SetTitle(getDisplayPathWithoutRoot(entry.Path))
+		if entry.resumable() {
+			row.SetSubtitle(fmt.Sprintf(L("%v (%v of %v)"), entry.Title, formatDuration(entry.position()), formatDuration(entry.duration())))
+		} else {
+			row.SetSubtitle(fmt.Sprintf(L("%v (watched)"), entry.Title))
+		}
+
+		removeButton := gtk.NewButtonFromIconName("user-trash-symbolic")
+		removeButton.AddCssClass("flat")
+		removeButton.SetValign(gtk.AlignCenterValue)
+		removeButton.SetTooltipText(L("Remove from History"))
+
+		onRemove := func(gtk.Button) {
+			if err := w.history.Delete(key); err != nil {
+				OpenErrorDialog(w.ctx, &w.ApplicationWindow, err)
 
-		if _, err := io.Copy(f, res.Body); err != nil {
-			if err == context.Canceled {
 				return
 			}
 
-			OpenErrorDialog(w.ctx, &w.ApplicationWindow, err)
+			w.refreshRecentlyWatched()
+		}
+		removeButton.ConnectClicked(&onRemove)
+
+		playButton := gtk.NewButtonFromIconName("media-playback-start-symbolic")
+		playButton.AddCssClass("flat")
+		playButton.SetValign(gtk.AlignCenterValue)
+		playButton.SetTooltipText(L("Continue Watching"))
+
+		onPlay := func(gtk.Button) {
+			switch entry.Type {
+			case sources.TypeTorrent:
+				w.resumeMedia = entry.Path
+				w.magnetLinkEntry.SetText(entry.Link)
+
+				w.onNext()
+			case sources.TypeFile:
+				w.OpenMediaFile(entry.Link)
+			default:
+				w.OpenLink(entry.Link)
+			}
+		}
+		playButton.ConnectClicked(&onPlay)
 
-			return
+		row.AddSuffix(&removeButton.Widget)
+		row.AddSuffix(&playButton.Widget)
+
+		w.recentlyWatched = append(w.recentlyWatched, row)
+		w.recentlyWatchedGroup.Add(&row.PreferencesRow.Widget)
+	}
+
+	w.recentlyWatchedGroup.SetVisible(len(w.recentlyWatched) > 0)
+}
+
+func (w *MainWindow) onOpenFile(gtk.Button) {
+	filePicker := gtk.NewFileChooserNative(
+		L("Select torrent or media file"),
+		&w.ApplicationWindow.Window,
+		gtk.FileChooserActionOpenValue,
+		"",
+		"")
+	filePicker.SetModal(true)
+
+	filter := gtk.NewFileFilter()
+	filter.SetName(L("Torrent and media files"))
+	filter.AddMimeType("application/x-bittorrent")
+	filter.AddPattern("*.torrent")
+	filter.AddMimeType("video/*")
+	filter.AddMimeType("audio/*")
+	filePicker.AddFilter(filter)
+
+	onFilePickerResponse := func(dialog gtk.NativeDialog, responseId int32) {
+		if responseId == int32(gtk.ResponseAcceptValue) {
+			w.OpenFile(filePicker.GetFile().GetPath())
 		}
 
-		close(ready)
+		filePicker.Destroy()
+	}
+	filePicker.ConnectResponse(&onFilePickerResponse)
+
+	filePicker.Show()
+}
+
+// OpenFile starts a new session for a torrent file or a local media file. It returns false if the file
+// could not be opened.
+func (w *MainWindow) OpenFile(path string) bool {
+	if strings.EqualFold(filepath.Ext(path), ".torrent") {
+		return w.OpenTorrentFile(path)
+	}
+
+	return w.OpenMediaFile(path)
+}
+
+// OpenTorrentFile starts a new session for a torrent file; the gateway is given the magnet link derived
+// from the file, which is also the magnet link that is shared with peers. It returns false if the file
+// could not be opened.
+func (w *MainWindow) OpenTorrentFile(path string) bool {
+	if w.stack.GetVisibleChildName() != welcomePageName || !w.magnetLinkEntry.GetSensitive() {
+		toast := adw.NewToast(L("Go back to the start to open another torrent file."))
+
+		w.overlay.AddToast(toast)
+
+		return false
+	}
+
+	log.Info().
+		Str("path", path).
+		Msg("Opening torrent file")
+
+	t, err := openTorrentFile(path)
+	if err != nil {
+		log.Warn().
+			Str("path", path).
+			Err(err).
+			Msg("Could not open torrent file")
+
+		toast := adw.NewToast(fmt.Sprintf(L("Could not open torrent file: %v"), err))
+
+		w.overlay.AddToast(toast)
+
+		return false
+	}
+
+	log.Info().
+		Str("path", path).
+		Str("infoHash", t.InfoHash).
+		Strs("trackers", t.Trackers).
+		Msg("Opened torrent file")
+
+	w.magnetLinkEntry.SetText(t.Magnet)
+	w.onNext()
+
+	return true
+}
+
+// OpenLink starts a new session or joins one for a `multiplex://` invite link, a magnet link or a URL.
+// It returns false if the link could not be opened.
+func (w *MainWindow) OpenLink(link string) bool {
+	if w.stack.GetVisibleChildName() != welcomePageName || !w.magnetLinkEntry.GetSensitive() {
+		toast := adw.NewToast(L("Go back to the start to open another link."))
+
+		w.overlay.AddToast(toast)
+
+		return false
+	}
+
+	log.Info().
+		Str("link", link).
+		Msg("Opening link")
+
+	w.magnetLinkEntry.SetText(link)
+	w.onNext()
+
+	return true
+}
+
+// OpenMediaFile starts a new session for a local media file, which peers play from their own copy of it
+func (w *MainWindow) OpenMediaFile(path string) bool {
+	if w.stack.GetVisibleChildName() != welcomePageName || !w.magnetLinkEntry.GetSensitive() {
+		toast := adw.NewToast(L("Go back to the start to open another file."))
+
+		w.overlay.AddToast(toast)
+
+		return false
+	}
+
+	w.magnetLinkEntry.SetText((&url.URL{Scheme: "file", Path: path}).String())
+	w.onNext()
+
+	return true
+}
+
+// openMedia starts a new session for a plain HTTP(S) URL or a local file; unlike torrents, they contain a single
+// media file, so the media page is skipped
+func (w *MainWindow) openMedia(u *url.URL) {
+	// Hashing media reads from the server or disk, so it happens in the background and the UI is only
+	// updated on the main thread
+	startFn := glib.SourceFunc(func(uintptr) bool {
+		w.isNewSession = true
+
+		w.headerbarSpinner.SetVisible(true)
+		w.magnetLinkEntry.SetSensitive(false)
+
+		return false
+	})
+	glib.IdleAdd(&startFn, 0)
+
+	go func() {
+		log.Info().
+			Str("url", u.String()).
+			Msg("Opening media")
+
+		var (
+			source sources.Source
+			err    error
+		)
+		if u.Scheme == "file" {
+			source, err = sources.NewFileSource(u.Path)
+		} else {
+			source, err = sources.NewURLSource(w.ctx, w.mediaClient, u.String())
+		}
+
+		sourceFn := glib.SourceFunc(func(uintptr) bool {
+			w.headerbarSpinner.SetVisible(false)
+			w.magnetLinkEntry.SetSensitive(true)
+
+			if err != nil {
+				log.Warn().
+					Str("url", u.String()).
+					Err(err).
+					Msg("Could not open media")
+
+				toast := adw.NewToast(fmt.Sprintf(L("Could not open this media: %v"), err))
+
+				w.overlay.AddToast(toast)
+
+				w.magnetLinkEntry.GrabFocus()
+
+				return false
+			}
+
+			log.Info().
+				Str("url", u.String()).
+				Str("hash", source.Hash).
+				Int64("size", source.Size).
+				Msg("Opened media")
+
+			w.source = source
+			w.torrentTitle = source.Name()
+			w.torrentReadme = ""
+			w.torrentMedia = []media{}
+			w.selectedTorrentMedia = source.Name()
+			w.resumeMedia = ""
+
+			w.previousButton.SetVisible(true)
+
+			w.buttonHeaderbarTitle.SetLabel(w.torrentTitle)
+			w.descriptionWindow.HeaderbarTitle().SetLabel(w.torrentTitle)
+
+			w.mediaInfoDisplay.SetVisible(false)
+			w.mediaInfoButton.SetVisible(true)
+
+			w.descriptionWindow.Text().SetWrapMode(gtk.WrapWordValue)
+			w.descriptionWindow.Text().GetBuffer().SetText(L(readmePlaceholder), -1)
+
+			w.rightsConfirmationButton.SetActive(false)
+
+			w.nextButton.SetVisible(false)
+
+			w.buttonHeaderbarSubtitle.SetVisible(true)
+			w.descriptionWindow.HeaderbarSubtitle().SetVisible(true)
+			w.buttonHeaderbarSubtitle.SetLabel(w.selectedTorrentMedia)
+			w.descriptionWindow.HeaderbarSubtitle().SetLabel(w.selectedTorrentMedia)
+
+			w.refreshDownloadAndPlayButton()
+
+			w.stack.SetVisibleChildName(readyPageName)
+
+			return false
+		})
+		glib.IdleAdd(&sourceFn, 0)
 	}()
 }
 
+func openTorrentFile(path string) (*torrents.Torrent, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return torrents.Load(f)
+}
+
 func (w *MainWindow) onStreamWithoutDownloading(gtk.Button) {
 	w.streamPopover.SetVisible(false)
 
+	if w.source.Type != sources.TypeTorrent {
+		w.onPlay()
+
+		return
+	}
+
+	w.stream()
+}
+
+// onPlay plays media which isn't a torrent; peers which join a session of a local file select their own copy of it first
+func (w *MainWindow) onPlay() {
+	if w.source.Type != sources.TypeFile || w.source.File != "" {
+		w.verifyAndStream(w.source)
+
+		return
+	}
+
+	filePicker := gtk.NewFileChooserNative(
+		fmt.Sprintf(L("Select your copy of %v"), w.selectedTorrentMedia),
+		&w.ApplicationWindow.Window,
+		gtk.FileChooserActionOpenValue,
+		"",
+		"")
+	filePicker.SetModal(true)
+
+	onFilePickerResponse := func(dialog gtk.NativeDialog, responseId int32) {
+		if responseId == int32(gtk.ResponseAcceptValue) {
+			w.verifyAndStream(w.source.WithFile(filePicker.GetFile().GetPath()))
+		}
+
+		filePicker.Destroy()
+	}
+	filePicker.ConnectResponse(&onFilePickerResponse)
+
+	filePicker.Show()
+}
+
+// verifyAndStream checks that the media of a session which has been joined is the same as the media of the
+// peer which started it before playing it
+func (w *MainWindow) verifyAndStream(source sources.Source) {
+	if w.isNewSession {
+		w.source = source
+		w.stream()
+
+		return
+	}
+
+	w.headerbarSpinner.SetVisible(true)
+	w.downloadAndPlayButton.SetSensitive(false)
+
+	go func() {
+		err := source.Verify(w.ctx, w.mediaClient)
+
+		w.headerbarSpinner.SetVisible(false)
+		w.downloadAndPlayButton.SetSensitive(w.rightsConfirmationButton.GetActive())
+
+		if err != nil {
+			log.Warn().
+				Str("hash", source.Hash).
+				Err(err).
+				Msg("Could not verify media")
+
+			message := fmt.Sprintf(L("Could not verify this media: %v"), err)
+			if errors.Is(err, sources.ErrHashMismatch) {
+				message = L("This file is not the same as the media of the session.")
+			}
+
+			toast := adw.NewToast(message)
+
+			w.overlay.AddToast(toast)
+
+			return
+		}
+
+		w.source = source
+		w.stream()
+	}()
+}
+
+func (w *MainWindow) stream() {
 	w.ApplicationWindow.Close()
 	w.refreshSubtitles()
 
-	streamURL, err := getStreamURL(w.apiAddr, w.magnetLink, w.selectedTorrentMedia)
+	streamURL, err := w.source.StreamURL(w.apiAddr, w.selectedTorrentMedia)
 	if err != nil {
 		OpenErrorDialog(w.ctx, &w.ApplicationWindow, err)
 
@@ -732,7 +1284,7 @@ func (w *MainWindow) onStreamWithoutDownloading(gtk.Button) {
 	}
 
 	ready := make(chan struct{})
-	if _, err := NewControlsWindow(w.ctx, w.app, w.torrentTitle, w.subtitles, w.selectedTorrentMedia, w.torrentReadme, w.manager, w.apiAddr, w.apiUsername, w.apiPassword, w.magnetLink, streamURL, w.settings, w.gateway, w.cancel, w.tmpDir, ready, func() {}, w.adapter, w.ids, w.adapterCtx, w.cancelAdapterCtx, w.community, w.password, w.key, w.bufferedMessages, w.bufferedPeer, w.bufferedDecoder); err != nil {
+	if _, err := NewControlsWindow(w.ctx, w.app, w.torrentTitle, w.subtitles, w.selectedTorrentMedia, w.torrentReadme, w.manager, w.downloadManager, w.history, w.bookmarksStore, w.delaysStore, w.automation, w.remote, w.apiAddr, w.apiUsername, w.apiPassword, w.source, streamURL, w.settings, w.gateway, w.cancel, w.tmpDir, ready, func() {}, w.session); err != nil {
 		OpenErrorDialog(w.ctx, &w.ApplicationWindow, err)
 
 		return
@@ -767,21 +1319,54 @@ func (w *MainWindow) onWarningDialogResponse(response string) {
 }
 
 func (w *MainWindow) onShow(gtk.Widget) {
+	var (
+		mpvInfo *mpvClient.MPVInfo
+		err     error
+	)
 	if oldMPVCommand := w.settings.GetString(resources.SchemaMPVKey); strings.TrimSpace(oldMPVCommand) == "" {
-		newMPVCommand, err := mpvClient.DiscoverMPVExecutable()
-		if err != nil {
-			if runtime.GOOS == "linux" {
-				w.warningDialog.SetResponseEnabled(responseDownloadFlathub, true)
-				w.warningDialog.SetDefaultResponse(responseDownloadFlathub)
+		mpvInfo, err = mpvClient.DiscoverMPVExecutable()
+		// Executables which lack required features are not saved so that discovery runs again once mpv has been updated
+		if err == nil {
+			w.settings.SetString(resources.SchemaMPVKey, mpvInfo.Command)
+			w.settings.Apply()
+		}
+	} else {
+		mpvInfo, err = mpvClient.ProbeMPVExecutable(oldMPVCommand)
+		if err != nil && !errors.Is(err, mpvClient.ErrMPVExecutableMissingFeatures) {
+			log.Warn().
+				Str("command", oldMPVCommand).
+				Err(err).
+				Msg("Could not probe configured mpv command")
+
+			w.warningDialog.SetBody(fmt.Sprintf(L("The configured command %v could not be run. Please install mpv or configure the existing installation to be able to play media."), oldMPVCommand))
+		}
+	}
+
+	if err != nil {
+		if errors.Is(err, mpvClient.ErrMPVExecutableMissingFeatures) {
+			log.Warn().
+				Str("command", mpvInfo.Command).
+				Str("version", mpvInfo.Version).
+				Strs("missing", mpvInfo.Missing).
+				Msg("mpv is missing required features")
+
+			version := mpvInfo.Version
+			if version == "" {
+				version = L("unknown")
 			}
 
-			w.warningDialog.Present(&w.ApplicationWindow.Window.Widget)
+			w.warningDialog.SetHeading(L("Media Player Is Too Old"))
+			w.warningDialog.SetBody(fmt.Sprintf(L("The installed version of mpv (%v) does not support the following features: %v. Please update mpv or configure a different installation to be able to play media."), version, strings.Join(mpvInfo.Missing, ", ")))
+		}
 
-			return
+		if runtime.GOOS == "linux" {
+			w.warningDialog.SetResponseEnabled(responseDownloadFlathub, true)
+			w.warningDialog.SetDefaultResponse(responseDownloadFlathub)
 		}
 
-		w.settings.SetString(resources.SchemaMPVKey, newMPVCommand)
-		w.settings.Apply()
+		w.warningDialog.Present(&w.ApplicationWindow.Window.Widget)
+
+		return
 	}
 
 	w.magnetLinkEntry.GrabFocus()
@@ -792,7 +1377,7 @@ func (w *MainWindow) refreshSubtitles() {
 	w.subtitles = []mediaWithPriorityAndID{}
 	for _, media := range w.torrentMedia {
 		if media.name != w.selectedTorrentMedia {
-			if strings.HasSuffix(media.name, ".srt") || strings.HasSuffix(media.name, ".vtt") || strings.HasSuffix(media.name, ".ass") {
+			if strings.HasSuffix(media.name, ".srt") || strings.HasSuffix(media.name, ".vtt") || strings.HasSuffix(media.name, ".ass") || (subtitles.IsArchive(media.name) && media.size <= subtitles.MaxArchiveSize) {
 				w.subtitles = append(w.subtitles, mediaWithPriorityAndID{
 					media:    media,
 					priority: 1,
@@ -828,6 +1413,9 @@ func init() {
 		typeClass.BindTemplateChildFull("stream_popover", false, 0)
 		typeClass.BindTemplateChildFull("media_info_display", false, 0)
 		typeClass.BindTemplateChildFull("media_info_button", false, 0)
+		typeClass.BindTemplateChildFull("unfinished_downloads_group", false, 0)
+		typeClass.BindTemplateChildFull("recently_watched_group", false, 0)
+		typeClass.BindTemplateChildFull("open_file_button", false, 0)
 
 		objClass := (*gobject.ObjectClass)(unsafe.Pointer(tc))
 
@@ -857,6 +1445,9 @@ func init() {
 				streamPopover                  gtk.Popover
 				mediaInfoDisplay               gtk.Box
 				mediaInfoButton                gtk.Button
+				unfinishedDownloadsGroup       adw.PreferencesGroup
+				recentlyWatchedGroup           adw.PreferencesGroup
+				openFileButton                 gtk.Button
 			)
 			parent.Widget.GetTemplateChild(gTypeMainWindow, "toast_overlay").Cast(&overlay)
 			parent.Widget.GetTemplateChild(gTypeMainWindow, "button_headerbar_title").Cast(&buttonHeaderbarTitle)
@@ -874,6 +1465,9 @@ func init() {
 			parent.Widget.GetTemplateChild(gTypeMainWindow, "stream_popover").Cast(&streamPopover)
 			parent.Widget.GetTemplateChild(gTypeMainWindow, "media_info_display").Cast(&mediaInfoDisplay)
 			parent.Widget.GetTemplateChild(gTypeMainWindow, "media_info_button").Cast(&mediaInfoButton)
+			parent.Widget.GetTemplateChild(gTypeMainWindow, "unfinished_downloads_group").Cast(&unfinishedDownloadsGroup)
+			parent.Widget.GetTemplateChild(gTypeMainWindow, "recently_watched_group").Cast(&recentlyWatchedGroup)
+			parent.Widget.GetTemplateChild(gTypeMainWindow, "open_file_button").Cast(&openFileButton)
 
 			w := &MainWindow{
 				ApplicationWindow: parent,
@@ -894,11 +1488,13 @@ func init() {
 				streamPopover:                  &streamPopover,
 				mediaInfoDisplay:               &mediaInfoDisplay,
 				mediaInfoButton:                &mediaInfoButton,
+				unfinishedDownloadsGroup:       &unfinishedDownloadsGroup,
+				recentlyWatchedGroup:           &recentlyWatchedGroup,
+				openFileButton:                 &openFileButton,
 				isNewSession:                   true,
 				activators:                     []*gtk.CheckButton{},
 				mediaRows:                      []*adw.ActionRow{},
 				subtitles:                      []mediaWithPriorityAndID{},
-				bufferedMessages:               []interface{}{},
 			}
 
 			var pinner runtime.Pinner
@@ -3,7 +3,10 @@ package components
 import (
 	"context"
 	"math"
+	"net"
 	"runtime"
+	"strconv"
+	"strings"
 	"unsafe"
 
 	. "github.com/pojntfx/go-gettext/pkg/i18n"
@@ -15,6 +18,7 @@ import (
 	"codeberg.org/puregotk/puregotk/v4/gtk"
 	"github.com/pojntfx/htorrent/pkg/server"
 	"github.com/pojntfx/multiplex/assets/resources"
+	"github.com/pojntfx/multiplex/internal/remote"
 )
 
 var (
@@ -26,6 +30,16 @@ type PreferencesDialog struct {
 
 	storageLocationInput       *gtk.Button
 	mpvCommandInput            *adw.EntryRow
+	audioLanguagesInput        *adw.EntryRow
+	subtitleLanguagesInput     *adw.EntryRow
+	subtitleProviderURLInput   *adw.EntryRow
+	subtitleProviderKeyInput   *adw.PasswordEntryRow
+	remoteControlSwitchInput   *gtk.Switch
+	remoteControlLANInput      *gtk.Switch
+	remoteControlPortInput     *adw.SpinRow
+	remoteControlTokenInput    *adw.PasswordEntryRow
+	remoteControlURLRow        *adw.ActionRow
+	remoteControlURLCopyButton *gtk.Button
 	verbosityLevelInput        *adw.SpinRow
 	remoteGatewaySwitchInput   *gtk.Switch
 	remoteGatewayURLInput      *adw.EntryRow
@@ -98,6 +112,17 @@ func (p *PreferencesDialog) havePreferencesChanged() bool {
 func (p *PreferencesDialog) setupBindings() {
 	p.settings.Bind(resources.SchemaMPVKey, &p.mpvCommandInput.Object, "text", gio.GSettingsBindDefaultValue)
 
+	p.settings.Bind(resources.SchemaSubtitleProviderURLKey, &p.subtitleProviderURLInput.Object, "text", gio.GSettingsBindDefaultValue)
+	p.settings.Bind(resources.SchemaSubtitleProviderKeyKey, &p.subtitleProviderKeyInput.Object, "text", gio.GSettingsBindDefaultValue)
+
+	p.settings.Bind(resources.SchemaRemoteControlKey, &p.remoteControlSwitchInput.Object, "active", gio.GSettingsBindDefaultValue)
+	p.settings.Bind(resources.SchemaRemoteControlLANKey, &p.remoteControlLANInput.Object, "active", gio.GSettingsBindDefaultValue)
+
+	p.remoteControlPortInput.SetAdjustment(gtk.NewAdjustment(0, 1, math.MaxUint16, 1, 1, 1))
+	p.settings.Bind(resources.SchemaRemoteControlPortKey, &p.remoteControlPortInput.Object, "value", gio.GSettingsBindDefaultValue)
+
+	p.settings.Bind(resources.SchemaRemoteControlTokenKey, &p.remoteControlTokenInput.Object, "text", gio.GSettingsBindDefaultValue)
+
 	p.verbosityLevelInput.SetAdjustment(gtk.NewAdjustment(0, 0, 8, 1, 1, 1))
 	p.settings.Bind(resources.SchemaVerboseKey, &p.verbosityLevelInput.Object, "value", gio.GSettingsBindDefaultValue)
 
@@ -128,6 +153,36 @@ func (p *PreferencesDialog) setupCallbacks() {
 			p.remoteGatewayUsernameInput.SetEditable(false)
 			p.remoteGatewayPasswordInput.SetEditable(false)
 		}
+
+		remoteControl := p.remoteControlSwitchInput.GetActive()
+		p.remoteControlLANInput.SetSensitive(remoteControl)
+		p.remoteControlPortInput.SetSensitive(remoteControl)
+		p.remoteControlTokenInput.SetSensitive(remoteControl)
+		p.remoteControlURLRow.SetSensitive(remoteControl)
+	}
+
+	// The address is derived from the other web remote preferences, so it is updated whenever they change
+	remoteControlURL := ""
+	syncRemoteControlURL := func() {
+		host := "localhost"
+		if p.remoteControlLANInput.GetActive() {
+			if host = remote.LANHost(); host == "" {
+				host = "localhost"
+			}
+		}
+
+		// The token is created when the web remote is started
+		remoteControlURL = ""
+		if token := p.remoteControlTokenInput.GetText(); strings.TrimSpace(token) != "" {
+			remoteControlURL = remote.URL(net.JoinHostPort(host, strconv.Itoa(int(p.remoteControlPortInput.GetValue()))), token)
+		}
+
+		if remoteControlURL == "" {
+			p.remoteControlURLRow.SetSubtitle(L("Available once the web remote has been started"))
+		} else {
+			p.remoteControlURLRow.SetSubtitle(remoteControlURL)
+		}
+		p.remoteControlURLCopyButton.SetSensitive(remoteControlURL != "")
 	}
 
 	onCloseRequest := func() bool {
@@ -152,9 +207,25 @@ func (p *PreferencesDialog) setupCallbacks() {
 
 	onShow := func(gtk.Widget) {
 		syncSensitivityState()
+		syncRemoteControlURL()
 	}
 	p.ConnectShow(&onShow)
 
+	onRemoteControlInputChanged := func(gobject.Object, uintptr) {
+		syncRemoteControlURL()
+	}
+	p.remoteControlLANInput.ConnectNotify(&onRemoteControlInputChanged)
+	p.remoteControlPortInput.ConnectNotify(&onRemoteControlInputChanged)
+	p.remoteControlTokenInput.ConnectNotify(&onRemoteControlInputChanged)
+
+	onCopyRemoteControlURL := func(gtk.Button) {
+		p.GetClipboard().SetText(remoteControlURL)
+
+		toast := adw.NewToast(L("Copied address to clipboard."))
+		p.AddToast(toast)
+	}
+	p.remoteControlURLCopyButton.ConnectClicked(&onCopyRemoteControlURL)
+
 	onClicked := func(gtk.Button) {
 		filePicker := gtk.NewFileChooserNative(
 			L("Select storage location"),
@@ -193,6 +264,49 @@ func (p *PreferencesDialog) setupCallbacks() {
 		return false
 	}
 	p.weronForceRelayInput.ConnectStateSet(&onWeronForceRelayStateSet)
+
+	// The web remote is started together with the app, so changes to it require reopening
+	onRemoteControlStateSet := func(gtk.Switch, bool) bool {
+		p.markPreferencesChanged()
+
+		syncSensitivityState()
+
+		return false
+	}
+	p.remoteControlSwitchInput.ConnectStateSet(&onRemoteControlStateSet)
+
+	onRemoteControlLANStateSet := func(gtk.Switch, bool) bool {
+		p.markPreferencesChanged()
+
+		return false
+	}
+	p.remoteControlLANInput.ConnectStateSet(&onRemoteControlLANStateSet)
+
+	// Language preferences are lists, so they can't be bound to the inputs directly; they are
+	// read when playback starts, so they don't require reopening either
+	for _, input := range []struct {
+		key   string
+		entry *adw.EntryRow
+	}{
+		{resources.SchemaAudioLanguagesKey, p.audioLanguagesInput},
+		{resources.SchemaSubtitleLanguagesKey, p.subtitleLanguagesInput},
+	} {
+		key := input.key
+
+		input.entry.SetText(strings.Join(p.settings.GetStrv(key), ", "))
+
+		onApply := func(entry adw.EntryRow) {
+			languages := []string{}
+			for _, language := range strings.Split(entry.GetText(), ",") {
+				if language = strings.TrimSpace(language); language != "" {
+					languages = append(languages, language)
+				}
+			}
+
+			p.settings.SetStrv(key, languages)
+		}
+		input.entry.ConnectApply(&onApply)
+	}
 }
 
 func init() {
@@ -202,6 +316,16 @@ func init() {
 
 		typeClass.BindTemplateChildFull("storage_location_input", false, 0)
 		typeClass.BindTemplateChildFull("mpv_command_input", false, 0)
+		typeClass.BindTemplateChildFull("audio_languages_input", false, 0)
+		typeClass.BindTemplateChildFull("subtitle_languages_input", false, 0)
+		typeClass.BindTemplateChildFull("subtitle_provider_url_input", false, 0)
+		typeClass.BindTemplateChildFull("subtitle_provider_key_input", false, 0)
+		typeClass.BindTemplateChildFull("remote_control_switch", false, 0)
+		typeClass.BindTemplateChildFull("remote_control_lan_switch", false, 0)
+		typeClass.BindTemplateChildFull("remote_control_port_input", false, 0)
+		typeClass.BindTemplateChildFull("remote_control_token_input", false, 0)
+		typeClass.BindTemplateChildFull("remote_control_url_row", false, 0)
+		typeClass.BindTemplateChildFull("remote_control_url_copy_button", false, 0)
 		typeClass.BindTemplateChildFull("verbosity_level_input", false, 0)
 		typeClass.BindTemplateChildFull("htorrent_remote_gateway_switch", false, 0)
 		typeClass.BindTemplateChildFull("htorrent_url_input", false, 0)
@@ -226,6 +350,16 @@ func init() {
 			var (
 				storageLocationInput       gtk.Button
 				mpvCommandInput            adw.EntryRow
+				audioLanguagesInput        adw.EntryRow
+				subtitleLanguagesInput     adw.EntryRow
+				subtitleProviderURLInput   adw.EntryRow
+				subtitleProviderKeyInput   adw.PasswordEntryRow
+				remoteControlSwitchInput   gtk.Switch
+				remoteControlLANInput      gtk.Switch
+				remoteControlPortInput     adw.SpinRow
+				remoteControlTokenInput    adw.PasswordEntryRow
+				remoteControlURLRow        adw.ActionRow
+				remoteControlURLCopyButton gtk.Button
 				verbosityLevelInput        adw.SpinRow
 				remoteGatewaySwitchInput   gtk.Switch
 				remoteGatewayURLInput      adw.EntryRow
@@ -238,6 +372,16 @@ func init() {
 			)
 			parent.Widget.GetTemplateChild(gTypePreferencesDialog, "storage_location_input").Cast(&storageLocationInput)
 			parent.Widget.GetTemplateChild(gTypePreferencesDialog, "mpv_command_input").Cast(&mpvCommandInput)
+			parent.Widget.GetTemplateChild(gTypePreferencesDialog, "audio_languages_input").Cast(&audioLanguagesInput)
+			parent.Widget.GetTemplateChild(gTypePreferencesDialog, "subtitle_languages_input").Cast(&subtitleLanguagesInput)
+			parent.Widget.GetTemplateChild(gTypePreferencesDialog, "subtitle_provider_url_input").Cast(&subtitleProviderURLInput)
+			parent.Widget.GetTemplateChild(gTypePreferencesDialog, "subtitle_provider_key_input").Cast(&subtitleProviderKeyInput)
+			parent.Widget.GetTemplateChild(gTypePreferencesDialog, "remote_control_switch").Cast(&remoteControlSwitchInput)
+			parent.Widget.GetTemplateChild(gTypePreferencesDialog, "remote_control_lan_switch").Cast(&remoteControlLANInput)
+			parent.Widget.GetTemplateChild(gTypePreferencesDialog, "remote_control_port_input").Cast(&remoteControlPortInput)
+			parent.Widget.GetTemplateChild(gTypePreferencesDialog, "remote_control_token_input").Cast(&remoteControlTokenInput)
+			parent.Widget.GetTemplateChild(gTypePreferencesDialog, "remote_control_url_row").Cast(&remoteControlURLRow)
+			parent.Widget.GetTemplateChild(gTypePreferencesDialog, "remote_control_url_copy_button").Cast(&remoteControlURLCopyButton)
 			parent.Widget.GetTemplateChild(gTypePreferencesDialog, "verbosity_level_input").Cast(&verbosityLevelInput)
 			parent.Widget.GetTemplateChild(gTypePreferencesDialog, "htorrent_remote_gateway_switch").Cast(&remoteGatewaySwitchInput)
 			parent.Widget.GetTemplateChild(gTypePreferencesDialog, "htorrent_url_input").Cast(&remoteGatewayURLInput)
@@ -253,6 +397,16 @@ func init() {
 
 				storageLocationInput:       &storageLocationInput,
 				mpvCommandInput:            &mpvCommandInput,
+				audioLanguagesInput:        &audioLanguagesInput,
+				subtitleLanguagesInput:     &subtitleLanguagesInput,
+				subtitleProviderURLInput:   &subtitleProviderURLInput,
+				subtitleProviderKeyInput:   &subtitleProviderKeyInput,
+				remoteControlSwitchInput:   &remoteControlSwitchInput,
+				remoteControlLANInput:      &remoteControlLANInput,
+				remoteControlPortInput:     &remoteControlPortInput,
+				remoteControlTokenInput:    &remoteControlTokenInput,
+				remoteControlURLRow:        &remoteControlURLRow,
+				remoteControlURLCopyButton: &remoteControlURLCopyButton,
 				verbosityLevelInput:        &verbosityLevelInput,
 				remoteGatewaySwitchInput:   &remoteGatewaySwitchInput,
 				remoteGatewayURLInput:      &remoteGatewayURLInput,
@@ -2,20 +2,28 @@ package components
 
 import (
 	"runtime"
+	"strings"
+	"sync"
 	"unsafe"
 
 	. "github.com/pojntfx/go-gettext/pkg/i18n"
 
 	"codeberg.org/puregotk/puregotk/v4/adw"
 	"codeberg.org/puregotk/puregotk/v4/gdk"
+	"codeberg.org/puregotk/puregotk/v4/gio"
 	"codeberg.org/puregotk/puregotk/v4/glib"
 	"codeberg.org/puregotk/puregotk/v4/gobject"
 	"codeberg.org/puregotk/puregotk/v4/gtk"
 	"github.com/pojntfx/multiplex/assets/resources"
+	"github.com/rs/zerolog/log"
 )
 
 var (
 	gTypeShortcutsWindow gobject.Type
+
+	// windowAccels are the accelerators of actions which are handled by a window instead of the application
+	windowAccelsLock sync.Mutex
+	windowAccels     = map[string][]string{}
 )
 
 type shortcutInfo struct {
@@ -53,6 +61,56 @@ func NewShortcutsWindow(transientFor *adw.ApplicationWindow, app *adw.Applicatio
 	return v
 }
 
+// getAccelsForAction returns the custom accelerators configured for a window action, or the defaults if there are none
+func getAccelsForAction(settings *gio.Settings, actionName string, defaults []string) []string {
+	for _, shortcut := range settings.GetStrv(resources.SchemaShortcutsKey) {
+		name, rawAccels, ok := strings.Cut(shortcut, "=")
+		if !ok || strings.TrimSpace(name) != actionName {
+			continue
+		}
+
+		accels := []string{}
+		for _, accel := range strings.Split(rawAccels, ",") {
+			accel = strings.TrimSpace(accel)
+
+			var (
+				key  uint32
+				mods gdk.ModifierType
+			)
+			if !gtk.AcceleratorParse(accel, &key, &mods) || key == 0 {
+				log.Warn().
+					Str("action", actionName).
+					Str("accelerator", accel).
+					Msg("Ignoring invalid custom keyboard shortcut")
+
+				continue
+			}
+
+			accels = append(accels, accel)
+		}
+
+		if len(accels) > 0 {
+			return accels
+		}
+	}
+
+	return defaults
+}
+
+func setWindowAccelsForAction(detailedActionName string, accels []string) {
+	windowAccelsLock.Lock()
+	defer windowAccelsLock.Unlock()
+
+	windowAccels[detailedActionName] = accels
+}
+
+func getWindowAccelsForAction(detailedActionName string) []string {
+	windowAccelsLock.Lock()
+	defer windowAccelsLock.Unlock()
+
+	return windowAccels[detailedActionName]
+}
+
 func (s *ShortcutsWindow) populateShortcuts() {
 	w := (*ShortcutsWindow)(unsafe.Pointer(s.Widget.GetData(dataKeyGoInstance)))
 
@@ -84,6 +142,26 @@ func (s *ShortcutsWindow) populateShortcuts() {
 			shortcuts: []shortcutInfo{
 				{title: L("Toggle Playback"), actionName: "win.togglePlayback"},
 				{title: L("Toggle Fullscreen"), actionName: "win.toggleFullscreen"},
+				{title: L("Seek Backward"), actionName: "win.seekBackward"},
+				{title: L("Seek Forward"), actionName: "win.seekForward"},
+				{title: L("Previous Chapter"), actionName: "win.previousChapter"},
+				{title: L("Next Chapter"), actionName: "win.nextChapter"},
+				{title: L("Toggle A-B Loop"), actionName: "win.toggleABLoop"},
+				{title: L("Add Bookmark"), actionName: "win.addBookmark"},
+				{title: L("Take Screenshot"), actionName: "win.takeScreenshot"},
+				{title: L("Set Clip Start or End"), actionName: "win.toggleClip"},
+			},
+		},
+		{
+			title: L("Audio & Subtitles"),
+			shortcuts: []shortcutInfo{
+				{title: L("Increase Volume"), actionName: "win.increaseVolume"},
+				{title: L("Decrease Volume"), actionName: "win.decreaseVolume"},
+				{title: L("Toggle Mute"), actionName: "win.toggleMute"},
+				{title: L("Previous Audio Track"), actionName: "win.previousAudioTrack"},
+				{title: L("Next Audio Track"), actionName: "win.nextAudioTrack"},
+				{title: L("Cycle Subtitles"), actionName: "win.cycleSubtitles"},
+				{title: L("Toggle Subtitles"), actionName: "win.toggleSubtitles"},
 			},
 		},
 	} {
@@ -96,6 +174,9 @@ func (s *ShortcutsWindow) populateShortcuts() {
 			accel := shortcut.accelerator
 			if accel == "" {
 				accels := a.GetAccelsForAction(shortcut.actionName)
+				if len(accels) == 0 {
+					accels = getWindowAccelsForAction(shortcut.actionName)
+				}
 				if len(accels) == 0 {
 					// The accelerators for some shortcuts (e.g. for the control window) don't get
 					// registered until the window they are attached to has been opened, so don't render their label
@@ -24,11 +24,16 @@ type SubtitlesDialog struct {
 	okButton          *gtk.Button
 	selectionGroup    *adw.PreferencesGroup
 	addFromFileButton *gtk.Button
+	searchButton      *gtk.Button
 	overlay           *adw.ToastOverlay
+	delayInput        *adw.SpinRow
+	shareDelaySwitch  *adw.SwitchRow
 
 	cancelCallback      func()
 	okCallback          func()
 	addFromFileCallback func()
+	searchCallback      func()
+	delayCallback       func(float64)
 }
 
 func NewSubtitlesDialog(transientFor *adw.ApplicationWindow) SubtitlesDialog {
@@ -88,6 +93,37 @@ func (s *SubtitlesDialog) SetAddFromFileCallback(callback func()) {
 	subD.addFromFileCallback = callback
 }
 
+func (s *SubtitlesDialog) SetSearchCallback(callback func()) {
+	subD := (*SubtitlesDialog)(unsafe.Pointer(s.Widget.GetData(dataKeyGoInstance)))
+	subD.searchCallback = callback
+}
+
+func (s *SubtitlesDialog) EnableSearchButton() {
+	subD := (*SubtitlesDialog)(unsafe.Pointer(s.Widget.GetData(dataKeyGoInstance)))
+	subD.searchButton.SetSensitive(true)
+}
+
+func (s *SubtitlesDialog) DisableSearchButton() {
+	subD := (*SubtitlesDialog)(unsafe.Pointer(s.Widget.GetData(dataKeyGoInstance)))
+	subD.searchButton.SetSensitive(false)
+}
+
+// SetDelay sets the subtitle delay in seconds; the delay callback is called if the value changes
+func (s *SubtitlesDialog) SetDelay(delay float64) {
+	subD := (*SubtitlesDialog)(unsafe.Pointer(s.Widget.GetData(dataKeyGoInstance)))
+	subD.delayInput.SetValue(delay)
+}
+
+func (s *SubtitlesDialog) SetDelayCallback(callback func(float64)) {
+	subD := (*SubtitlesDialog)(unsafe.Pointer(s.Widget.GetData(dataKeyGoInstance)))
+	subD.delayCallback = callback
+}
+
+func (s *SubtitlesDialog) ShareDelaySwitch() *adw.SwitchRow {
+	subD := (*SubtitlesDialog)(unsafe.Pointer(s.Widget.GetData(dataKeyGoInstance)))
+	return subD.shareDelaySwitch
+}
+
 func init() {
 	var classInit gobject.ClassInitFunc = func(tc *gobject.TypeClass, u uintptr) {
 		typeClass := (*gtk.WidgetClass)(unsafe.Pointer(tc))
@@ -98,7 +134,10 @@ func init() {
 		typeClass.BindTemplateChildFull("button_ok", false, 0)
 		typeClass.BindTemplateChildFull("subtitle_tracks", false, 0)
 		typeClass.BindTemplateChildFull("add_from_file_button", false, 0)
+		typeClass.BindTemplateChildFull("search_button", false, 0)
 		typeClass.BindTemplateChildFull("toast_overlay", false, 0)
+		typeClass.BindTemplateChildFull("delay_input", false, 0)
+		typeClass.BindTemplateChildFull("share_delay_switch", false, 0)
 
 		objClass := (*gobject.ObjectClass)(unsafe.Pointer(tc))
 
@@ -117,14 +156,20 @@ func init() {
 				okButton          gtk.Button
 				selectionGroup    adw.PreferencesGroup
 				addFromFileButton gtk.Button
+				searchButton      gtk.Button
 				overlay           adw.ToastOverlay
+				delayInput        adw.SpinRow
+				shareDelaySwitch  adw.SwitchRow
 			)
 			parent.Widget.GetTemplateChild(gTypeSubtitlesDialog, "button_cancel").Cast(&cancelButton)
 			parent.Widget.GetTemplateChild(gTypeSubtitlesDialog, "headerbar_spinner").Cast(&spinner)
 			parent.Widget.GetTemplateChild(gTypeSubtitlesDialog, "button_ok").Cast(&okButton)
 			parent.Widget.GetTemplateChild(gTypeSubtitlesDialog, "subtitle_tracks").Cast(&selectionGroup)
 			parent.Widget.GetTemplateChild(gTypeSubtitlesDialog, "add_from_file_button").Cast(&addFromFileButton)
+			parent.Widget.GetTemplateChild(gTypeSubtitlesDialog, "search_button").Cast(&searchButton)
 			parent.Widget.GetTemplateChild(gTypeSubtitlesDialog, "toast_overlay").Cast(&overlay)
+			parent.Widget.GetTemplateChild(gTypeSubtitlesDialog, "delay_input").Cast(&delayInput)
+			parent.Widget.GetTemplateChild(gTypeSubtitlesDialog, "share_delay_switch").Cast(&shareDelaySwitch)
 
 			s := &SubtitlesDialog{
 				Window:            parent,
@@ -133,7 +178,10 @@ func init() {
 				okButton:          &okButton,
 				selectionGroup:    &selectionGroup,
 				addFromFileButton: &addFromFileButton,
+				searchButton:      &searchButton,
 				overlay:           &overlay,
+				delayInput:        &delayInput,
+				shareDelaySwitch:  &shareDelaySwitch,
 			}
 
 			ctrl := gtk.NewEventControllerKey()
@@ -175,6 +223,20 @@ func init() {
 			}
 			addFromFileButton.ConnectClicked(&onAddFromFileClicked)
 
+			onSearchClicked := func(gtk.Button) {
+				if s.searchCallback != nil {
+					s.searchCallback()
+				}
+			}
+			searchButton.ConnectClicked(&onSearchClicked)
+
+			onDelayChanged := func(gtk.Adjustment) {
+				if s.delayCallback != nil {
+					s.delayCallback(delayInput.GetValue())
+				}
+			}
+			delayInput.GetAdjustment().ConnectValueChanged(&onDelayChanged)
+
 			var pinner runtime.Pinner
 			pinner.Pin(s)
 
@@ -0,0 +1,154 @@
+package downloads
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/pojntfx/multiplex/internal/store"
+	mpvClient "github.com/pojntfx/multiplex/pkg/client"
+)
+
+const (
+	// Extension of the file which is downloaded to until it is complete, so that a file without it is always complete
+	partialFileExtension = ".part"
+)
+
+var (
+	ErrSizeMismatch = errors.New("size of the downloaded file does not match the torrent's metadata")
+)
+
+// Manifest records a download so that it can be resumed later
+type Manifest struct {
+	MagnetLink string    `json:"magnetLink"`
+	InfoHash   string    `json:"infoHash"`
+	Title      string    `json:"title"`
+	Path       string    `json:"path"` // Path of the file in the torrent
+	File       string    `json:"file"` // Path of the downloaded file on disk
+	Size       int64     `json:"size"` // Size of the file according to the torrent's metadata
+	Complete   bool      `json:"complete"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// Key identifies the download of a file in a torrent
+func (m Manifest) Key() string {
+	return store.MediaKey(m.InfoHash, m.Path)
+}
+
+// PartialFile returns the path which a file is downloaded to until it is complete
+func PartialFile(file string) string {
+	return file + partialFileExtension
+}
+
+// Progress returns the number of bytes which have been downloaded so far
+func (m Manifest) Progress() int64 {
+	if stat, err := os.Stat(m.File); err == nil && stat.Size() == m.Size {
+		return stat.Size()
+	}
+
+	if stat, err := os.Stat(PartialFile(m.File)); err == nil {
+		return stat.Size()
+	}
+
+	return 0
+}
+
+type progressWriter struct {
+	w          io.Writer
+	written    int64
+	onProgress func(written int64)
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.written += int64(n)
+
+	if p.onProgress != nil {
+		p.onProgress(p.written)
+	}
+
+	return n, err
+}
+
+// Download fetches the file of a manifest from the gateway, continuing from the partial file of an earlier
+// attempt if there is one. The file is only moved to its final path once its size matches the manifest.
+func Download(ctx context.Context, client *mpvClient.GatewayClient, streamURL string, manifest Manifest, onProgress func(written int64)) error {
+	if stat, err := os.Stat(manifest.File); err == nil && stat.Size() == manifest.Size {
+		if onProgress != nil {
+			onProgress(stat.Size())
+		}
+
+		return nil
+	} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	partialFile := PartialFile(manifest.File)
+
+	f, err := os.OpenFile(partialFile, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	offset := stat.Size()
+	if offset > manifest.Size {
+		// The partial file can't belong to this torrent, so start over
+		offset = 0
+	}
+
+	if offset < manifest.Size {
+		res, err := client.Open(ctx, streamURL, offset)
+		if err != nil {
+			return err
+		}
+		defer res.Close()
+
+		if res.Size >= 0 && res.Size != manifest.Size {
+			return fmt.Errorf("%w: expected %v bytes, gateway sent %v bytes", ErrSizeMismatch, manifest.Size, res.Size)
+		}
+
+		// If the gateway ignored the range, the body starts at the beginning of the file
+		offset = res.Offset
+
+		if err := f.Truncate(offset); err != nil {
+			return err
+		}
+
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+
+		if _, err := io.Copy(&progressWriter{
+			w:          f,
+			written:    offset,
+			onProgress: onProgress,
+		}, res); err != nil {
+			return err
+		}
+	}
+
+	stat, err = f.Stat()
+	if err != nil {
+		return err
+	}
+
+	if stat.Size() != manifest.Size {
+		return fmt.Errorf("%w: expected %v bytes, got %v bytes", ErrSizeMismatch, manifest.Size, stat.Size())
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(partialFile, manifest.File)
+}
@@ -0,0 +1,413 @@
+package downloads
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pojntfx/multiplex/internal/store"
+	mpvClient "github.com/pojntfx/multiplex/pkg/client"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// Minimum time between two samples of the download speed
+	speedSampleInterval = time.Second
+
+	// Weight of the latest sample in the moving average of the download speed
+	speedSampleWeight = 0.3
+)
+
+var (
+	ErrDownloadNotFound = errors.New("download not found")
+	ErrDownloadCanceled = errors.New("download canceled")
+)
+
+type State string
+
+const (
+	StateDownloading State = "downloading"
+	StatePaused      State = "paused"
+	StateComplete    State = "complete"
+	StateFailed      State = "failed"
+)
+
+// Status is a snapshot of a download
+type Status struct {
+	Manifest
+
+	State   State
+	Written int64   // Number of bytes which have been downloaded so far
+	Speed   float64 // Download speed in bytes per second
+	Err     error   // Error which stopped the download if it failed
+}
+
+// ETA returns the remaining time until the download is complete, or -1 if it is unknown
+func (s Status) ETA() time.Duration {
+	if s.State != StateDownloading || s.Speed <= 0 {
+		return -1
+	}
+
+	return time.Duration(float64(s.Size-s.Written) / s.Speed * float64(time.Second))
+}
+
+type download struct {
+	cancel func()
+	done   chan struct{}
+
+	written     int64
+	speed       float64
+	lastSample  time.Time
+	lastWritten int64
+	err         error
+}
+
+// Manager runs downloads in the background, independently of the window they were started from, and
+// records them in a manifest so that they can be resumed after the app was closed
+type Manager struct {
+	client  *mpvClient.GatewayClient
+	apiAddr string
+	store   *store.JSONStore[Manifest]
+
+	lock      sync.Mutex
+	downloads map[string]*download
+	waiters   map[string][]chan error
+
+	activeLock     sync.Mutex
+	active         bool // Whether the active callback was last called with true
+	activeCallback func(active bool)
+}
+
+func NewManager(client *mpvClient.GatewayClient, apiAddr, manifestPath string) *Manager {
+	return &Manager{
+		client:    client,
+		apiAddr:   apiAddr,
+		store:     store.NewJSONStore[Manifest](manifestPath),
+		downloads: map[string]*download{},
+		waiters:   map[string][]chan error{},
+	}
+}
+
+// Open loads the manifest of earlier downloads
+func (m *Manager) Open() error {
+	return m.store.Open()
+}
+
+// SetActiveCallback sets a function which is called when the first download starts and when the last download stops;
+// it is called from the goroutine which started or stopped the download, without holding any locks
+func (m *Manager) SetActiveCallback(callback func(active bool)) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.activeCallback = callback
+}
+
+func (m *Manager) Get(key string) (Manifest, bool) {
+	return m.store.Get(key)
+}
+
+// Active reports whether a file is being downloaded
+func (m *Manager) Active(key string) bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	d, ok := m.downloads[key]
+	if !ok {
+		return false
+	}
+
+	select {
+	case <-d.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// Done returns a channel which receives nil once the download of a file is complete, or the error which
+// stopped it if it fails or is canceled; pausing a download doesn't stop it from being waited for
+func (m *Manager) Done(key string) <-chan error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	done := make(chan error, 1)
+	m.waiters[key] = append(m.waiters[key], done)
+
+	return done
+}
+
+// notify sends the result of a download to everyone who is waiting for it; the lock must be held
+func (m *Manager) notify(key string, err error) {
+	for _, done := range m.waiters[key] {
+		done <- err
+	}
+	delete(m.waiters, key)
+}
+
+// Start downloads a file in the background, or continues an earlier download of it; starting a download
+// which is already running does nothing
+func (m *Manager) Start(manifest Manifest) error {
+	key := manifest.Key()
+
+	streamURL, err := mpvClient.StreamURL(m.apiAddr, manifest.MagnetLink, manifest.Path)
+	if err != nil {
+		return err
+	}
+
+	manifest.Complete = false
+	manifest.UpdatedAt = time.Now()
+	if err := m.store.Set(key, manifest); err != nil {
+		return err
+	}
+
+	m.lock.Lock()
+	if d, ok := m.downloads[key]; ok {
+		select {
+		case <-d.done:
+			// The download failed earlier, so it can be restarted
+		default:
+			m.lock.Unlock()
+
+			return nil
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &download{
+		cancel:      cancel,
+		done:        make(chan struct{}),
+		written:     manifest.Progress(),
+		lastSample:  time.Now(),
+		lastWritten: manifest.Progress(),
+	}
+	m.downloads[key] = d
+	m.lock.Unlock()
+
+	m.updateActive()
+
+	go func() {
+		log.Info().
+			Str("streamURL", streamURL).
+			Int64("offset", d.written).
+			Int64("size", manifest.Size).
+			Msg("Downloading media")
+
+		err := Download(ctx, m.client, streamURL, manifest, func(written int64) {
+			m.lock.Lock()
+			defer m.lock.Unlock()
+
+			d.written = written
+
+			if elapsed := time.Since(d.lastSample); elapsed >= speedSampleInterval {
+				sample := float64(written-d.lastWritten) / elapsed.Seconds()
+				if d.speed == 0 {
+					d.speed = sample
+				} else {
+					d.speed = speedSampleWeight*sample + (1-speedSampleWeight)*d.speed
+				}
+
+				d.lastSample = time.Now()
+				d.lastWritten = written
+			}
+		})
+
+		if err == nil {
+			manifest.Complete = true
+			manifest.UpdatedAt = time.Now()
+			if err := m.store.Set(key, manifest); err != nil {
+				log.Warn().
+					Str("key", key).
+					Err(err).
+					Msg("Could not write downloads manifest")
+			}
+		}
+
+		m.lock.Lock()
+		if err == nil {
+			m.notify(key, nil)
+
+			log.Info().
+				Str("streamURL", streamURL).
+				Msg("Finished downloading media")
+		} else if errors.Is(err, context.Canceled) {
+			log.Info().
+				Str("streamURL", streamURL).
+				Int64("offset", d.written).
+				Msg("Download stopped, it can be resumed later")
+		} else {
+			log.Warn().
+				Str("streamURL", streamURL).
+				Err(err).
+				Msg("Could not download media")
+
+			d.err = err
+
+			m.notify(key, err)
+		}
+
+		close(d.done)
+
+		// Failed downloads are kept so that their error can be shown until they are resumed or canceled
+		if d.err == nil && m.downloads[key] == d {
+			delete(m.downloads, key)
+		}
+		m.lock.Unlock()
+
+		m.updateActive()
+	}()
+
+	return nil
+}
+
+// updateActive calls the active callback if downloads started or stopped running since it was last called; the
+// lock must not be held
+func (m *Manager) updateActive() {
+	m.activeLock.Lock()
+	defer m.activeLock.Unlock()
+
+	m.lock.Lock()
+	active := len(m.running()) > 0
+	callback := m.activeCallback
+	m.lock.Unlock()
+
+	if callback == nil || active == m.active {
+		return
+	}
+
+	m.active = active
+	callback(active)
+}
+
+// running returns the downloads which haven't stopped yet; the lock must be held
+func (m *Manager) running() []*download {
+	downloads := []*download{}
+	for _, d := range m.downloads {
+		select {
+		case <-d.done:
+		default:
+			downloads = append(downloads, d)
+		}
+	}
+
+	return downloads
+}
+
+// stop cancels a download and waits for it to stop
+func (m *Manager) stop(key string) {
+	m.lock.Lock()
+	d, ok := m.downloads[key]
+	m.lock.Unlock()
+
+	if !ok {
+		return
+	}
+
+	d.cancel()
+	<-d.done
+
+	m.lock.Lock()
+	if m.downloads[key] == d {
+		delete(m.downloads, key)
+	}
+	m.lock.Unlock()
+}
+
+// Pause stops a download, keeping the data which has been downloaded so far so that it can be resumed later
+func (m *Manager) Pause(key string) {
+	m.stop(key)
+}
+
+// Resume continues a paused or failed download
+func (m *Manager) Resume(key string) error {
+	manifest, ok := m.store.Get(key)
+	if !ok {
+		return ErrDownloadNotFound
+	}
+
+	m.stop(key)
+
+	return m.Start(manifest)
+}
+
+// Cancel stops a download and removes the data which has been downloaded so far; files which have been
+// downloaded completely are kept and only removed from the list of downloads
+func (m *Manager) Cancel(key string) error {
+	m.stop(key)
+
+	m.lock.Lock()
+	m.notify(key, ErrDownloadCanceled)
+	m.lock.Unlock()
+
+	manifest, ok := m.store.Get(key)
+	if !ok {
+		return nil
+	}
+
+	if !manifest.Complete {
+		if err := os.Remove(PartialFile(manifest.File)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	return m.store.Delete(key)
+}
+
+// List returns the status of all downloads, starting with the most recent one
+func (m *Manager) List() []Status {
+	manifests := m.store.Entries()
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	statuses := []Status{}
+	for key, manifest := range manifests {
+		status := Status{
+			Manifest: manifest,
+			State:    StatePaused,
+		}
+
+		if d, ok := m.downloads[key]; ok {
+			status.Written = d.written
+			status.Err = d.err
+
+			if d.err != nil {
+				status.State = StateFailed
+			} else {
+				status.State = StateDownloading
+				status.Speed = d.speed
+			}
+		} else {
+			status.Written = manifest.Progress()
+
+			if manifest.Complete {
+				status.State = StateComplete
+			}
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].UpdatedAt.After(statuses[j].UpdatedAt)
+	})
+
+	return statuses
+}
+
+// Close pauses all downloads
+func (m *Manager) Close() {
+	m.lock.Lock()
+	keys := []string{}
+	for key := range m.downloads {
+		keys = append(keys, key)
+	}
+	m.lock.Unlock()
+
+	for _, key := range keys {
+		m.stop(key)
+	}
+}
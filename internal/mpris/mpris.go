@@ -0,0 +1,434 @@
+package mpris
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+	"github.com/pojntfx/multiplex/assets/resources"
+)
+
+const (
+	BusName    = "org.mpris.MediaPlayer2.multiplex"
+	ObjectPath = dbus.ObjectPath("/org/mpris/MediaPlayer2")
+
+	RootInterface   = "org.mpris.MediaPlayer2"
+	PlayerInterface = "org.mpris.MediaPlayer2.Player"
+
+	PlaybackStatusPlaying = "Playing"
+	PlaybackStatusPaused  = "Paused"
+	PlaybackStatusStopped = "Stopped"
+
+	identity        = "Multiplex"
+	trackPathPrefix = "/com/pojtinger/felicitas/Multiplex/Track/"
+	noTrack         = dbus.ObjectPath("/org/mpris/MediaPlayer2/TrackList/NoTrack")
+)
+
+var (
+	ErrNameTaken = errors.New("could not acquire MPRIS bus name")
+
+	errNotSupported = dbus.NewError("org.mpris.MediaPlayer2.Error.NotSupported", []any{"not supported"})
+
+	instances atomic.Int64
+
+	// Methods of the player interface which can't use their D-Bus names in Go
+	playerMethodNames = map[string]string{
+		"SeekBy": "Seek",
+	}
+)
+
+// Handlers are called when playback is controlled over MPRIS; they should behave like the controls of the
+// controls window, so that peers are kept in sync. Handlers which are nil are skipped.
+type Handlers struct {
+	Raise       func()
+	PlayPause   func()
+	Play        func()
+	Pause       func()
+	Stop        func()
+	Seek        func(position time.Duration) // Seeks to an absolute position
+	GetPosition func() time.Duration
+	SetVolume   func(volume float64) // Volume from 0 to 1
+}
+
+// Server exposes playback through the MPRIS D-Bus interface
+type Server struct {
+	conn     *dbus.Conn
+	handlers Handlers
+	name     string
+	props    *prop.Properties
+
+	lock    sync.Mutex
+	trackID dbus.ObjectPath
+	length  time.Duration
+}
+
+// NewServer creates an MPRIS server on a D-Bus connection, e.g. the session bus or a private bus for testing
+func NewServer(conn *dbus.Conn, handlers Handlers) *Server {
+	return &Server{
+		conn:     conn,
+		handlers: handlers,
+		trackID:  noTrack,
+	}
+}
+
+// Name returns the bus name of the server once it has been opened
+func (s *Server) Name() string {
+	return s.name
+}
+
+// Open exports the MPRIS interfaces and acquires a bus name. If another window already uses the
+// well-known name, an instance-specific name is used instead, like the MPRIS specification suggests.
+func (s *Server) Open() error {
+	var err error
+	s.props, err = prop.Export(s.conn, ObjectPath, prop.Map{
+		RootInterface: {
+			"CanQuit":             {Value: false, Emit: prop.EmitConst},
+			"CanRaise":            {Value: s.handlers.Raise != nil, Emit: prop.EmitConst},
+			"HasTrackList":        {Value: false, Emit: prop.EmitConst},
+			"Identity":            {Value: identity, Emit: prop.EmitConst},
+			"DesktopEntry":        {Value: resources.AppID, Emit: prop.EmitConst},
+			"SupportedUriSchemes": {Value: []string{}, Emit: prop.EmitConst},
+			"SupportedMimeTypes":  {Value: []string{}, Emit: prop.EmitConst},
+		},
+		PlayerInterface: {
+			"PlaybackStatus": {Value: PlaybackStatusPaused, Emit: prop.EmitTrue},
+			"LoopStatus":     {Value: "None", Emit: prop.EmitConst},
+			"Rate":           {Value: 1.0, Emit: prop.EmitConst},
+			"Shuffle":        {Value: false, Emit: prop.EmitConst},
+			"Metadata":       {Value: s.metadata("", "", 0), Emit: prop.EmitTrue},
+			"Volume": {
+				Value:    1.0,
+				Writable: true,
+				Emit:     prop.EmitTrue,
+				Callback: s.onSetVolume,
+			},
+			"Position":      {Value: int64(0), Emit: prop.EmitFalse},
+			"MinimumRate":   {Value: 1.0, Emit: prop.EmitConst},
+			"MaximumRate":   {Value: 1.0, Emit: prop.EmitConst},
+			"CanGoNext":     {Value: false, Emit: prop.EmitConst},
+			"CanGoPrevious": {Value: false, Emit: prop.EmitConst},
+			"CanPlay":       {Value: true, Emit: prop.EmitConst},
+			"CanPause":      {Value: true, Emit: prop.EmitConst},
+			"CanSeek":       {Value: false, Emit: prop.EmitTrue},
+			"CanControl":    {Value: true, Emit: prop.EmitConst},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := s.conn.Export(mediaPlayer2{s}, ObjectPath, RootInterface); err != nil {
+		return err
+	}
+
+	if err := s.conn.ExportWithMap(player{s}, playerMethodNames, ObjectPath, PlayerInterface); err != nil {
+		return err
+	}
+
+	playerMethods := introspect.Methods(player{s})
+	for i, method := range playerMethods {
+		if name, ok := playerMethodNames[method.Name]; ok {
+			playerMethods[i].Name = name
+		}
+	}
+
+	if err := s.conn.Export(introspect.NewIntrospectable(&introspect.Node{
+		Name: string(ObjectPath),
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{
+				Name:       RootInterface,
+				Methods:    introspect.Methods(mediaPlayer2{s}),
+				Properties: s.props.Introspection(RootInterface),
+			},
+			{
+				Name:       PlayerInterface,
+				Methods:    playerMethods,
+				Properties: s.props.Introspection(PlayerInterface),
+				Signals: []introspect.Signal{
+					{
+						Name: "Seeked",
+						Args: []introspect.Arg{{Name: "Position", Type: "x"}},
+					},
+				},
+			},
+		},
+	}), ObjectPath, "org.freedesktop.DBus.Introspectable"); err != nil {
+		return err
+	}
+
+	for _, name := range []string{
+		BusName,
+		fmt.Sprintf("%v.instance%v_%v", BusName, os.Getpid(), instances.Add(1)),
+	} {
+		reply, err := s.conn.RequestName(name, dbus.NameFlagDoNotQueue)
+		if err != nil {
+			return err
+		}
+
+		if reply == dbus.RequestNameReplyPrimaryOwner {
+			s.name = name
+
+			return nil
+		}
+	}
+
+	return ErrNameTaken
+}
+
+// Close releases the bus name and stops exporting the MPRIS interfaces
+func (s *Server) Close() error {
+	if s.name != "" {
+		if _, err := s.conn.ReleaseName(s.name); err != nil {
+			return err
+		}
+	}
+
+	for _, iface := range []string{RootInterface, PlayerInterface, "org.freedesktop.DBus.Properties", "org.freedesktop.DBus.Introspectable"} {
+		if err := s.conn.Export(nil, ObjectPath, iface); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SetMedia updates the metadata of the media which is playing; `length` is 0 if it is unknown
+func (s *Server) SetMedia(id, title, album string, length time.Duration) {
+	s.lock.Lock()
+	trackID := dbus.ObjectPath(trackPathPrefix + sanitizePathElement(id))
+	changed := trackID != s.trackID || length != s.length
+	s.trackID = trackID
+	s.length = length
+	s.lock.Unlock()
+
+	if !changed || s.props == nil {
+		return
+	}
+
+	s.props.SetMust(PlayerInterface, "Metadata", s.metadata(title, album, length))
+	s.props.SetMust(PlayerInterface, "CanSeek", length > 0)
+}
+
+// SetPlaying updates the playback status
+func (s *Server) SetPlaying(playing bool) {
+	if s.props == nil {
+		return
+	}
+
+	status := PlaybackStatusPaused
+	if playing {
+		status = PlaybackStatusPlaying
+	}
+
+	if s.props.GetMust(PlayerInterface, "PlaybackStatus") != status {
+		s.props.SetMust(PlayerInterface, "PlaybackStatus", status)
+	}
+}
+
+// SetPosition updates the position without notifying clients, which is what MPRIS expects for regular playback
+func (s *Server) SetPosition(position time.Duration) {
+	if s.props == nil {
+		return
+	}
+
+	s.props.SetMust(PlayerInterface, "Position", position.Microseconds())
+}
+
+// SetVolume updates the volume (from 0 to 1)
+func (s *Server) SetVolume(volume float64) {
+	if s.props == nil {
+		return
+	}
+
+	if s.props.GetMust(PlayerInterface, "Volume") != volume {
+		s.props.SetMust(PlayerInterface, "Volume", volume)
+	}
+}
+
+// Seeked notifies clients that the position changed in a way which isn't regular playback, e.g. after a seek
+func (s *Server) Seeked(position time.Duration) error {
+	s.SetPosition(position)
+
+	return s.conn.Emit(ObjectPath, PlayerInterface+".Seeked", position.Microseconds())
+}
+
+func (s *Server) metadata(title, album string, length time.Duration) map[string]dbus.Variant {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	metadata := map[string]dbus.Variant{
+		"mpris:trackid": dbus.MakeVariant(s.trackID),
+	}
+
+	if title != "" {
+		metadata["xesam:title"] = dbus.MakeVariant(title)
+	}
+
+	if album != "" {
+		metadata["xesam:album"] = dbus.MakeVariant(album)
+	}
+
+	if length > 0 {
+		metadata["mpris:length"] = dbus.MakeVariant(length.Microseconds())
+	}
+
+	return metadata
+}
+
+func (s *Server) onSetVolume(c *prop.Change) *dbus.Error {
+	volume, ok := c.Value.(float64)
+	if !ok {
+		return prop.ErrInvalidArg
+	}
+
+	if s.handlers.SetVolume != nil {
+		// The handler can update the property itself, which would deadlock while the change is being applied
+		go s.handlers.SetVolume(math.Max(0, math.Min(1, volume)))
+	}
+
+	return nil
+}
+
+// seekTo seeks to a position within the media and notifies clients
+func (s *Server) seekTo(position time.Duration) {
+	if s.handlers.Seek == nil {
+		return
+	}
+
+	s.handlers.Seek(position)
+
+	_ = s.Seeked(position)
+}
+
+func sanitizePathElement(id string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' {
+			return r
+		}
+
+		return '_'
+	}, id)
+}
+
+// mediaPlayer2 implements the `org.mpris.MediaPlayer2` interface
+type mediaPlayer2 struct {
+	s *Server
+}
+
+func (m mediaPlayer2) Raise() *dbus.Error {
+	if m.s.handlers.Raise != nil {
+		m.s.handlers.Raise()
+	}
+
+	return nil
+}
+
+// Quit does nothing, since `CanQuit` is false
+func (m mediaPlayer2) Quit() *dbus.Error {
+	return nil
+}
+
+// player implements the `org.mpris.MediaPlayer2.Player` interface
+type player struct {
+	s *Server
+}
+
+// Next does nothing, since there is only one media per session
+func (p player) Next() *dbus.Error {
+	return nil
+}
+
+// Previous does nothing, since there is only one media per session
+func (p player) Previous() *dbus.Error {
+	return nil
+}
+
+func (p player) Pause() *dbus.Error {
+	if p.s.handlers.Pause != nil {
+		p.s.handlers.Pause()
+	}
+
+	return nil
+}
+
+func (p player) PlayPause() *dbus.Error {
+	if p.s.handlers.PlayPause != nil {
+		p.s.handlers.PlayPause()
+	}
+
+	return nil
+}
+
+func (p player) Stop() *dbus.Error {
+	if p.s.handlers.Stop != nil {
+		p.s.handlers.Stop()
+	}
+
+	return nil
+}
+
+func (p player) Play() *dbus.Error {
+	if p.s.handlers.Play != nil {
+		p.s.handlers.Play()
+	}
+
+	return nil
+}
+
+// SeekBy implements `Seek`, which seeks relative to the current position (in microseconds); it is renamed
+// so that it isn't mistaken for `io.Seeker`
+func (p player) SeekBy(offset int64) *dbus.Error {
+	if p.s.handlers.GetPosition == nil {
+		return nil
+	}
+
+	p.s.lock.Lock()
+	length := p.s.length
+	p.s.lock.Unlock()
+
+	target := p.s.handlers.GetPosition() + time.Duration(offset)*time.Microsecond
+	if target < 0 {
+		target = 0
+	}
+
+	// There is no next media to skip to, so seeking past the end stops at the end
+	if length > 0 && target > length {
+		target = length
+	}
+
+	p.s.seekTo(target)
+
+	return nil
+}
+
+// SetPosition seeks to an absolute position (in microseconds) if the track is still playing
+func (p player) SetPosition(trackID dbus.ObjectPath, position int64) *dbus.Error {
+	p.s.lock.Lock()
+	currentTrackID := p.s.trackID
+	length := p.s.length
+	p.s.lock.Unlock()
+
+	target := time.Duration(position) * time.Microsecond
+	if trackID != currentTrackID || target < 0 || (length > 0 && target > length) {
+		return nil
+	}
+
+	p.s.seekTo(target)
+
+	return nil
+}
+
+// OpenUri is not supported, since media is opened from the main window
+func (p player) OpenUri(uri string) *dbus.Error {
+	return errNotSupported
+}
@@ -0,0 +1,224 @@
+package mpris
+
+import (
+	"bufio"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// connectTestBus starts a private session bus and returns two connections to it, one for the server and one for clients
+func connectTestBus(t *testing.T) (*dbus.Conn, *dbus.Conn) {
+	t.Helper()
+
+	if _, err := exec.LookPath("dbus-daemon"); err != nil {
+		t.Skip("dbus-daemon is not installed")
+	}
+
+	cmd := exec.Command("dbus-daemon", "--session", "--nofork", "--print-address", "--address=unix:dir="+t.TempDir())
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conns := []*dbus.Conn{}
+	for range 2 {
+		conn, err := dbus.Connect(strings.TrimSpace(address))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			_ = conn.Close()
+		})
+
+		conns = append(conns, conn)
+	}
+
+	return conns[0], conns[1]
+}
+
+func openTestServer(t *testing.T, conn *dbus.Conn, handlers Handlers) *Server {
+	t.Helper()
+
+	s := NewServer(conn, handlers)
+	if err := s.Open(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = s.Close()
+	})
+
+	return s
+}
+
+func TestOpenUsesInstanceNameIfTaken(t *testing.T) {
+	serverConn, otherConn := connectTestBus(t)
+
+	first := openTestServer(t, serverConn, Handlers{})
+	if first.Name() != BusName {
+		t.Fatalf("Name() = %q, want %q", first.Name(), BusName)
+	}
+
+	second := openTestServer(t, otherConn, Handlers{})
+	if !strings.HasPrefix(second.Name(), BusName+".instance") {
+		t.Fatalf("Name() = %q, want an instance name", second.Name())
+	}
+}
+
+func TestPlaybackMethods(t *testing.T) {
+	serverConn, clientConn := connectTestBus(t)
+
+	calls := make(chan string, 1)
+	s := openTestServer(t, serverConn, Handlers{
+		Raise:     func() { calls <- "Raise" },
+		PlayPause: func() { calls <- "PlayPause" },
+		Play:      func() { calls <- "Play" },
+		Pause:     func() { calls <- "Pause" },
+		Stop:      func() { calls <- "Stop" },
+	})
+
+	obj := clientConn.Object(s.Name(), ObjectPath)
+
+	tests := []struct {
+		method string
+		want   string
+	}{
+		{RootInterface + ".Raise", "Raise"},
+		{PlayerInterface + ".PlayPause", "PlayPause"},
+		{PlayerInterface + ".Play", "Play"},
+		{PlayerInterface + ".Pause", "Pause"},
+		{PlayerInterface + ".Stop", "Stop"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			if err := obj.Call(tt.method, 0).Err; err != nil {
+				t.Fatal(err)
+			}
+
+			select {
+			case got := <-calls:
+				if got != tt.want {
+					t.Fatalf("called %v, want %v", got, tt.want)
+				}
+
+			default:
+				t.Fatalf("%v was not called", tt.want)
+			}
+		})
+	}
+}
+
+func TestSeeking(t *testing.T) {
+	serverConn, clientConn := connectTestBus(t)
+
+	const (
+		position = 10 * time.Second
+		length   = time.Minute
+	)
+
+	seeks := make(chan time.Duration, 1)
+	s := openTestServer(t, serverConn, Handlers{
+		Seek: func(position time.Duration) {
+			seeks <- position
+		},
+		GetPosition: func() time.Duration {
+			return position
+		},
+	})
+	s.SetMedia("media-1", "Title", "Album", length)
+
+	if err := clientConn.AddMatchSignal(
+		dbus.WithMatchObjectPath(ObjectPath),
+		dbus.WithMatchInterface(PlayerInterface),
+		dbus.WithMatchMember("Seeked"),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	signals := make(chan *dbus.Signal, 10)
+	clientConn.Signal(signals)
+
+	obj := clientConn.Object(s.Name(), ObjectPath)
+
+	metadata, err := obj.GetProperty(PlayerInterface + ".Metadata")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var trackID dbus.ObjectPath
+	if err := metadata.Value().(map[string]dbus.Variant)["mpris:trackid"].Store(&trackID); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		args   []any
+		want   time.Duration // Position which is seeked to, or -1 if the call must be ignored
+	}{
+		{"seek forwards", PlayerInterface + ".Seek", []any{int64(5 * time.Second / time.Microsecond)}, 15 * time.Second},
+		{"seek backwards", PlayerInterface + ".Seek", []any{int64(-5 * time.Second / time.Microsecond)}, 5 * time.Second},
+		{"seek before start", PlayerInterface + ".Seek", []any{int64(-time.Minute / time.Microsecond)}, 0},
+		{"seek past end", PlayerInterface + ".Seek", []any{int64(time.Hour / time.Microsecond)}, length},
+		{"set position", PlayerInterface + ".SetPosition", []any{trackID, int64(30 * time.Second / time.Microsecond)}, 30 * time.Second},
+		{"set position of stale track", PlayerInterface + ".SetPosition", []any{dbus.ObjectPath(trackPathPrefix + "media_0"), int64(30 * time.Second / time.Microsecond)}, -1},
+		{"set position past end", PlayerInterface + ".SetPosition", []any{trackID, int64(2 * time.Minute / time.Microsecond)}, -1},
+		{"set negative position", PlayerInterface + ".SetPosition", []any{trackID, int64(-1)}, -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := obj.Call(tt.method, 0, tt.args...).Err; err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.want < 0 {
+				select {
+				case got := <-seeks:
+					t.Fatalf("seeked to %v, want the call to be ignored", got)
+
+				default:
+				}
+
+				return
+			}
+
+			select {
+			case got := <-seeks:
+				if got != tt.want {
+					t.Fatalf("seeked to %v, want %v", got, tt.want)
+				}
+
+			default:
+				t.Fatalf("did not seek, want %v", tt.want)
+			}
+
+			select {
+			case signal := <-signals:
+				if len(signal.Body) != 1 || signal.Body[0] != tt.want.Microseconds() {
+					t.Fatalf("Seeked signal body = %v, want [%v]", signal.Body, tt.want.Microseconds())
+				}
+
+			case <-time.After(5 * time.Second):
+				t.Fatal("did not receive Seeked signal")
+			}
+		})
+	}
+}
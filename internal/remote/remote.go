@@ -0,0 +1,556 @@
+package remote
+
+import (
+	"context"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	TypeState    = "state"    // TypeState contains the complete playback state, sent once a client connects or the controlled window changes
+	TypePause    = "pause"    // TypePause synchronizes play/pause state
+	TypePosition = "position" // TypePosition synchronizes seek positions
+	TypeVolume   = "volume"   // TypeVolume synchronizes the volume
+	TypeTracks   = "tracks"   // TypeTracks lists the audio tracks and subtitles
+	TypeTrack    = "track"    // TypeTrack selects an audio track or subtitles
+
+	TrackKindAudio     = "audio"
+	TrackKindSubtitles = "subtitles"
+
+	tokenParameter = "token"
+	writeTimeout   = time.Second * 10
+)
+
+var (
+	//go:embed index.html
+	index []byte
+)
+
+// Message is a generic message container
+type Message struct {
+	Type string `json:"type"` // Message type to unmarshal to
+}
+
+// Track is an audio track or subtitles which can be selected
+type Track struct {
+	Name   string `json:"name"`   // Name of the track
+	Active bool   `json:"active"` // Whether the track is selected
+}
+
+// State is the playback state of the controlled window
+type State struct {
+	Message
+	Active    bool    `json:"active"`    // Whether a window is being controlled; if not, all other fields are empty
+	Title     string  `json:"title"`     // Title of the media
+	Playing   bool    `json:"playing"`   // Whether playback is running
+	Position  float64 `json:"position"`  // Position in nanoseconds
+	Duration  float64 `json:"duration"`  // Duration in nanoseconds; 0 if it is unknown
+	Volume    float64 `json:"volume"`    // Volume from 0 to 1
+	Audio     []Track `json:"audio"`     // Audio tracks; the first one disables audio
+	Subtitles []Track `json:"subtitles"` // Subtitles; the first one disables subtitles
+}
+
+// Pause synchronizes play/pause state
+type Pause struct {
+	Message
+	Pause bool `json:"pause"` // Whether to pause or play
+}
+
+// Position synchronizes seek positions
+type Position struct {
+	Message
+	Position float64 `json:"position"` // Position in nanoseconds
+	Duration float64 `json:"duration"` // Duration in nanoseconds, only sent to clients
+}
+
+// Volume synchronizes the volume
+type Volume struct {
+	Message
+	Volume float64 `json:"volume"` // Volume from 0 to 1
+}
+
+// Tracks lists the audio tracks and subtitles
+type Tracks struct {
+	Message
+	Audio     []Track `json:"audio"`
+	Subtitles []Track `json:"subtitles"`
+}
+
+// SelectTrack selects an audio track or subtitles
+type SelectTrack struct {
+	Message
+	Kind  string `json:"kind"`  // TrackKindAudio or TrackKindSubtitles
+	Index int    `json:"index"` // Index of the track in the list of tracks of this kind
+}
+
+// Handlers are called when playback is controlled remotely; they should behave like the controls of the
+// controls window, so that peers are kept in sync. Handlers which are nil are skipped.
+type Handlers struct {
+	SetPaused       func(pause bool)
+	Seek            func(position float64) // Seeks to an absolute position in nanoseconds
+	SetVolume       func(volume float64)   // Volume from 0 to 1
+	SelectAudio     func(index int)
+	SelectSubtitles func(index int)
+}
+
+// Server serves the web remote and the WebSocket API which controls the window that was attached last
+type Server struct {
+	addr     string
+	token    string
+	upgrader websocket.Upgrader
+
+	listener net.Listener
+	server   *http.Server
+
+	lock    sync.Mutex
+	players []*Player
+	clients map[*client]struct{}
+}
+
+// URL returns the address which browsers open to use the web remote on `addr`, including the access token
+func URL(addr, token string) string {
+	return (&url.URL{
+		Scheme:   "http",
+		Host:     addr,
+		Path:     "/",
+		RawQuery: url.Values{tokenParameter: []string{token}}.Encode(),
+	}).String()
+}
+
+// LANHost returns an address of this device in the local network, or "" if it isn't connected to one
+func LANHost() string {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return ""
+	}
+
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.IsPrivate() && ipNet.IP.To4() != nil {
+			return ipNet.IP.String()
+		}
+	}
+
+	return ""
+}
+
+// NewServer creates a remote control server which listens on `addr` and requires `token` from clients
+func NewServer(addr, token string) *Server {
+	return &Server{
+		addr:    addr,
+		token:   token,
+		clients: map[*client]struct{}{},
+	}
+}
+
+// Open starts listening for clients
+func (s *Server) Open() error {
+	var err error
+	s.listener, err = net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", s.handleIndex)
+	mux.HandleFunc("GET /ws", s.handleWebSocket)
+
+	s.server = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: writeTimeout,
+	}
+
+	go func() {
+		if err := s.server.Serve(s.listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Warn().
+				Err(err).
+				Msg("Could not serve remote control")
+		}
+	}()
+
+	return nil
+}
+
+// Addr returns the address which the server listens on once it has been opened
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Close stops listening and disconnects all clients
+func (s *Server) Close() error {
+	if s.server == nil {
+		return nil
+	}
+
+	s.lock.Lock()
+	for c := range s.clients {
+		_ = c.conn.Close()
+	}
+	s.lock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+	defer cancel()
+
+	return s.server.Shutdown(ctx)
+}
+
+// Attach lets a window be controlled remotely until it is detached. It is safe to call on a nil server,
+// which is what windows have if the remote control is disabled; the returned player is nil then.
+func (s *Server) Attach(title string, handlers Handlers) *Player {
+	if s == nil {
+		return nil
+	}
+
+	p := &Player{
+		server:   s,
+		handlers: handlers,
+		state: State{
+			Message:   Message{Type: TypeState},
+			Active:    true,
+			Title:     title,
+			Volume:    1,
+			Audio:     []Track{},
+			Subtitles: []Track{},
+		},
+	}
+
+	s.lock.Lock()
+	s.players = append(s.players, p)
+	s.lock.Unlock()
+
+	s.broadcast(p.State())
+
+	return p
+}
+
+// current returns the player which is being controlled, or nil if there is none
+func (s *Server) current() *Player {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if len(s.players) == 0 {
+		return nil
+	}
+
+	return s.players[len(s.players)-1]
+}
+
+// state returns the playback state which is sent to clients which connect
+func (s *Server) state() *State {
+	if p := s.current(); p != nil {
+		return p.State()
+	}
+
+	return &State{
+		Message:   Message{Type: TypeState},
+		Audio:     []Track{},
+		Subtitles: []Track{},
+	}
+}
+
+func (s *Server) broadcast(message interface{}) {
+	s.lock.Lock()
+	clients := make([]*client, 0, len(s.clients))
+	for c := range s.clients {
+		clients = append(clients, c)
+	}
+	s.lock.Unlock()
+
+	for _, c := range clients {
+		if err := c.write(message); err != nil {
+			log.Debug().
+				Err(err).
+				Msg("Could not send message to remote control client, disconnecting")
+
+			_ = c.conn.Close()
+		}
+	}
+}
+
+// authorized checks the token of a request, which is sent as a query parameter by browsers or as a bearer token
+func (s *Server) authorized(r *http.Request) bool {
+	token := r.URL.Query().Get(tokenParameter)
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		token = bearer
+	}
+
+	return s.token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+
+	_, _ = w.Write(index)
+}
+
+func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+
+		return
+	}
+
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Debug().
+			Err(err).
+			Msg("Could not upgrade remote control connection")
+
+		return
+	}
+
+	c := &client{conn: conn}
+
+	s.lock.Lock()
+	s.clients[c] = struct{}{}
+	s.lock.Unlock()
+
+	defer func() {
+		s.lock.Lock()
+		delete(s.clients, c)
+		s.lock.Unlock()
+
+		_ = conn.Close()
+	}()
+
+	log.Info().
+		Str("raddr", r.RemoteAddr).
+		Msg("Remote control connected")
+
+	if err := c.write(s.state()); err != nil {
+		return
+	}
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			log.Info().
+				Str("raddr", r.RemoteAddr).
+				Msg("Remote control disconnected")
+
+			return
+		}
+
+		if err := s.handleMessage(data); err != nil {
+			log.Debug().
+				Err(err).
+				Msg("Could not handle remote control message, skipping")
+		}
+	}
+}
+
+func (s *Server) handleMessage(data []byte) error {
+	var message Message
+	if err := json.Unmarshal(data, &message); err != nil {
+		return err
+	}
+
+	p := s.current()
+	if p == nil {
+		return nil
+	}
+
+	switch message.Type {
+	case TypePause:
+		var m Pause
+		if err := json.Unmarshal(data, &m); err != nil {
+			return err
+		}
+
+		if p.handlers.SetPaused != nil {
+			p.handlers.SetPaused(m.Pause)
+		}
+
+	case TypePosition:
+		var m Position
+		if err := json.Unmarshal(data, &m); err != nil {
+			return err
+		}
+
+		if p.handlers.Seek != nil && m.Position >= 0 {
+			p.handlers.Seek(m.Position)
+		}
+
+	case TypeVolume:
+		var m Volume
+		if err := json.Unmarshal(data, &m); err != nil {
+			return err
+		}
+
+		if p.handlers.SetVolume != nil {
+			p.handlers.SetVolume(max(0, min(1, m.Volume)))
+		}
+
+	case TypeTrack:
+		var m SelectTrack
+		if err := json.Unmarshal(data, &m); err != nil {
+			return err
+		}
+
+		p.lock.Lock()
+		audio, subtitles := len(p.state.Audio), len(p.state.Subtitles)
+		p.lock.Unlock()
+
+		switch {
+		case m.Kind == TrackKindAudio && m.Index >= 0 && m.Index < audio && p.handlers.SelectAudio != nil:
+			p.handlers.SelectAudio(m.Index)
+
+		case m.Kind == TrackKindSubtitles && m.Index >= 0 && m.Index < subtitles && p.handlers.SelectSubtitles != nil:
+			p.handlers.SelectSubtitles(m.Index)
+		}
+	}
+
+	return nil
+}
+
+// client is a WebSocket connection; only one message can be written to it at a time
+type client struct {
+	conn *websocket.Conn
+	lock sync.Mutex
+}
+
+func (c *client) write(message interface{}) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if err := c.conn.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
+		return err
+	}
+
+	return c.conn.WriteJSON(message)
+}
+
+// Player is a window which is controlled remotely; changes to its state are sent to clients while it is
+// being controlled. All methods are safe to call on a nil player.
+type Player struct {
+	server   *Server
+	handlers Handlers
+
+	lock  sync.Mutex
+	state State
+}
+
+// State returns a copy of the playback state
+func (p *Player) State() *State {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	state := p.state
+	state.Audio = slices.Clone(p.state.Audio)
+	state.Subtitles = slices.Clone(p.state.Subtitles)
+
+	return &state
+}
+
+// SetPlaying updates the playback status
+func (p *Player) SetPlaying(playing bool) {
+	if p == nil {
+		return
+	}
+
+	p.lock.Lock()
+	changed := p.state.Playing != playing
+	p.state.Playing = playing
+	p.lock.Unlock()
+
+	if changed {
+		p.send(&Pause{Message: Message{Type: TypePause}, Pause: !playing})
+	}
+}
+
+// SetPosition updates the position and duration (in nanoseconds); clients are only notified about
+// changes of at least a second, which is what regular playback looks like
+func (p *Player) SetPosition(position, duration float64) {
+	if p == nil {
+		return
+	}
+
+	p.lock.Lock()
+	changed := int64(p.state.Position/float64(time.Second)) != int64(position/float64(time.Second)) || p.state.Duration != duration
+	p.state.Position = position
+	p.state.Duration = duration
+	p.lock.Unlock()
+
+	if changed {
+		p.send(&Position{Message: Message{Type: TypePosition}, Position: position, Duration: duration})
+	}
+}
+
+// SetVolume updates the volume (from 0 to 1)
+func (p *Player) SetVolume(volume float64) {
+	if p == nil {
+		return
+	}
+
+	p.lock.Lock()
+	changed := p.state.Volume != volume
+	p.state.Volume = volume
+	p.lock.Unlock()
+
+	if changed {
+		p.send(&Volume{Message: Message{Type: TypeVolume}, Volume: volume})
+	}
+}
+
+// SetTracks updates the audio tracks and subtitles
+func (p *Player) SetTracks(audio, subtitles []Track) {
+	if p == nil {
+		return
+	}
+
+	p.lock.Lock()
+	changed := !slices.Equal(p.state.Audio, audio) || !slices.Equal(p.state.Subtitles, subtitles)
+	p.state.Audio = slices.Clone(audio)
+	p.state.Subtitles = slices.Clone(subtitles)
+	p.lock.Unlock()
+
+	if changed {
+		p.send(&Tracks{Message: Message{Type: TypeTracks}, Audio: audio, Subtitles: subtitles})
+	}
+}
+
+// Detach stops controlling the window; the window which was attached before it is controlled again
+func (p *Player) Detach() {
+	if p == nil {
+		return
+	}
+
+	p.server.lock.Lock()
+	i := slices.Index(p.server.players, p)
+	if i >= 0 {
+		p.server.players = slices.Delete(p.server.players, i, i+1)
+	}
+	p.server.lock.Unlock()
+
+	if i >= 0 {
+		p.server.broadcast(p.server.state())
+	}
+}
+
+// send notifies clients about a change if the player is being controlled
+func (p *Player) send(message interface{}) {
+	if p.server.current() != p {
+		return
+	}
+
+	p.server.broadcast(message)
+}
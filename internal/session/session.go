@@ -0,0 +1,662 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"codeberg.org/puregotk/puregotk/v4/gio"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pojntfx/multiplex/assets/resources"
+	api "github.com/pojntfx/multiplex/pkg/api/webrtc/v1"
+	"github.com/pojntfx/multiplex/pkg/links"
+	"github.com/pojntfx/weron/pkg/wrtcconn"
+	"github.com/rs/zerolog/log"
+	"github.com/teivah/broadcast"
+	"github.com/teris-io/shortid"
+)
+
+const (
+	channelID = "multiplex/sync"
+
+	EventPeerJoined = "peerjoined" // EventPeerJoined is sent when a peer connects
+	EventPeerLeft   = "peerleft"   // EventPeerLeft is sent when a peer disconnects
+
+	eventsCapacity = 64
+)
+
+var (
+	ErrPeerDisconnected = errors.New("peer disconnected before sending the media of the session")
+)
+
+// Config configures how the peers of a session are connected
+type Config struct {
+	SignalerURL string        // URL of the weron signaling server
+	ICEServers  []string      // STUN and TURN servers
+	Timeout     time.Duration // Time to wait for connections
+	ForceRelay  bool          // Only connect to peers through TURN servers
+}
+
+// NewConfig reads the configuration of sessions from the preferences
+func NewConfig(settings *gio.Settings) Config {
+	return Config{
+		SignalerURL: settings.GetString(resources.SchemaWeronURLKey),
+		ICEServers:  strings.Split(settings.GetString(resources.SchemaWeronICEKey), ","),
+		Timeout:     time.Duration(time.Second * time.Duration(settings.GetInt64(resources.SchemaWeronTimeoutKey))),
+		ForceRelay:  settings.GetBoolean(resources.SchemaWeronForceRelayKey),
+	}
+}
+
+// Callbacks handle the messages which are received from peers; callbacks which are nil are skipped
+type Callbacks struct {
+	OnPeer          func(connected bool)
+	OnPause         func(pause bool)
+	OnPosition      func(position float64) // Position in nanoseconds
+	OnBuffering     func(buffering bool)
+	OnBookmark      func(bookmark *api.Bookmark)
+	OnABLoop        func(loop *api.ABLoop)
+	OnSubtitleDelay func(delay *api.SubtitleDelay)
+
+	GetPosition    func() float64       // Position in nanoseconds which is sent to peers once they connect; 0 if it is unknown
+	GetSharedState func() []interface{} // Bookmarks, loops and delays which are sent to peers once they connect
+}
+
+// Event is a change of a session; besides peers joining and leaving, every message which is synced with peers
+// is reported, no matter if it was sent or received
+type Event struct {
+	Type   string      // EventPeerJoined, EventPeerLeft or the type of the synced message, e.g. `api.TypePause`
+	PeerID string      // Peer which joined, left or sent the message; empty for messages which were sent
+	Value  interface{} // Synced value: a `bool` for pauses and buffering, a position in nanoseconds or the message itself
+}
+
+// Session connects the peers which watch the same media and relays the playback state between them
+type Session struct {
+	config    Config
+	community string
+	password  string
+	key       string
+
+	ctx     context.Context
+	cancel  func()
+	adapter *wrtcconn.Adapter
+	ids     chan string
+
+	// The peer which sent the media of a joined session; messages which it sent before the media are handled once the session is served
+	bufferedPeer     *wrtcconn.Peer
+	bufferedDecoder  *json.Decoder
+	bufferedMessages []interface{}
+
+	peersLock sync.Mutex
+	peers     []string
+
+	eventsLock sync.Mutex
+	closed     bool
+	events     *broadcast.Relay[Event]
+
+	closeOnce sync.Once
+
+	Pauses         *broadcast.Relay[bool]
+	Positions      *broadcast.Relay[float64] // Positions in nanoseconds
+	Buffering      *broadcast.Relay[bool]
+	Bookmarks      *broadcast.Relay[*api.Bookmark]
+	Loops          *broadcast.Relay[*api.ABLoop]
+	SubtitleDelays *broadcast.Relay[*api.SubtitleDelay]
+}
+
+// NewSession creates a session for a stream code; if `streamCode` is empty, a new one is generated
+func NewSession(config Config, streamCode string) (*Session, error) {
+	s := &Session{
+		config: config,
+		events: broadcast.NewRelay[Event](),
+
+		Pauses:         broadcast.NewRelay[bool](),
+		Positions:      broadcast.NewRelay[float64](),
+		Buffering:      broadcast.NewRelay[bool](),
+		Bookmarks:      broadcast.NewRelay[*api.Bookmark](),
+		Loops:          broadcast.NewRelay[*api.ABLoop](),
+		SubtitleDelays: broadcast.NewRelay[*api.SubtitleDelay](),
+	}
+
+	if streamCode != "" {
+		if err := links.ValidateStreamCode(streamCode); err != nil {
+			return nil, err
+		}
+
+		parts := strings.Split(streamCode, ":")
+		s.community, s.password, s.key = parts[0], parts[1], parts[2]
+
+		return s, nil
+	}
+
+	sid, err := shortid.New(1, shortid.DefaultABC, uint64(time.Now().UnixNano()))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, part := range []*string{&s.community, &s.password, &s.key} {
+		*part, err = sid.Generate()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// StreamCode returns the `<community>:<password>:<key>` code which peers join the session with
+func (s *Session) StreamCode() string {
+	return fmt.Sprintf("%v:%v:%v", s.community, s.password, s.key)
+}
+
+// Peers returns the IDs of the peers which are connected to the session
+func (s *Session) Peers() []string {
+	s.peersLock.Lock()
+	defer s.peersLock.Unlock()
+
+	return slices.Clone(s.peers)
+}
+
+// Events returns a listener for the events of the session, which is closed together with the session; if the
+// session has already been closed, it returns nil. Events which aren't received in time are dropped.
+func (s *Session) Events() *broadcast.Listener[Event] {
+	s.eventsLock.Lock()
+	defer s.eventsLock.Unlock()
+
+	if s.closed {
+		return nil
+	}
+
+	return s.events.Listener(eventsCapacity)
+}
+
+// Open connects to the signaling server
+func (s *Session) Open(ctx context.Context) error {
+	u, err := url.Parse(s.config.SignalerURL)
+	if err != nil {
+		return err
+	}
+
+	q := u.Query()
+	q.Set("community", s.community)
+	q.Set("password", s.password)
+	u.RawQuery = q.Encode()
+
+	s.ctx, s.cancel = context.WithCancel(ctx)
+
+	s.adapter = wrtcconn.NewAdapter(
+		u.String(),
+		s.key,
+		s.config.ICEServers,
+		[]string{channelID},
+		&wrtcconn.AdapterConfig{
+			Timeout:    s.config.Timeout,
+			ForceRelay: s.config.ForceRelay,
+			OnSignalerReconnect: func() {
+				log.Info().
+					Str("raddr", s.config.SignalerURL).
+					Msg("Reconnecting to signaler")
+			},
+		},
+		s.ctx,
+	)
+
+	s.ids, err = s.adapter.Open()
+	if err != nil {
+		s.cancel()
+
+		return err
+	}
+
+	return nil
+}
+
+// Join waits for the first peer of the session and returns the media which it is playing
+func (s *Session) Join() (*api.Magnet, error) {
+	for {
+		select {
+		case <-s.ctx.Done():
+			return nil, s.ctx.Err()
+		case rid := <-s.ids:
+			log.Info().
+				Str("raddr", s.config.SignalerURL).
+				Str("id", rid).
+				Msg("Reconnecting to signaler")
+		case peer := <-s.adapter.Accept():
+			log.Info().
+				Str("peerID", peer.PeerID).
+				Str("channel", peer.ChannelID).
+				Msg("Connected to peer")
+
+			s.bufferedPeer = peer
+			s.bufferedDecoder = json.NewDecoder(peer.Conn)
+
+			for {
+				var j interface{}
+				if err := s.bufferedDecoder.Decode(&j); err != nil {
+					return nil, fmt.Errorf("%w: %v", ErrPeerDisconnected, err)
+				}
+
+				var message api.Message
+				if err := mapstructure.Decode(j, &message); err != nil {
+					log.Debug().
+						Err(err).
+						Msg("Could not decode message, skipping")
+
+					continue
+				}
+
+				log.Info().Interface("message", message).Msg("Decoded message")
+
+				if message.Type != api.TypeMagnet {
+					s.bufferedMessages = append(s.bufferedMessages, j)
+
+					continue
+				}
+
+				var m api.Magnet
+				if err := mapstructure.Decode(j, &m); err != nil {
+					log.Debug().
+						Err(err).
+						Msg("Could not decode magnet, skipping")
+
+					continue
+				}
+
+				log.Info().
+					Str("magnet", m.Magnet).
+					Str("path", m.Path).
+					Msg("Got magnet link")
+
+				return &m, nil
+			}
+		}
+	}
+}
+
+// Serve sends `media` and the playback state to all peers which connect and handles their messages with
+// `callbacks` until the session is closed or `ctx` is cancelled
+func (s *Session) Serve(ctx context.Context, media *api.Magnet, callbacks Callbacks) error {
+	if s.bufferedPeer != nil {
+		go s.handlePeer(ctx, s.bufferedPeer, s.bufferedDecoder, s.bufferedMessages, media, callbacks)
+
+		s.bufferedPeer = nil
+		s.bufferedDecoder = nil
+		s.bufferedMessages = nil
+	}
+
+	go s.reportState(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := ctx.Err(); err != context.Canceled {
+				return err
+			}
+
+			return nil
+		case <-s.ctx.Done():
+			return nil
+		case rid := <-s.ids:
+			log.Info().
+				Str("raddr", s.config.SignalerURL).
+				Str("id", rid).
+				Msg("Reconnecting to signaler")
+		case peer := <-s.adapter.Accept():
+			go s.handlePeer(ctx, peer, nil, nil, media, callbacks)
+		}
+	}
+}
+
+func (s *Session) handlePeer(ctx context.Context, peer *wrtcconn.Peer, decoder *json.Decoder, bufferedMessages []interface{}, media *api.Magnet, callbacks Callbacks) {
+	defer func() {
+		log.Info().
+			Str("peerID", peer.PeerID).
+			Str("channel", peer.ChannelID).
+			Msg("Disconnected from peer")
+
+		s.peersLock.Lock()
+		if i := slices.Index(s.peers, peer.PeerID); i >= 0 {
+			s.peers = slices.Delete(s.peers, i, i+1)
+		}
+		s.peersLock.Unlock()
+
+		s.events.Broadcast(Event{Type: EventPeerLeft, PeerID: peer.PeerID})
+
+		if callbacks.OnPeer != nil {
+			callbacks.OnPeer(false)
+		}
+	}()
+
+	log.Info().
+		Str("peerID", peer.PeerID).
+		Str("channel", peer.ChannelID).
+		Msg("Connected to peer")
+
+	s.peersLock.Lock()
+	s.peers = append(s.peers, peer.PeerID)
+	s.peersLock.Unlock()
+
+	s.events.Broadcast(Event{Type: EventPeerJoined, PeerID: peer.PeerID})
+
+	if callbacks.OnPeer != nil {
+		callbacks.OnPeer(true)
+	}
+
+	encoder := json.NewEncoder(peer.Conn)
+	if decoder == nil {
+		decoder = json.NewDecoder(peer.Conn)
+	}
+
+	go s.relayState(ctx, encoder)
+
+	if err := encoder.Encode(api.NewPause(true)); err != nil {
+		log.Debug().
+			Err(err).
+			Msg("Could not encode pause, stopping")
+
+		return
+	}
+
+	if err := encoder.Encode(media); err != nil {
+		log.Debug().
+			Err(err).
+			Msg("Could not encode magnet link, stopping")
+
+		return
+	}
+
+	if callbacks.GetPosition != nil {
+		if position := callbacks.GetPosition(); position != 0 {
+			s.Positions.Broadcast(position)
+		}
+	}
+
+	if callbacks.GetSharedState != nil {
+		for _, message := range callbacks.GetSharedState() {
+			if err := encoder.Encode(message); err != nil {
+				log.Debug().
+					Err(err).
+					Msg("Could not encode shared state, stopping")
+
+				return
+			}
+		}
+	}
+
+	for {
+		var j interface{}
+		if len(bufferedMessages) > 0 {
+			j = bufferedMessages[0]
+			bufferedMessages = bufferedMessages[1:]
+		} else {
+			if err := decoder.Decode(&j); err != nil {
+				log.Debug().
+					Err(err).
+					Msg("Could not decode structure, skipping")
+
+				return
+			}
+		}
+
+		var message api.Message
+		if err := mapstructure.Decode(j, &message); err != nil {
+			log.Debug().
+				Err(err).
+				Msg("Could not decode message, skipping")
+
+			continue
+		}
+
+		log.Info().Interface("message", message).Msg("Decoded message")
+
+		switch message.Type {
+		case api.TypePause:
+			var p api.Pause
+			if err := mapstructure.Decode(j, &p); err != nil {
+				log.Debug().
+					Err(err).
+					Msg("Could not decode pause, skipping")
+
+				continue
+			}
+
+			s.events.Broadcast(Event{Type: api.TypePause, PeerID: peer.PeerID, Value: p.Pause})
+
+			if callbacks.OnPause != nil {
+				callbacks.OnPause(p.Pause)
+			}
+		case api.TypePosition:
+			var p api.Position
+			if err := mapstructure.Decode(j, &p); err != nil {
+				log.Debug().
+					Err(err).
+					Msg("Could not decode position, skipping")
+
+				continue
+			}
+
+			s.events.Broadcast(Event{Type: api.TypePosition, PeerID: peer.PeerID, Value: p.Position})
+
+			if callbacks.OnPosition != nil {
+				callbacks.OnPosition(p.Position)
+			}
+		case api.TypeMagnet:
+			var m api.Magnet
+			if err := mapstructure.Decode(j, &m); err != nil {
+				log.Debug().
+					Err(err).
+					Msg("Could not decode magnet, skipping")
+
+				continue
+			}
+
+			log.Info().
+				Str("magnet", m.Magnet).
+				Str("path", m.Path).
+				Msg("Got magnet link")
+		case api.TypeBuffering:
+			var b api.Buffering
+			if err := mapstructure.Decode(j, &b); err != nil {
+				log.Debug().
+					Err(err).
+					Msg("Could not decode buffering, skipping")
+
+				continue
+			}
+
+			s.events.Broadcast(Event{Type: api.TypeBuffering, PeerID: peer.PeerID, Value: b.Buffering})
+
+			if callbacks.OnBuffering != nil {
+				callbacks.OnBuffering(b.Buffering)
+			}
+		case api.TypeBookmark:
+			var b api.Bookmark
+			if err := mapstructure.Decode(j, &b); err != nil {
+				log.Debug().
+					Err(err).
+					Msg("Could not decode bookmark, skipping")
+
+				continue
+			}
+
+			s.events.Broadcast(Event{Type: api.TypeBookmark, PeerID: peer.PeerID, Value: &b})
+
+			if callbacks.OnBookmark != nil {
+				callbacks.OnBookmark(&b)
+			}
+		case api.TypeABLoop:
+			var l api.ABLoop
+			if err := mapstructure.Decode(j, &l); err != nil {
+				log.Debug().
+					Err(err).
+					Msg("Could not decode A-B loop, skipping")
+
+				continue
+			}
+
+			s.events.Broadcast(Event{Type: api.TypeABLoop, PeerID: peer.PeerID, Value: &l})
+
+			if callbacks.OnABLoop != nil {
+				callbacks.OnABLoop(&l)
+			}
+		case api.TypeSubtitleDelay:
+			var d api.SubtitleDelay
+			if err := mapstructure.Decode(j, &d); err != nil {
+				log.Debug().
+					Err(err).
+					Msg("Could not decode subtitle delay, skipping")
+
+				continue
+			}
+
+			s.events.Broadcast(Event{Type: api.TypeSubtitleDelay, PeerID: peer.PeerID, Value: &d})
+
+			if callbacks.OnSubtitleDelay != nil {
+				callbacks.OnSubtitleDelay(&d)
+			}
+		}
+	}
+}
+
+// relayState sends the local playback state to a peer until the session is closed
+func (s *Session) relayState(ctx context.Context, encoder *json.Encoder) {
+	pl := s.Pauses.Listener(0)
+	defer pl.Close()
+
+	ol := s.Positions.Listener(0)
+	defer ol.Close()
+
+	bl := s.Buffering.Listener(0)
+	defer bl.Close()
+
+	bml := s.Bookmarks.Listener(0)
+	defer bml.Close()
+
+	ll := s.Loops.Listener(0)
+	defer ll.Close()
+
+	sdl := s.SubtitleDelays.Listener(0)
+	defer sdl.Close()
+
+	for {
+		var (
+			message interface{}
+			ok      bool
+		)
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.ctx.Done():
+			return
+		case pause, open := <-pl.Ch():
+			message, ok = api.NewPause(pause), open
+		case position, open := <-ol.Ch():
+			message, ok = api.NewPosition(position), open
+		case buffering, open := <-bl.Ch():
+			message, ok = api.NewBuffering(buffering), open
+		case bookmark, open := <-bml.Ch():
+			message, ok = bookmark, open
+		case loop, open := <-ll.Ch():
+			message, ok = loop, open
+		case subtitleDelay, open := <-sdl.Ch():
+			message, ok = subtitleDelay, open
+		}
+
+		// The relays are closed together with the session
+		if !ok {
+			return
+		}
+
+		if err := encoder.Encode(message); err != nil {
+			log.Debug().
+				Err(err).
+				Msg("Could not encode playback state, stopping")
+
+			return
+		}
+	}
+}
+
+// reportState reports the local playback state which is sent to peers as events until the session is closed
+func (s *Session) reportState(ctx context.Context) {
+	pl := s.Pauses.Listener(eventsCapacity)
+	defer pl.Close()
+
+	ol := s.Positions.Listener(eventsCapacity)
+	defer ol.Close()
+
+	bl := s.Buffering.Listener(eventsCapacity)
+	defer bl.Close()
+
+	bml := s.Bookmarks.Listener(eventsCapacity)
+	defer bml.Close()
+
+	ll := s.Loops.Listener(eventsCapacity)
+	defer ll.Close()
+
+	sdl := s.SubtitleDelays.Listener(eventsCapacity)
+	defer sdl.Close()
+
+	for {
+		var (
+			event Event
+			ok    bool
+		)
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.ctx.Done():
+			return
+		case pause, open := <-pl.Ch():
+			event, ok = Event{Type: api.TypePause, Value: pause}, open
+		case position, open := <-ol.Ch():
+			event, ok = Event{Type: api.TypePosition, Value: position}, open
+		case buffering, open := <-bl.Ch():
+			event, ok = Event{Type: api.TypeBuffering, Value: buffering}, open
+		case bookmark, open := <-bml.Ch():
+			event, ok = Event{Type: api.TypeBookmark, Value: bookmark}, open
+		case loop, open := <-ll.Ch():
+			event, ok = Event{Type: api.TypeABLoop, Value: loop}, open
+		case subtitleDelay, open := <-sdl.Ch():
+			event, ok = Event{Type: api.TypeSubtitleDelay, Value: subtitleDelay}, open
+		}
+
+		// The relays are closed together with the session
+		if !ok {
+			return
+		}
+
+		s.events.Broadcast(event)
+	}
+}
+
+// Close disconnects from all peers and the signaling server
+func (s *Session) Close() {
+	s.closeOnce.Do(func() {
+		if s.adapter != nil {
+			_ = s.adapter.Close()
+		}
+
+		if s.cancel != nil {
+			s.cancel()
+		}
+
+		s.Pauses.Close()
+		s.Positions.Close()
+		s.Buffering.Close()
+		s.Bookmarks.Close()
+		s.Loops.Close()
+		s.SubtitleDelays.Close()
+
+		s.eventsLock.Lock()
+		s.closed = true
+		s.events.Close()
+		s.eventsLock.Unlock()
+	})
+}
@@ -0,0 +1,112 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// MediaKey identifies a file in a torrent
+func MediaKey(infoHash, path string) string {
+	return infoHash + "/" + path
+}
+
+// JSONStore is a key-value store which is persisted to a JSON file
+type JSONStore[T any] struct {
+	path string
+
+	lock    sync.Mutex
+	entries map[string]T
+}
+
+func NewJSONStore[T any](path string) *JSONStore[T] {
+	return &JSONStore[T]{
+		path:    path,
+		entries: map[string]T{},
+	}
+}
+
+// Open loads the entries from disk; a missing file is treated as an empty store
+func (s *JSONStore[T]) Open() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	content, err := os.ReadFile(s.path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+
+		return err
+	}
+
+	return json.Unmarshal(content, &s.entries)
+}
+
+func (s *JSONStore[T]) Get(key string) (T, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	value, ok := s.entries[key]
+
+	return value, ok
+}
+
+func (s *JSONStore[T]) Entries() map[string]T {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return maps.Clone(s.entries)
+}
+
+func (s *JSONStore[T]) Set(key string, value T) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.entries[key] = value
+
+	return s.persist()
+}
+
+func (s *JSONStore[T]) Delete(key string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.entries, key)
+
+	return s.persist()
+}
+
+// persist writes the entries to a temporary file first so that a crash can't leave a truncated store behind
+func (s *JSONStore[T]) persist() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), os.ModePerm); err != nil {
+		return err
+	}
+
+	content, err := json.Marshal(s.entries)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(content); err != nil {
+		_ = f.Close()
+
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), s.path)
+}
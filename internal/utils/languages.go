@@ -0,0 +1,142 @@
+package utils
+
+import (
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
+)
+
+var (
+	// Tags which are commonly used in subtitle file names, but which aren't languages (i.e. `HI` for "hearing impaired")
+	nonLanguageFileTags = []string{"HI", "SDH", "CC"}
+)
+
+const (
+	// Number of words at the end of a file name which are checked for language tags, i.e. `Movie.2020.en.forced.srt`
+	maxFileLanguageTags = 2
+)
+
+// languageBases maps lowercase English language names to their language
+var languageBases = sync.OnceValue(func() map[string]language.Base {
+	bases := map[string]language.Base{}
+	for first := 'a'; first <= 'z'; first++ {
+		for second := 'a'; second <= 'z'; second++ {
+			base, err := language.ParseBase(string([]rune{first, second}))
+			if err != nil {
+				continue
+			}
+
+			if name := display.English.Languages().Name(base); name != "" {
+				bases[strings.ToLower(name)] = base
+			}
+		}
+	}
+
+	return bases
+})
+
+// parseLanguage returns the language of a language code (i.e. `de`, `deu` or `ger`) or English name (i.e. `German`)
+func parseLanguage(code string) (language.Base, bool) {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return language.Base{}, false
+	}
+
+	if len(code) <= 3 {
+		base, err := language.ParseBase(code)
+		if err != nil || base.String() == "und" {
+			return language.Base{}, false
+		}
+
+		return base, true
+	}
+
+	base, ok := languageBases()[strings.ToLower(code)]
+
+	return base, ok
+}
+
+// LanguageName returns the English name of a language code (i.e. `de`, `deu` or `ger`) or name (i.e. `German`),
+// which allows comparing languages regardless of how they are written; it returns "" for unknown languages
+func LanguageName(code string) string {
+	base, ok := parseLanguage(code)
+	if !ok {
+		return ""
+	}
+
+	return display.English.Languages().Name(base)
+}
+
+// LanguageCode returns the ISO 639-1 code of a language code or name (i.e. `de` for `ger` or `German`),
+// or "" for unknown languages and languages which don't have a two-letter code
+func LanguageCode(code string) string {
+	base, ok := parseLanguage(code)
+	if !ok {
+		return ""
+	}
+
+	// `String` returns the shortest code of the language, which is only two letters long if it is part of ISO 639-1
+	if short := base.String(); len(short) == 2 {
+		return short
+	}
+
+	return ""
+}
+
+// FileLanguage returns the English name of the language a file is tagged with, i.e. `German` for
+// `Movie.ger.srt` or `Subs/German.srt`, or "" if the file name doesn't contain a language tag
+func FileLanguage(name string) string {
+	base := strings.TrimSuffix(path.Base(name), path.Ext(name))
+
+	words := strings.FieldsFunc(base, func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+
+	// Language tags are usually at the end of the file name; if the file is only named after the language,
+	// the directory name is checked as well
+	candidates := words[max(0, len(words)-maxFileLanguageTags):]
+	if len(words) <= 1 {
+		candidates = append(candidates, path.Base(path.Dir(name)))
+	}
+
+	for i := len(candidates) - 1; i >= 0; i-- {
+		candidate := candidates[i]
+
+		// Two- and three-letter words are only treated as language codes if they are all lowercase or
+		// all uppercase, which avoids matching words such as "It" or "The"
+		if len(candidate) <= 3 && candidate != strings.ToLower(candidate) && candidate != strings.ToUpper(candidate) {
+			continue
+		}
+
+		if slices.Contains(nonLanguageFileTags, candidate) {
+			continue
+		}
+
+		if name := LanguageName(candidate); name != "" {
+			return name
+		}
+	}
+
+	return ""
+}
+
+// RankLanguage returns the position of a language in an ordered list of preferred languages, or -1 if it is not preferred
+func RankLanguage(preferences []string, lang string) int {
+	name := LanguageName(lang)
+	if name == "" {
+		return -1
+	}
+
+	for i, preference := range preferences {
+		if LanguageName(preference) == name {
+			return i
+		}
+	}
+
+	return -1
+}
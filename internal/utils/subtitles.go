@@ -3,20 +3,28 @@ package utils
 import (
 	. "github.com/pojntfx/go-gettext/pkg/i18n"
 
+	"bytes"
 	"encoding/json"
 	"io"
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"codeberg.org/puregotk/puregotk/v4/adw"
 	"codeberg.org/puregotk/puregotk/v4/gtk"
 	mpv "github.com/pojntfx/multiplex/pkg/api/sockets/v1"
 	mpvClient "github.com/pojntfx/multiplex/pkg/client"
+	"github.com/pojntfx/multiplex/pkg/subtitles"
 	"github.com/rs/zerolog/log"
 )
 
+const (
+	// Subtitle files are small, so larger files are passed to mpv as-is
+	maxNormalizedSubtitlesSize = 10 * 1024 * 1024
+)
+
 func SetSubtitles(
 	filePath string,
 	file io.Reader,
@@ -25,20 +33,71 @@ func SetSubtitles(
 
 	noneActivator *gtk.CheckButton,
 	subtitlesOverlay *adw.ToastOverlay,
-) error {
+) (string, error) {
 	subtitlesDir, err := os.MkdirTemp(tmpDir, "subtitles")
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	subtitlesFile := filepath.Join(subtitlesDir, path.Base(filePath))
-	f, err := os.Create(subtitlesFile)
+	content, err := io.ReadAll(io.LimitReader(file, maxNormalizedSubtitlesSize+1))
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	if _, err := io.Copy(f, file); err != nil {
-		return err
+	var subtitlesFile string
+	if len(content) > maxNormalizedSubtitlesSize || !subtitles.IsText(content) {
+		// This is most likely not a subtitle file or a binary subtitle format (i.e. VobSub or PGS), which
+		// would be corrupted by converting its encoding, but mpv might still be able to load it as-is
+		subtitlesFile = filepath.Join(subtitlesDir, path.Base(filePath))
+
+		f, err := os.Create(subtitlesFile)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+
+		if _, err := io.Copy(f, io.MultiReader(bytes.NewReader(content), file)); err != nil {
+			return "", err
+		}
+	} else {
+		normalized, encoding, format, err := subtitles.Normalize(content)
+		if err != nil {
+			return "", err
+		}
+
+		// Only text subtitle formats are normalized, other text formats (i.e. VobSub indexes) are loaded as-is
+		if format == subtitles.FormatUnknown && !subtitles.IsSubtitle(filePath) {
+			normalized = content
+			encoding = ""
+		}
+
+		// mpv can only guess the frame rate of MicroDVD subtitles, so convert them if they declare it
+		if _, ok := subtitles.MicroDVDFrameRate(normalized); ok && format == subtitles.FormatMicroDVD {
+			converted, err := subtitles.Convert(normalized, format, subtitles.FormatSRT)
+			if err != nil {
+				return "", err
+			}
+
+			normalized = converted
+			format = subtitles.FormatSRT
+		}
+
+		log.Debug().
+			Str("path", filePath).
+			Str("encoding", string(encoding)).
+			Str("format", string(format)).
+			Msg("Normalized subtitles")
+
+		// Use an extension which matches the content so that mpv picks the right demuxer
+		name := path.Base(filePath)
+		if format != subtitles.FormatUnknown {
+			name = strings.TrimSuffix(name, path.Ext(name)) + format.Extension()
+		}
+
+		subtitlesFile = filepath.Join(subtitlesDir, name)
+		if err := os.WriteFile(subtitlesFile, normalized, 0600); err != nil {
+			return "", err
+		}
 	}
 
 	if err := mpvClient.ExecuteMPVRequest(ipcFile, func(encoder *json.Encoder, decoder *json.Decoder) error {
@@ -53,7 +112,7 @@ func SetSubtitles(
 		var successResponse mpv.ResponseSuccess
 		return decoder.Decode(&successResponse)
 	}); err != nil {
-		return err
+		return "", err
 	}
 
 	var trackListResponse mpv.ResponseTrackList
@@ -66,7 +125,7 @@ func SetSubtitles(
 
 		return decoder.Decode(&trackListResponse)
 	}); err != nil {
-		return err
+		return "", err
 	}
 
 	sid := -1
@@ -94,14 +153,14 @@ func SetSubtitles(
 			var successResponse mpv.ResponseSuccess
 			return decoder.Decode(&successResponse)
 		}); err != nil {
-			return err
+			return "", err
 		}
 
 		toast := adw.NewToast(L("This file does not contain subtitles."))
 
 		subtitlesOverlay.AddToast(toast)
 
-		return nil
+		return "", nil
 	}
 
 	if err := mpvClient.ExecuteMPVRequest(ipcFile, func(encoder *json.Encoder, decoder *json.Decoder) error {
@@ -117,15 +176,19 @@ func SetSubtitles(
 		var successResponse mpv.ResponseSuccess
 		return decoder.Decode(&successResponse)
 	}); err != nil {
-		return err
+		return "", err
 	}
 
-	return mpvClient.ExecuteMPVRequest(ipcFile, func(encoder *json.Encoder, decoder *json.Decoder) error {
+	if err := mpvClient.ExecuteMPVRequest(ipcFile, func(encoder *json.Encoder, decoder *json.Decoder) error {
 		if err := encoder.Encode(mpv.Request{[]interface{}{"set_property", "sub-visibility", "yes"}}); err != nil {
 			return err
 		}
 
 		var successResponse mpv.ResponseSuccess
 		return decoder.Decode(&successResponse)
-	})
+	}); err != nil {
+		return "", err
+	}
+
+	return subtitlesFile, nil
 }
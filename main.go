@@ -2,26 +2,38 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
 	"errors"
+	"fmt"
 	"log/slog"
 	"net"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"syscall"
+	"unsafe"
 
 	"codeberg.org/puregotk/puregotk/v4/adw"
 	"codeberg.org/puregotk/puregotk/v4/gdk"
 	"codeberg.org/puregotk/puregotk/v4/gio"
 	"codeberg.org/puregotk/puregotk/v4/glib"
 	"codeberg.org/puregotk/puregotk/v4/gtk"
+	"github.com/godbus/dbus/v5"
 	"github.com/phayes/freeport"
 	"github.com/pojntfx/go-gettext/pkg/i18n"
 	v1 "github.com/pojntfx/htorrent/pkg/api/http/v1"
 	"github.com/pojntfx/htorrent/pkg/client"
 	"github.com/pojntfx/htorrent/pkg/server"
 	"github.com/pojntfx/multiplex/assets/resources"
+	"github.com/pojntfx/multiplex/internal/automation"
 	"github.com/pojntfx/multiplex/internal/components"
 	"github.com/pojntfx/multiplex/internal/crypto"
+	"github.com/pojntfx/multiplex/internal/downloads"
+	"github.com/pojntfx/multiplex/internal/remote"
+	"github.com/pojntfx/multiplex/internal/store"
+	mpvClient "github.com/pojntfx/multiplex/pkg/client"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
@@ -114,28 +126,60 @@ func main() {
 		}
 	}
 
-	configureZerolog(settings.GetInt64(resources.SchemaVerboseKey))
+	opts := options{
+		verbose: verboseUnset,
+	}
+
+	// The verbosity from the command line takes precedence over the preferences for this run
+	getVerbose := func() int64 {
+		if opts.verbose != verboseUnset {
+			return opts.verbose
+		}
+
+		return settings.GetInt64(resources.SchemaVerboseKey)
+	}
+
+	configureZerolog(getVerbose())
 	changedCallback := func(s gio.Settings, key string) {
 		if key == resources.SchemaVerboseKey {
-			configureZerolog(settings.GetInt64(resources.SchemaVerboseKey))
+			configureZerolog(getVerbose())
 		}
 	}
 	settings.ConnectChanged(&changedCallback)
 
-	app := adw.NewApplication(resources.AppID, gio.GApplicationNonUniqueValue)
+	// Only one instance runs at a time; later invocations forward their command line to it, so that
+	// all windows share the same gateway
+	app := adw.NewApplication(resources.AppID, gio.GApplicationHandlesCommandLineValue|gio.GApplicationHandlesOpenValue)
+
+	addMainOptions(app)
 
 	prov := gtk.NewCssProvider()
 	prov.LoadFromResource(resources.ResourceStyleCSSPath)
 
-	var gateway *server.Gateway
+	var (
+		gateway         *server.Gateway
+		manager         *client.Manager
+		downloadManager *downloads.Manager
+		history         *store.JSONStore[components.WatchedMedia]
+		bookmarksStore  *store.JSONStore[[]components.Bookmark]
+		delaysStore     *store.JSONStore[components.MediaDelays]
+
+		automationServer *automation.Server
+		automationConn   *dbus.Conn
+
+		remoteServer *remote.Server
+
+		apiAddr     string
+		apiUsername string
+		apiPassword string
+	)
 	ctx, cancel := context.WithCancel(context.Background())
 
-	activateCallback := func(_ gio.Application) {
-		gtk.StyleContextAddProviderForDisplay(
-			gdk.DisplayGetDefault(),
-			prov,
-			uint32(gtk.STYLE_PROVIDER_PRIORITY_APPLICATION),
-		)
+	// openGateway starts the local gateway, unless a remote gateway is configured or it has already been started
+	openGateway := func() {
+		if manager != nil {
+			return
+		}
 
 		addr, err := net.ResolveTCPAddr("tcp", "localhost:0")
 		if err != nil {
@@ -152,9 +196,9 @@ func main() {
 			panic(err)
 		}
 
-		apiAddr := settings.GetString(resources.SchemaGatewayURLKey)
-		apiUsername := settings.GetString(resources.SchemaGatewayUsernameKey)
-		apiPassword := settings.GetString(resources.SchemaGatewayPasswordKey)
+		apiAddr = settings.GetString(resources.SchemaGatewayURLKey)
+		apiUsername = settings.GetString(resources.SchemaGatewayUsernameKey)
+		apiPassword = settings.GetString(resources.SchemaGatewayPasswordKey)
 		if !settings.GetBoolean(resources.SchemaGatewayRemoteKey) {
 			apiUsername = crypto.RandomString(20)
 			apiPassword = crypto.RandomString(20)
@@ -166,7 +210,7 @@ func main() {
 				apiPassword,
 				"",
 				"",
-				settings.GetInt64(resources.SchemaVerboseKey) > 5,
+				getVerbose() > 5,
 				func(torrentMetrics v1.TorrentMetrics, fileMetrics v1.FileMetrics) {
 					log.Info().
 						Str("magnet", torrentMetrics.Magnet).
@@ -196,21 +240,343 @@ func main() {
 			apiAddr = "http://" + addr.String()
 		}
 
-		manager := client.NewManager(
+		manager = client.NewManager(
 			apiAddr,
 			apiUsername,
 			apiPassword,
 			ctx,
 		)
+	}
+
+	// openDownloadManager restores the downloads of earlier runs, unless it has already been opened
+	openDownloadManager := func() {
+		if downloadManager != nil {
+			return
+		}
+
+		downloadManager = downloads.NewManager(
+			mpvClient.NewGatewayClient(apiUsername, apiPassword, nil),
+			apiAddr,
+			filepath.Join(glib.GetUserDataDir(), resources.DataDirName, "downloads.json"),
+		)
+		if err := downloadManager.Open(); err != nil {
+			log.Warn().
+				Err(err).
+				Msg("Could not open downloads manifest, continuing without earlier downloads")
+		}
+
+		// Keep running while downloads are active, even if all windows have been closed
+		downloadManager.SetActiveCallback(func(active bool) {
+			sourceFn := glib.SourceFunc(func(uintptr) bool {
+				if active {
+					app.Hold()
+				} else {
+					app.Release()
+				}
+
+				return false
+			})
+			glib.IdleAdd(&sourceFn, 0)
+		})
+	}
+
+	// openStores loads the watch history, bookmarks and delays, unless they have already been opened; all windows
+	// share them so that they don't overwrite each other's changes
+	openStores := func() {
+		if history != nil {
+			return
+		}
+
+		history = components.NewHistoryStore()
+		if err := history.Open(); err != nil {
+			log.Warn().
+				Err(err).
+				Msg("Could not open watch history, continuing without it")
+		}
+
+		bookmarksStore = components.NewBookmarksStore()
+		if err := bookmarksStore.Open(); err != nil {
+			log.Warn().
+				Err(err).
+				Msg("Could not open bookmarks, continuing without saved bookmarks")
+		}
+
+		delaysStore = components.NewDelaysStore()
+		if err := delaysStore.Open(); err != nil {
+			log.Warn().
+				Err(err).
+				Msg("Could not open delays, continuing without saved delays")
+		}
+	}
+
+	styleProviderAdded := false
+	openMainWindow := func() *components.MainWindow {
+		if !styleProviderAdded {
+			gtk.StyleContextAddProviderForDisplay(
+				gdk.DisplayGetDefault(),
+				prov,
+				uint32(gtk.STYLE_PROVIDER_PRIORITY_APPLICATION),
+			)
+
+			styleProviderAdded = true
+		}
+
+		openGateway()
+		openDownloadManager()
+		openStores()
 
-		mainWindow := components.NewMainWindow(ctx, app, manager, apiAddr, apiUsername, apiPassword, &settings, gateway, cancel, tmpDir)
+		mainWindow := components.NewMainWindow(ctx, app, manager, downloadManager, history, bookmarksStore, delaysStore, automationServer, remoteServer, apiAddr, apiUsername, apiPassword, &settings, gateway, cancel, tmpDir)
 
 		app.AddWindow(&mainWindow.ApplicationWindow.Window)
 		mainWindow.SetVisible(true)
+
+		return mainWindow
+	}
+
+	// play opens a main window which starts or joins the session which has been requested with the options
+	play := func(o options) {
+		mainWindow := openMainWindow()
+
+		switch {
+		case o.magnet != "":
+			mainWindow.Play(o.magnet, o.path, o.streamOnly)
+
+		case o.join != "":
+			mainWindow.Play(o.join, "", o.streamOnly)
+		}
+	}
+
+	// openAutomation lets sessions be started, joined and followed over D-Bus; without a session bus, sessions
+	// can only be started from the windows
+	openAutomation := func() {
+		conn, err := dbus.ConnectSessionBus()
+		if err != nil {
+			log.Warn().
+				Err(err).
+				Msg("Could not connect to session bus, continuing without automation interface")
+
+			return
+		}
+		automationConn = conn
+
+		// Windows can only be opened from the main thread, so sessions are started once it is idle
+		playWhenIdle := func(o options) error {
+			if err := o.validate(); err != nil {
+				return err
+			}
+
+			sourceFn := glib.SourceFunc(func(uintptr) bool {
+				play(o)
+
+				return false
+			})
+			glib.IdleAdd(&sourceFn, 0)
+
+			return nil
+		}
+
+		server := automation.NewServer(conn, automation.Handlers{
+			StartSession: func(magnet, path string, streamOnly bool) error {
+				if magnet == "" {
+					return errInvalidMagnet
+				}
+
+				return playWhenIdle(options{
+					magnet:     magnet,
+					path:       path,
+					streamOnly: streamOnly,
+					verbose:    verboseUnset,
+				})
+			},
+			JoinSession: func(streamCode string, streamOnly bool) error {
+				return playWhenIdle(options{
+					join:       streamCode,
+					streamOnly: streamOnly,
+					verbose:    verboseUnset,
+				})
+			},
+		})
+		if err := server.Open(); err != nil {
+			log.Warn().
+				Err(err).
+				Msg("Could not export automation interface, continuing without it")
+
+			return
+		}
+
+		log.Info().
+			Str("name", automation.BusName).
+			Msg("Exported automation interface")
+
+		automationServer = server
+	}
+
+	// openFiles opens a main window for torrent and media files and links
+	openFiles := func(files []*gio.FileBase) {
+		mainWindow := openMainWindow()
+
+		for _, f := range files {
+			// Only one file can be streamed at a time, so the first file or link which can be opened is used
+			if path := f.GetPath(); path != "" {
+				if mainWindow.OpenFile(path) {
+					break
+				}
+
+				continue
+			}
+
+			// Files without a local path are links, e.g. `multiplex://join/<stream code>` or magnet links
+			if mainWindow.OpenLink(f.GetUri()) {
+				break
+			}
+		}
+	}
+
+	// openRemote serves the web remote if it has been enabled in the preferences
+	openRemote := func() {
+		if !settings.GetBoolean(resources.SchemaRemoteControlKey) {
+			return
+		}
+
+		// Unlike the gateway's credentials, the token can be used from other devices, so it has to be unguessable
+		token := settings.GetString(resources.SchemaRemoteControlTokenKey)
+		if strings.TrimSpace(token) == "" {
+			token = rand.Text()
+
+			settings.SetString(resources.SchemaRemoteControlTokenKey, token)
+			settings.Apply()
+		}
+
+		host := "localhost"
+		if settings.GetBoolean(resources.SchemaRemoteControlLANKey) {
+			host = ""
+		}
+
+		server := remote.NewServer(net.JoinHostPort(host, strconv.FormatInt(settings.GetInt64(resources.SchemaRemoteControlPortKey), 10)), token)
+		if err := server.Open(); err != nil {
+			log.Warn().
+				Err(err).
+				Msg("Could not start web remote, continuing without it")
+
+			return
+		}
+
+		log.Info().
+			Str("address", server.Addr().String()).
+			Msg("Web remote listening")
+
+		remoteServer = server
+	}
+
+	// Startup only happens in the first instance, before any windows are opened
+	startupCallback := func(_ gio.Application) {
+		openAutomation()
+		openRemote()
+	}
+	app.ConnectStartup(&startupCallback)
+
+	// Command lines are handled separately, so the app is only activated without any options, e.g. over D-Bus
+	activateCallback := func(_ gio.Application) {
+		openMainWindow()
 	}
 	app.ConnectActivate(&activateCallback)
 
-	shutdownCallback := func(_ gio.Application) {
+	openCallback := func(_ gio.Application, files uintptr, nFiles int32, hint string) {
+		f := []*gio.FileBase{}
+		for _, file := range unsafe.Slice((*uintptr)(unsafe.Pointer(files)), nFiles) {
+			f = append(f, &gio.FileBase{Ptr: file})
+		}
+
+		openFiles(f)
+	}
+	app.ConnectOpen(&openCallback)
+
+	// The command line of this and all later invocations is handled here, in the first instance
+	commandLineCallback := func(_ gio.Application, commandLinePtr uintptr) int32 {
+		commandLine := gio.ApplicationCommandLineNewFromInternalPtr(commandLinePtr)
+
+		// The options of later invocations come from another process, so they are validated again
+		o := parseOptions(commandLine.GetOptionsDict())
+		if err := o.validate(); err != nil {
+			var usage strings.Builder
+			fmt.Fprintf(&usage, "%v\n\n", err)
+			printUsage(&usage)
+
+			commandLine.PrinterrLiteral(usage.String())
+
+			return exitCodeUsage
+		}
+
+		if commandLine.GetIsRemote() {
+			if ignored := o.startupOptions(gateway != nil); len(ignored) > 0 {
+				log.Warn().
+					Strs("options", ignored).
+					Msg("Ignoring options which only take effect when the app starts, since it is already running")
+
+				commandLine.PrinterrLiteral(fmt.Sprintf("Ignoring options which only take effect when the app starts, since it is already running: %v\n", strings.Join(ignored, ", ")))
+			}
+		}
+
+		files := []*gio.FileBase{}
+		if args := commandLine.GetArguments(nil); len(args) > 1 {
+			for _, arg := range args[1:] {
+				if file := commandLine.CreateFileForArg(arg); file != nil {
+					files = append(files, file)
+				}
+			}
+		}
+
+		if len(files) == 0 {
+			play(o)
+
+			return 0
+		}
+
+		if o.hasMedia() {
+			var usage strings.Builder
+			fmt.Fprintf(&usage, "%v\n\n", errFilesWithOptions)
+			printUsage(&usage)
+
+			commandLine.PrinterrLiteral(usage.String())
+
+			return exitCodeUsage
+		}
+
+		openFiles(files)
+
+		return 0
+	}
+	app.ConnectCommandLine(&commandLineCallback)
+
+	shutdown := func() {
+		if downloadManager != nil {
+			downloadManager.Close()
+		}
+
+		if automationServer != nil {
+			if err := automationServer.Close(); err != nil {
+				log.Debug().
+					Err(err).
+					Msg("Could not close automation interface")
+			}
+		}
+
+		if remoteServer != nil {
+			if err := remoteServer.Close(); err != nil {
+				log.Debug().
+					Err(err).
+					Msg("Could not stop web remote")
+			}
+		}
+
+		if automationConn != nil {
+			if err := automationConn.Close(); err != nil {
+				log.Debug().
+					Err(err).
+					Msg("Could not close session bus connection")
+			}
+		}
+
 		cancel()
 
 		if gateway != nil {
@@ -219,8 +585,62 @@ func main() {
 			}
 		}
 	}
+
+	shutdownCallback := func(_ gio.Application) {
+		shutdown()
+	}
 	app.ConnectShutdown(&shutdownCallback)
 
+	handleLocalOptions := func(_ gio.Application, dictPtr uintptr) int32 {
+		opts = parseOptions((*glib.VariantDict)(unsafe.Pointer(dictPtr)))
+
+		if err := opts.validate(); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n\n", err)
+			printUsage(os.Stderr)
+
+			return exitCodeUsage
+		}
+
+		configureZerolog(getVerbose())
+
+		if opts.storage != "" {
+			storage, err := filepath.Abs(opts.storage)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+
+				return exitCodeUsage
+			}
+
+			settings.SetString(resources.SchemaStorageKey, storage)
+			settings.Apply()
+		}
+
+		if opts.headless {
+			// Headless sessions log their progress to stdout instead of opening windows
+			log.Logger = log.Output(os.Stdout)
+
+			openGateway()
+			defer shutdown()
+
+			headlessCtx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			if err := runHeadless(headlessCtx, &settings, opts, manager, apiAddr, apiUsername, apiPassword); err != nil {
+				log.Error().
+					Err(err).
+					Msg("Could not run headless session")
+
+				return 1
+			}
+
+			return 0
+		}
+
+		// Continue with the default handling, which forwards the command line to the first instance
+		return -1
+	}
+	app.ConnectHandleLocalOptions(&handleLocalOptions)
+
 	if code := app.Run(int32(len(os.Args)), os.Args); code > 0 {
 		os.Exit(int(code))
 	}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"codeberg.org/puregotk/puregotk/v4/adw"
+	"codeberg.org/puregotk/puregotk/v4/glib"
+	"github.com/pojntfx/go-gettext/pkg/i18n"
+	"github.com/pojntfx/multiplex/pkg/links"
+)
+
+const (
+	optionMagnet     = "magnet"
+	optionPath       = "path"
+	optionJoin       = "join"
+	optionStreamOnly = "stream-only"
+	optionStorage    = "storage"
+	optionVerbose    = "verbose"
+	optionHeadless   = "headless"
+
+	verboseUnset = -1
+	verboseMax   = 7
+
+	// Exit code for invalid command-line options, like the one used by GLib's option parser
+	exitCodeUsage = 1
+)
+
+var (
+	errInvalidMagnet          = errors.New("--magnet requires a magnet link")
+	errPathWithoutMagnet      = errors.New("--path requires --magnet")
+	errJoinWithMagnet         = errors.New("--join can't be combined with --magnet or --path")
+	errStreamOnlyWithoutMedia = errors.New("--stream-only requires --magnet with --path, or --join")
+	errInvalidVerbose         = fmt.Errorf("--verbose requires a level from 0 to %v", verboseMax)
+	errFilesWithOptions       = errors.New("files and links can't be combined with --magnet or --join")
+	errHeadlessWithoutMedia   = errors.New("--headless requires --magnet with --path, or --join")
+)
+
+// options are the command-line options of the app
+type options struct {
+	magnet     string
+	path       string
+	join       string
+	streamOnly bool
+	storage    string
+	verbose    int64
+	headless   bool
+}
+
+type mainOption struct {
+	long           string
+	short          byte
+	arg            glib.OptionArg
+	description    string
+	argDescription string
+}
+
+func getMainOptions() []mainOption {
+	return []mainOption{
+		{optionMagnet, 'm', glib.GOptionArgStringValue, i18n.L("Start a session for a magnet link"), "MAGNET"},
+		{optionPath, 'p', glib.GOptionArgStringValue, i18n.L("Play the media at this path in the torrent without asking"), "PATH"},
+		{optionJoin, 'j', glib.GOptionArgStringValue, i18n.L("Join the session of a stream code and play its media without asking"), "STREAM-CODE"},
+		{optionStreamOnly, 's', glib.GOptionArgNoneValue, i18n.L("Stream the media without downloading it first"), ""},
+		{optionStorage, 0, glib.GOptionArgStringValue, i18n.L("Store media in this directory (saved in the preferences)"), "DIRECTORY"},
+		{optionVerbose, 'v', glib.GOptionArgIntValue, i18n.L("Log verbosity from 0 (disabled) to 7 (trace)"), "LEVEL"},
+		{optionHeadless, 0, glib.GOptionArgNoneValue, i18n.L("Start or join a session without opening any windows and log its progress"), ""},
+	}
+}
+
+func addMainOptions(app *adw.Application) {
+	for _, option := range getMainOptions() {
+		app.AddMainOption(option.long, option.short, glib.GOptionFlagNoneValue, option.arg, option.description, option.argDescription)
+	}
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintf(w, i18n.L("Usage:\n  %v [OPTION…] [FILE|LINK…]\n\nOptions:\n"), filepath.Base(os.Args[0]))
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, option := range getMainOptions() {
+		flag := "    --" + option.long
+		if option.short != 0 {
+			flag = fmt.Sprintf("  -%c, --%v", option.short, option.long)
+		}
+
+		if option.argDescription != "" {
+			flag += "=" + option.argDescription
+		}
+
+		fmt.Fprintf(tw, "%v\t%v\n", flag, option.description)
+	}
+	_ = tw.Flush()
+
+	fmt.Fprintf(w, "\n%v\n", i18n.L("Run with --help to show all options."))
+}
+
+// parseOptions reads the options from the `GVariantDict` of the `handle-local-options` or `command-line` signals
+func parseOptions(dict *glib.VariantDict) options {
+	lookupString := func(key string) string {
+		value := dict.LookupValue(key, glib.NewVariantType("s"))
+		if value == nil {
+			return ""
+		}
+		defer value.Unref()
+
+		return value.GetString(nil)
+	}
+
+	opts := options{
+		magnet:     lookupString(optionMagnet),
+		path:       lookupString(optionPath),
+		join:       lookupString(optionJoin),
+		streamOnly: dict.Contains(optionStreamOnly),
+		storage:    lookupString(optionStorage),
+		verbose:    verboseUnset,
+		headless:   dict.Contains(optionHeadless),
+	}
+
+	if value := dict.LookupValue(optionVerbose, glib.NewVariantType("i")); value != nil {
+		opts.verbose = int64(value.GetInt32())
+
+		value.Unref()
+	}
+
+	return opts
+}
+
+// startupOptions returns the options which only take effect when the app starts, so they are ignored by an instance
+// which is already running; the storage directory is saved in the preferences, but the gateway only uses it once it
+// is started
+func (o options) startupOptions(gatewayStarted bool) []string {
+	ignored := []string{}
+	if o.storage != "" && gatewayStarted {
+		ignored = append(ignored, "--"+optionStorage)
+	}
+
+	if o.verbose != verboseUnset {
+		ignored = append(ignored, "--"+optionVerbose)
+	}
+
+	return ignored
+}
+
+// hasMedia reports whether the options select media to play
+func (o options) hasMedia() bool {
+	return o.magnet != "" || o.join != ""
+}
+
+func (o options) validate() error {
+	if o.magnet != "" {
+		if u, err := url.Parse(o.magnet); err != nil || u.Scheme != "magnet" {
+			return errInvalidMagnet
+		}
+	}
+
+	if o.path != "" && o.magnet == "" {
+		return errPathWithoutMagnet
+	}
+
+	if o.join != "" {
+		if o.magnet != "" || o.path != "" {
+			return errJoinWithMagnet
+		}
+
+		if err := links.ValidateStreamCode(o.join); err != nil {
+			return err
+		}
+	}
+
+	if o.streamOnly && o.path == "" && o.join == "" {
+		return errStreamOnlyWithoutMedia
+	}
+
+	// Headless sessions can't ask which media to play
+	if o.headless && (!o.hasMedia() || (o.magnet != "" && o.path == "")) {
+		return errHeadlessWithoutMedia
+	}
+
+	if o.verbose != verboseUnset && (o.verbose < 0 || o.verbose > verboseMax) {
+		return errInvalidVerbose
+	}
+
+	return nil
+}
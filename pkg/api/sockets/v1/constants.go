@@ -4,3 +4,7 @@ const (
 	TypeSub   = "sub"
 	TypeAudio = "audio"
 )
+
+const (
+	ErrorSuccess = "success"
+)
@@ -1,5 +1,7 @@
 package v1
 
+import "encoding/json"
+
 type ResponseFloat64 struct {
 	Data float64 `json:"data"`
 }
@@ -23,3 +25,15 @@ type ResponseTrackDescription struct {
 type ResponseSuccess struct {
 	Data []any `json:"data"`
 }
+
+type ResponseChapterDescription struct {
+	Title string  `json:"title"`
+	Time  float64 `json:"time"`
+}
+
+// Response is a generic response or event; `Data` is decoded lazily based on the request
+type Response struct {
+	Error string          `json:"error"`
+	Data  json.RawMessage `json:"data"`
+	Event string          `json:"event"`
+}
@@ -15,10 +15,15 @@ func NewPause(pause bool) *Pause {
 	}
 }
 
-// Magnet contains a magnet link
+// Magnet describes the media to play. Despite its name, it is used for all sources, so that
+// peers which only know about torrents can still join sessions of torrents.
 type Magnet struct {
 	Message
-	Magnet      string     `json:"magnet"`      // Encapsulated magnet link
+	Source      string     `json:"source"`      // Source of the media to play; empty if it was sent by a peer which only knows about torrents
+	Magnet      string     `json:"magnet"`      // Encapsulated magnet link, if the source is a torrent
+	URL         string     `json:"url"`         // URL of the media to play, if the source is a URL
+	Hash        string     `json:"hash"`        // Content hash of the media to play, if the source is a URL or a file
+	Size        int64      `json:"size"`        // Size of the media to play, if the source is a URL or a file
 	Path        string     `json:"path"`        // Path of the media to play
 	Title       string     `json:"title"`       // Title of the media to play
 	Description string     `json:"description"` // Description of the media to play
@@ -32,11 +37,19 @@ type Subtitle struct {
 }
 
 func NewMagnetLink(magnet, path, title, description string, subtitles []Subtitle) *Magnet {
+	return NewMedia(SourceTorrent, magnet, "", "", 0, path, title, description, subtitles)
+}
+
+func NewMedia(source, magnet, url, hash string, size int64, path, title, description string, subtitles []Subtitle) *Magnet {
 	return &Magnet{
 		Message: Message{
 			Type: TypeMagnet,
 		},
+		Source:      source,
 		Magnet:      magnet,
+		URL:         url,
+		Hash:        hash,
+		Size:        size,
 		Path:        path,
 		Title:       title,
 		Description: description,
@@ -73,3 +86,52 @@ func NewBuffering(buffering bool) *Buffering {
 		Buffering: buffering,
 	}
 }
+
+// Bookmark shares a bookmark
+type Bookmark struct {
+	Message
+	Name     string  `json:"name"`     // Name of the bookmark
+	Position float64 `json:"position"` // Position of the bookmark
+}
+
+func NewBookmark(name string, position float64) *Bookmark {
+	return &Bookmark{
+		Message: Message{
+			Type: TypeBookmark,
+		},
+		Name:     name,
+		Position: position,
+	}
+}
+
+// ABLoop synchronizes A-B loop points
+type ABLoop struct {
+	Message
+	A float64 `json:"a"` // Position of the loop start, negative if unset
+	B float64 `json:"b"` // Position of the loop end, negative if unset
+}
+
+func NewABLoop(a, b float64) *ABLoop {
+	return &ABLoop{
+		Message: Message{
+			Type: TypeABLoop,
+		},
+		A: a,
+		B: b,
+	}
+}
+
+// SubtitleDelay synchronizes the subtitle delay
+type SubtitleDelay struct {
+	Message
+	Delay float64 `json:"delay"` // Delay of the subtitles in seconds
+}
+
+func NewSubtitleDelay(delay float64) *SubtitleDelay {
+	return &SubtitleDelay{
+		Message: Message{
+			Type: TypeSubtitleDelay,
+		},
+		Delay: delay,
+	}
+}
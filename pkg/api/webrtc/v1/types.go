@@ -5,4 +5,14 @@ const (
 	TypePause     = "pause"     // TypePause synchronizes play/pause state
 	TypePosition  = "position"  // TypePosition synchronizes seek positions
 	TypeBuffering = "buffering" // TypeBuffering synchronizes buffering state
+	TypeBookmark  = "bookmark"  // TypeBookmark shares a bookmark
+	TypeABLoop    = "abloop"    // TypeABLoop synchronizes A-B loop points
+
+	TypeSubtitleDelay = "subtitledelay" // TypeSubtitleDelay synchronizes the subtitle delay
+)
+
+const (
+	SourceTorrent = "torrent" // SourceTorrent is a file in a torrent, which is streamed through the gateway
+	SourceURL     = "url"     // SourceURL is a plain HTTP(S) URL, which every peer fetches directly
+	SourceFile    = "file"    // SourceFile is a local file, of which every peer has their own copy
 )
@@ -0,0 +1,168 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"unicode"
+)
+
+var (
+	ErrEmptyCommand     = errors.New("command is empty")
+	ErrUnterminatedWord = errors.New("command contains an unterminated quote or escape")
+	ErrInvalidHeader    = errors.New("header value contains invalid characters")
+)
+
+// SplitCommand splits a command line into words like a POSIX shell would, but without
+// expanding variables or globs. Backslashes only escape whitespace, quotes and other
+// backslashes so that Windows paths such as `bin\mpv.exe` can be used unquoted.
+func SplitCommand(command string) ([]string, error) {
+	var (
+		words []string
+		word  strings.Builder
+
+		inWord        bool
+		inSingleQuote bool
+		inDoubleQuote bool
+	)
+
+	runes := []rune(command)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		switch {
+		case inSingleQuote:
+			if r == '\'' {
+				inSingleQuote = false
+
+				continue
+			}
+
+			word.WriteRune(r)
+		case inDoubleQuote:
+			if r == '"' {
+				inDoubleQuote = false
+
+				continue
+			}
+
+			if r == '\\' && i+1 < len(runes) && (runes[i+1] == '"' || runes[i+1] == '\\') {
+				i++
+				r = runes[i]
+			}
+
+			word.WriteRune(r)
+		case r == '\'':
+			inWord = true
+			inSingleQuote = true
+		case r == '"':
+			inWord = true
+			inDoubleQuote = true
+		case r == '\\':
+			if i+1 >= len(runes) {
+				return nil, ErrUnterminatedWord
+			}
+
+			if next := runes[i+1]; next == '\'' || next == '"' || next == '\\' || unicode.IsSpace(next) {
+				i++
+				r = next
+			}
+
+			inWord = true
+			word.WriteRune(r)
+		case unicode.IsSpace(r):
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+
+				inWord = false
+			}
+		default:
+			inWord = true
+			word.WriteRune(r)
+		}
+	}
+
+	if inSingleQuote || inDoubleQuote {
+		return nil, ErrUnterminatedWord
+	}
+
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	if len(words) == 0 {
+		return nil, ErrEmptyCommand
+	}
+
+	return words, nil
+}
+
+// WriteMPVConfig writes an mpv configuration file which authenticates all HTTP requests
+// against the gateway, so that credentials never show up in the process list. If `authorization`
+// is empty, no header is sent, so media which isn't served by the gateway never receives the credentials.
+func WriteMPVConfig(configFile, authorization string) error {
+	if authorization == "" {
+		return os.WriteFile(configFile, []byte{}, 0600)
+	}
+
+	if strings.ContainsAny(authorization, ",\r\n") {
+		return ErrInvalidHeader
+	}
+
+	header := "Authorization: " + authorization
+
+	// `%<length>%<value>` is mpv's escape-free quoting syntax for configuration files
+	return os.WriteFile(configFile, []byte(fmt.Sprintf("http-header-fields=%%%v%%%v\n", len(header), header)), 0600)
+}
+
+// NewMPVCommand creates the command to play a stream with mpv; all arguments are passed
+// to the executable directly instead of being interpreted by a shell
+func NewMPVCommand(command, ipcFile, configFile, streamURL string, extraArgs ...string) (*exec.Cmd, error) {
+	argv, err := SplitCommand(command)
+	if err != nil {
+		return nil, err
+	}
+
+	argv = append(
+		argv,
+		"--no-sub-visibility",
+		"--keep-open=always",
+		"--no-osc",
+		"--no-input-default-bindings",
+		"--pause",
+		"--input-ipc-server="+ipcFile,
+		"--include="+configFile,
+	)
+	argv = append(argv, extraArgs...)
+	argv = append(argv, "--", streamURL)
+
+	return exec.Command(argv[0], argv[1:]...), nil
+}
+
+// NewMPVEncodeCommand creates the command to export the range between `start` and `end` (in seconds)
+// of a stream to `outputFile` with mpv's encoding mode; the container and codecs are derived from the
+// extension of `outputFile`
+func NewMPVEncodeCommand(ctx context.Context, command, configFile, streamURL, outputFile string, start, end float64) (*exec.Cmd, error) {
+	argv, err := SplitCommand(command)
+	if err != nil {
+		return nil, err
+	}
+
+	argv = append(
+		argv,
+		"--no-terminal",
+		"--include="+configFile,
+		"--sid=no",
+		fmt.Sprintf("--start=%f", start),
+		fmt.Sprintf("--end=%f", end),
+		"--o="+outputFile,
+		"--",
+		streamURL,
+	)
+
+	return exec.CommandContext(ctx, argv[0], argv[1:]...), nil
+}
@@ -0,0 +1,146 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		want    []string
+		wantErr error
+	}{
+		{"simple", "mpv", []string{"mpv"}, nil},
+		{"arguments", "flatpak run io.mpv.Mpv", []string{"flatpak", "run", "io.mpv.Mpv"}, nil},
+		{"surrounding whitespace", "  mpv \t --fs  ", []string{"mpv", "--fs"}, nil},
+		{"single quotes", `'/opt/my mpv/mpv' --title='a "b" c'`, []string{"/opt/my mpv/mpv", `--title=a "b" c`}, nil},
+		{"double quotes", `"/opt/my mpv/mpv" --title="it's \"here\""`, []string{"/opt/my mpv/mpv", `--title=it's "here"`}, nil},
+		{"escaped whitespace", `/opt/my\ mpv/mpv`, []string{"/opt/my mpv/mpv"}, nil},
+		{"windows path", `bin\mpv.exe`, []string{`bin\mpv.exe`}, nil},
+		{"escaped backslash", `C:\\mpv\\mpv.exe`, []string{`C:\mpv\mpv.exe`}, nil},
+		{"empty quotes", `mpv ''`, []string{"mpv", ""}, nil},
+		{"no shell expansion", "mpv $HOME/*.mkv; rm -rf ~ && `id`", []string{"mpv", "$HOME/*.mkv;", "rm", "-rf", "~", "&&", "`id`"}, nil},
+		{"empty", "", nil, ErrEmptyCommand},
+		{"only whitespace", " \t\n", nil, ErrEmptyCommand},
+		{"unterminated single quote", "mpv 'abc", nil, ErrUnterminatedWord},
+		{"unterminated double quote", `mpv "abc`, nil, ErrUnterminatedWord},
+		{"trailing backslash", `mpv \`, nil, ErrUnterminatedWord},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SplitCommand(tt.command)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SplitCommand(%q) error = %v, want %v", tt.command, err, tt.wantErr)
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("SplitCommand(%q) = %q, want %q", tt.command, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteMPVConfig(t *testing.T) {
+	tests := []struct {
+		name          string
+		authorization string
+		want          string
+		wantErr       error
+	}{
+		{"no authorization", "", "", nil},
+		{"basic", "Basic dXNlcjpwYXNz", "http-header-fields=%33%Authorization: Basic dXNlcjpwYXNz\n", nil},
+		{"quotes", `Bearer "a'b"`, "http-header-fields=%27%Authorization: Bearer \"a'b\"\n", nil},
+		{"percent signs", "Bearer %5%abc%", "http-header-fields=%29%Authorization: Bearer %5%abc%\n", nil},
+		{"multibyte", "Bearer ä", "http-header-fields=%24%Authorization: Bearer ä\n", nil},
+		{"newline", "Basic abc\nhttp-proxy=http://evil", "", ErrInvalidHeader},
+		{"carriage return", "Basic abc\r", "", ErrInvalidHeader},
+		{"second header", "Basic abc,Cookie: a", "", ErrInvalidHeader},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configFile := filepath.Join(t.TempDir(), "mpv.conf")
+
+			err := WriteMPVConfig(configFile, tt.authorization)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("WriteMPVConfig(%q) error = %v, want %v", tt.authorization, err, tt.wantErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			got, err := os.ReadFile(configFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(got) != tt.want {
+				t.Fatalf("WriteMPVConfig(%q) wrote %q, want %q", tt.authorization, got, tt.want)
+			}
+
+			info, err := os.Stat(configFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if perm := info.Mode().Perm(); perm != 0600 {
+				t.Fatalf("WriteMPVConfig(%q) created file with permissions %v, want 0600", tt.authorization, perm)
+			}
+		})
+	}
+}
+
+func TestNewMPVCommandStreamURLIsNotAnOption(t *testing.T) {
+	tests := []struct {
+		name      string
+		streamURL string
+	}{
+		{"url", "http://localhost:1337/stream?path=a.mkv"},
+		{"option", "--script=/tmp/evil.lua"},
+		{"short option", "-v"},
+		{"path with spaces", "/home/user/My Movies/-a b.mkv"},
+		{"quotes and semicolons", `file'"; rm -rf ~.mkv`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, err := NewMPVCommand(`"/opt/my mpv/mpv" --fs`, "/tmp/mpv.sock", "/tmp/mpv.conf", tt.streamURL, "--start=10")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assertAfterSeparator(t, cmd.Args, "/opt/my mpv/mpv", tt.streamURL)
+
+			if !slices.Contains(cmd.Args, "--start=10") || slices.Index(cmd.Args, "--start=10") > slices.Index(cmd.Args, "--") {
+				t.Fatalf("extra arguments must come before the separator, got %q", cmd.Args)
+			}
+
+			encodeCmd, err := NewMPVEncodeCommand(context.Background(), "mpv", "/tmp/mpv.conf", tt.streamURL, "/tmp/clip.mkv", 1, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assertAfterSeparator(t, encodeCmd.Args, "mpv", tt.streamURL)
+		})
+	}
+}
+
+func assertAfterSeparator(t *testing.T, args []string, executable, streamURL string) {
+	t.Helper()
+
+	if args[0] != executable {
+		t.Fatalf("executable = %q, want %q", args[0], executable)
+	}
+
+	if len(args) < 2 || args[len(args)-2] != "--" || args[len(args)-1] != streamURL {
+		t.Fatalf("stream URL %q must be the only argument after \"--\", got %q", streamURL, args)
+	}
+}
@@ -0,0 +1,294 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultGatewayTimeout     = time.Second * 30
+	DefaultGatewayRetries     = 5
+	DefaultGatewayBackoff     = time.Millisecond * 500
+	DefaultGatewayMaxBackoff  = time.Second * 10
+	gatewayBackoffMultiplier  = 2
+	gatewayUnknownContentSize = -1
+)
+
+var (
+	ErrUnexpectedStatus = errors.New("unexpected status code")
+)
+
+// StreamURL returns the URL of a file in a torrent on the gateway at `base`
+func StreamURL(base, magnet, path string) (string, error) {
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+
+	streamSuffix, err := url.Parse("/stream")
+	if err != nil {
+		return "", err
+	}
+
+	stream := baseURL.ResolveReference(streamSuffix)
+
+	q := stream.Query()
+	q.Set("magnet", magnet)
+	q.Set("path", path)
+	stream.RawQuery = q.Encode()
+
+	return stream.String(), nil
+}
+
+// GatewayClientOptions configures how a `GatewayClient` connects to the gateway
+type GatewayClientOptions struct {
+	Timeout    time.Duration // Maximum time to wait for the headers of a response; the body can take longer
+	Retries    int           // Number of times a failed request (or a body which stops mid-way) is retried; negative to disable retries
+	Backoff    time.Duration // Time to wait before the first retry, which is doubled for every further retry
+	MaxBackoff time.Duration // Maximum time to wait between retries
+}
+
+// GatewayClient fetches files from the gateway's stream URLs with authenticated range requests,
+// retrying with exponential backoff if the gateway can't be reached or fails temporarily. Without a
+// username and password, no credentials are sent, so it can also fetch files from other HTTP servers.
+type GatewayClient struct {
+	client   *http.Client
+	username string
+	password string
+	options  GatewayClientOptions
+}
+
+func NewGatewayClient(username, password string, options *GatewayClientOptions) *GatewayClient {
+	if options == nil {
+		options = &GatewayClientOptions{}
+	}
+
+	opts := *options
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultGatewayTimeout
+	}
+	if opts.Retries < 0 {
+		opts.Retries = 0
+	} else if opts.Retries == 0 {
+		opts.Retries = DefaultGatewayRetries
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = DefaultGatewayBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = DefaultGatewayMaxBackoff
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = opts.Timeout
+
+	return &GatewayClient{
+		client: &http.Client{
+			Transport: transport,
+		},
+		username: username,
+		password: password,
+		options:  opts,
+	}
+}
+
+// GatewayResponse is the body of a (partial) file from the gateway
+type GatewayResponse struct {
+	io.ReadCloser
+
+	Offset int64 // Offset of the first byte of the body in the file; 0 if the gateway ignored the range
+	Size   int64 // Size of the complete file, or -1 if the gateway didn't send it
+}
+
+func isRetryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
+
+func (c *GatewayClient) wait(ctx context.Context, attempt int) error {
+	backoff := c.options.Backoff
+	for i := 0; i < attempt && backoff < c.options.MaxBackoff; i++ {
+		backoff *= gatewayBackoffMultiplier
+	}
+
+	timer := time.NewTimer(min(backoff, c.options.MaxBackoff))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (c *GatewayClient) do(ctx context.Context, url string, start, end int64) (*GatewayResponse, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+	if err != nil {
+		return nil, false, err
+	}
+	if c.username != "" || c.password != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	ranged := start > 0 || end >= 0
+	if ranged {
+		if end >= 0 {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%v-%v", start, end))
+		} else {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%v-", start))
+		}
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, ctx.Err() == nil, err
+	}
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		return &GatewayResponse{
+			ReadCloser: res.Body,
+			Offset:     0,
+			Size:       res.ContentLength,
+		}, false, nil
+
+	case http.StatusPartialContent:
+		size := int64(gatewayUnknownContentSize)
+		if _, total, ok := strings.Cut(res.Header.Get("Content-Range"), "/"); ok && total != "*" {
+			if parsed, err := strconv.ParseInt(total, 10, 64); err == nil {
+				size = parsed
+			}
+		}
+
+		return &GatewayResponse{
+			ReadCloser: res.Body,
+			Offset:     start,
+			Size:       size,
+		}, false, nil
+
+	default:
+		_ = res.Body.Close()
+
+		return nil, isRetryableStatus(res.StatusCode), fmt.Errorf("%w: %v", ErrUnexpectedStatus, res.Status)
+	}
+}
+
+// Get fetches the bytes from `start` to `end` (inclusive) of a file; if `end` is negative, the file is
+// fetched until its end. Gateways which don't support range requests return the complete file, which can
+// be detected with the response's `Offset`.
+func (c *GatewayClient) Get(ctx context.Context, url string, start, end int64) (*GatewayResponse, error) {
+	for attempt := 0; ; attempt++ {
+		res, retryable, err := c.do(ctx, url, start, end)
+		if err == nil {
+			return res, nil
+		}
+
+		if !retryable || attempt >= c.options.Retries {
+			return nil, err
+		}
+
+		if err := c.wait(ctx, attempt); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Open fetches a file from `offset` until its end; if reading the body fails, the request is resumed
+// from the last byte which was read, so large files can be fetched over unreliable connections
+func (c *GatewayClient) Open(ctx context.Context, url string, offset int64) (*GatewayResponse, error) {
+	res, err := c.Get(ctx, url, offset, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GatewayResponse{
+		ReadCloser: &resumingReader{
+			ctx:    ctx,
+			client: c,
+			url:    url,
+			offset: res.Offset,
+			body:   res.ReadCloser,
+		},
+		Offset: res.Offset,
+		Size:   res.Size,
+	}, nil
+}
+
+// Size returns the size of a file from the `Content-Range` header of a one-byte range request
+func (c *GatewayClient) Size(ctx context.Context, url string) (int64, error) {
+	res, err := c.Get(ctx, url, 0, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Close()
+
+	if res.Size < 0 {
+		return 0, ErrRangesNotSupported
+	}
+
+	return res.Size, nil
+}
+
+type resumingReader struct {
+	ctx     context.Context
+	client  *GatewayClient
+	url     string
+	offset  int64
+	body    io.ReadCloser
+	retries int
+}
+
+func (r *resumingReader) Read(p []byte) (int, error) {
+	for {
+		n, err := r.body.Read(p)
+		r.offset += int64(n)
+
+		if err == nil || errors.Is(err, io.EOF) {
+			if n > 0 {
+				r.retries = 0
+			}
+
+			return n, err
+		}
+
+		if r.ctx.Err() != nil || r.retries >= r.client.options.Retries {
+			return n, err
+		}
+
+		if n > 0 {
+			// Return the bytes which were read before the error; the next call will resume the request
+			return n, nil
+		}
+
+		_ = r.body.Close()
+
+		if err := r.client.wait(r.ctx, r.retries); err != nil {
+			return 0, err
+		}
+		r.retries++
+
+		res, err := r.client.Get(r.ctx, r.url, r.offset, -1)
+		if err != nil {
+			return 0, err
+		}
+
+		if res.Offset != r.offset {
+			_ = res.Close()
+
+			return 0, ErrRangesNotSupported
+		}
+
+		r.body = res.ReadCloser
+	}
+}
+
+func (r *resumingReader) Close() error {
+	return r.body.Close()
+}
@@ -0,0 +1,202 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testContent = []byte(strings.Repeat("0123456789abcdef", 4096))
+
+// testClientOptions retries quickly, so that the tests don't have to wait for the default backoff
+var testClientOptions = &GatewayClientOptions{
+	Retries:    2,
+	Backoff:    time.Millisecond,
+	MaxBackoff: time.Millisecond,
+}
+
+// serveContent serves `testContent` with support for range requests
+func serveContent(w http.ResponseWriter, r *http.Request) {
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(testContent))
+}
+
+// dropConnection sends the headers for the full content but drops the connection after `n` bytes of the body
+func dropConnection(w http.ResponseWriter, n int) {
+	w.Header().Set("Content-Length", "100000000")
+	w.WriteHeader(http.StatusOK)
+
+	_, _ = w.Write(testContent[:n])
+	w.(http.Flusher).Flush()
+
+	panic(http.ErrAbortHandler)
+}
+
+func TestOpenResumesDroppedConnection(t *testing.T) {
+	const dropAfter = 1000
+
+	var (
+		rangesLock sync.Mutex
+		ranges     []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rangesLock.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		first := len(ranges) == 1
+		rangesLock.Unlock()
+
+		if first {
+			dropConnection(w, dropAfter)
+		}
+
+		serveContent(w, r)
+	}))
+	defer server.Close()
+
+	res, err := NewGatewayClient("", "", testClientOptions).Open(context.Background(), server.URL, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Close()
+
+	got, err := io.ReadAll(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(got, testContent) {
+		t.Fatalf("read %v bytes which don't match the content, want %v bytes", len(got), len(testContent))
+	}
+
+	rangesLock.Lock()
+	defer rangesLock.Unlock()
+
+	want := []string{"", "bytes=1000-"}
+	if len(ranges) != len(want) {
+		t.Fatalf("got %v requests with ranges %q, want %q", len(ranges), ranges, want)
+	}
+
+	for i := range want {
+		if ranges[i] != want[i] {
+			t.Errorf("request %v range = %q, want %q", i, ranges[i], want[i])
+		}
+	}
+}
+
+func TestServerIgnoringRanges(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Dropping the first connection makes Open resume the request
+		if requests.Add(1) == 1 && r.URL.Query().Get("drop") != "" {
+			dropConnection(w, 1000)
+		}
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, _ = w.Write(testContent)
+	}))
+	defer server.Close()
+
+	client := NewGatewayClient("", "", testClientOptions)
+
+	t.Run("Get", func(t *testing.T) {
+		res, err := client.Get(context.Background(), server.URL, 100, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Close()
+
+		if res.Offset != 0 {
+			t.Fatalf("Offset = %v, want 0", res.Offset)
+		}
+
+		got, err := io.ReadAll(res)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(got, testContent) {
+			t.Fatalf("read %v bytes which don't match the content, want the complete file", len(got))
+		}
+	})
+
+	t.Run("ReadAt", func(t *testing.T) {
+		if _, err := NewHTTPReaderAt(context.Background(), client, server.URL).ReadAt(make([]byte, 10), 100); !errors.Is(err, ErrRangesNotSupported) {
+			t.Fatalf("ReadAt() error = %v, want %v", err, ErrRangesNotSupported)
+		}
+	})
+
+	t.Run("Size", func(t *testing.T) {
+		if _, err := client.Size(context.Background(), server.URL); !errors.Is(err, ErrRangesNotSupported) {
+			t.Fatalf("Size() error = %v, want %v", err, ErrRangesNotSupported)
+		}
+	})
+
+	t.Run("Open", func(t *testing.T) {
+		requests.Store(0)
+
+		res, err := client.Open(context.Background(), server.URL+"?drop=true", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Close()
+
+		if _, err := io.ReadAll(res); !errors.Is(err, ErrRangesNotSupported) {
+			t.Fatalf("ReadAll() error = %v, want %v", err, ErrRangesNotSupported)
+		}
+	})
+}
+
+func TestGetRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int // Status codes of the responses; the last one is repeated
+		options      *GatewayClientOptions
+		wantRequests int32
+		wantErr      error
+	}{
+		{"success", []int{http.StatusOK}, testClientOptions, 1, nil},
+		{"recovers from server error", []int{http.StatusServiceUnavailable, http.StatusInternalServerError, http.StatusOK}, testClientOptions, 3, nil},
+		{"recovers from rate limit", []int{http.StatusTooManyRequests, http.StatusOK}, testClientOptions, 2, nil},
+		{"gives up after retries", []int{http.StatusBadGateway}, testClientOptions, 3, ErrUnexpectedStatus},
+		{"retries disabled", []int{http.StatusInternalServerError}, &GatewayClientOptions{Retries: -1}, 1, ErrUnexpectedStatus},
+		{"client error is not retried", []int{http.StatusNotFound}, testClientOptions, 1, ErrUnexpectedStatus},
+		{"unauthorized is not retried", []int{http.StatusUnauthorized}, testClientOptions, 1, ErrUnexpectedStatus},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				status := tt.statuses[min(int(requests.Add(1)), len(tt.statuses))-1]
+				if status == http.StatusOK {
+					serveContent(w, r)
+
+					return
+				}
+
+				w.WriteHeader(status)
+			}))
+			defer server.Close()
+
+			res, err := NewGatewayClient("", "", tt.options).Get(context.Background(), server.URL, 0, -1)
+			if err == nil {
+				_ = res.Close()
+			}
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Get() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if got := requests.Load(); got != tt.wantRequests {
+				t.Fatalf("got %v requests, want %v", got, tt.wantRequests)
+			}
+		})
+	}
+}
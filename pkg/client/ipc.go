@@ -0,0 +1,91 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	mpv "github.com/pojntfx/multiplex/pkg/api/sockets/v1"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	ErrMPVRequestFailed     = errors.New("mpv request failed")
+	ErrMPVExitedBeforeReady = errors.New("mpv exited before its IPC socket was ready")
+	ErrMPVQuit              = errors.New("mpv was quit")
+)
+
+// WaitForMPVIPC waits until mpv's IPC socket accepts connections; `exited` receives the exit status of mpv, so
+// that waiting stops if it exits before the socket is ready. If it exits cleanly, i.e. because the user quit it,
+// the returned error also matches ErrMPVQuit
+func WaitForMPVIPC(ctx context.Context, ipcFile string, exited <-chan error) error {
+	for {
+		sock, err := net.Dial("unix", ipcFile)
+		if err == nil {
+			_ = sock.Close()
+
+			return nil
+		}
+
+		log.Debug().
+			Str("path", ipcFile).
+			Err(err).
+			Msg("Could not dial IPC socket, retrying in 100ms")
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-exited:
+			if err != nil {
+				return fmt.Errorf("%w: %v", ErrMPVExitedBeforeReady, err)
+			}
+
+			return fmt.Errorf("%w: %w", ErrMPVExitedBeforeReady, ErrMPVQuit)
+		case <-time.After(time.Millisecond * 100):
+		}
+	}
+}
+
+// ExecuteMPVCommand runs a command over mpv's JSON IPC and decodes the returned data into `data` if it is not nil
+func ExecuteMPVCommand(ipcFile string, data any, command ...interface{}) error {
+	return ExecuteMPVRequest(ipcFile, func(encoder *json.Encoder, decoder *json.Decoder) error {
+		if err := encoder.Encode(mpv.Request{command}); err != nil {
+			return err
+		}
+
+		for {
+			var response mpv.Response
+			if err := decoder.Decode(&response); err != nil {
+				return err
+			}
+
+			// mpv broadcasts events to all clients, which can arrive before the response
+			if response.Event != "" {
+				continue
+			}
+
+			if response.Error != mpv.ErrorSuccess {
+				return fmt.Errorf("%w: %v: %v", ErrMPVRequestFailed, command, response.Error)
+			}
+
+			if data == nil || len(response.Data) == 0 {
+				return nil
+			}
+
+			return json.Unmarshal(response.Data, data)
+		}
+	})
+}
+
+// GetMPVProperty decodes the value of an mpv property into `value`
+func GetMPVProperty(ipcFile, property string, value any) error {
+	return ExecuteMPVCommand(ipcFile, value, "get_property", property)
+}
+
+// SetMPVProperty sets the value of an mpv property
+func SetMPVProperty(ipcFile, property string, value any) error {
+	return ExecuteMPVCommand(ipcFile, nil, "set_property", property, value)
+}
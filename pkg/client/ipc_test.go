@@ -0,0 +1,35 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestWaitForMPVIPCExited(t *testing.T) {
+	tests := []struct {
+		name     string
+		exitErr  error
+		wantQuit bool
+	}{
+		{"quit", nil, true},
+		{"crashed", errors.New("exit status 1"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exited := make(chan error, 1)
+			exited <- tt.exitErr
+
+			err := WaitForMPVIPC(context.Background(), filepath.Join(t.TempDir(), "mpv.sock"), exited)
+			if !errors.Is(err, ErrMPVExitedBeforeReady) {
+				t.Fatalf("WaitForMPVIPC() error = %v, want %v", err, ErrMPVExitedBeforeReady)
+			}
+
+			if got := errors.Is(err, ErrMPVQuit); got != tt.wantQuit {
+				t.Fatalf("errors.Is(%v, ErrMPVQuit) = %v, want %v", err, got, tt.wantQuit)
+			}
+		})
+	}
+}
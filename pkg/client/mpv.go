@@ -1,50 +1,163 @@
 package client
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
 	"errors"
 	"net"
 	"os"
 	"os/exec"
+	"regexp"
 	"runtime"
+	"strconv"
+	"strings"
+)
+
+const (
+	MPVFeatureJSONIPC          = "JSON IPC"             // MPVFeatureJSONIPC is the JSON-based IPC protocol
+	MPVFeatureInputIPCServer   = "--input-ipc-server"   // MPVFeatureInputIPCServer is the option to expose the IPC socket
+	MPVFeatureHTTPHeaderFields = "--http-header-fields" // MPVFeatureHTTPHeaderFields is the option to authenticate against the gateway
 )
 
 var (
-	ErrNoWorkingMPVExecutableFound = errors.New("could not find working a working mpv executable")
+	ErrNoWorkingMPVExecutableFound  = errors.New("could not find working a working mpv executable")
+	ErrMPVExecutableMissingFeatures = errors.New("mpv executable is missing required features")
+
+	mpvVersionRegex = regexp.MustCompile(`mpv v?(\d+)\.(\d+)(?:\.(\d+))?`)
 )
 
-func DiscoverMPVExecutable() (string, error) {
-	if _, err := os.Stat("/.flatpak-info"); err == nil {
-		if err := exec.Command("flatpak-spawn", "--host", "mpv", "--version").Run(); err == nil {
-			return "flatpak-spawn --host mpv", nil
-		}
+// MPVInfo describes an mpv executable and the features it supports
+type MPVInfo struct {
+	Command string   // Command to launch mpv with
+	Version string   // Version as reported by `mpv --version`, empty if it could not be parsed
+	Major   int      // Major version
+	Minor   int      // Minor version
+	Patch   int      // Patch version
+	Missing []string // Required features which this build of mpv lacks
+}
+
+// AtLeast reports whether the version is equal to or newer than the given one;
+// builds with unknown versions (e.g. from Git) are assumed to be recent enough
+func (i *MPVInfo) AtLeast(major, minor, patch int) bool {
+	if i.Version == "" {
+		return true
+	}
+
+	if i.Major != major {
+		return i.Major > major
+	}
+
+	if i.Minor != minor {
+		return i.Minor > minor
+	}
+
+	return i.Patch >= patch
+}
+
+// ProbeMPVExecutable checks that the given mpv command works and which of the required features it supports
+func ProbeMPVExecutable(command string) (*MPVInfo, error) {
+	argv, err := SplitCommand(command)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := exec.Command(argv[0], append(argv[1:], "--version")...).Output()
+	if err != nil {
+		return nil, err
+	}
+
+	info := &MPVInfo{
+		Command: command,
+		Missing: []string{},
+	}
 
-		if err := exec.Command("flatpak-spawn", "--host", "flatpak", "run", "io.mpv.Mpv", "--version").Run(); err == nil {
-			return "flatpak-spawn --host flatpak run io.mpv.Mpv", nil
+	if match := mpvVersionRegex.FindSubmatch(version); match != nil {
+		info.Major, _ = strconv.Atoi(string(match[1]))
+		info.Minor, _ = strconv.Atoi(string(match[2]))
+		if len(match[3]) > 0 {
+			info.Patch, _ = strconv.Atoi(string(match[3]))
 		}
 
-		return "", ErrNoWorkingMPVExecutableFound
+		info.Version = strings.TrimPrefix(strings.TrimPrefix(string(match[0]), "mpv "), "v")
+	}
+
+	// JSON IPC replaced the old text-based protocol in mpv 0.7.0
+	if !info.AtLeast(0, 7, 0) {
+		info.Missing = append(info.Missing, MPVFeatureJSONIPC)
+	}
+
+	rawOptions, err := exec.Command(argv[0], append(argv[1:], "--list-options")...).Output()
+	if err != nil {
+		return nil, err
 	}
 
-	if runtime.GOOS == "windows" {
-		if err := exec.Command("cmd.exe", "/c", "start", "mpv.exe", "--version").Run(); err == nil {
-			return "mpv.exe", nil
+	options := map[string]struct{}{}
+	scanner := bufio.NewScanner(bytes.NewReader(rawOptions))
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) > 0 && strings.HasPrefix(fields[0], "--") {
+			options[fields[0]] = struct{}{}
 		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
 
-		if err := exec.Command("cmd.exe", "/c", "start", `bin\mpv.exe`, "--version").Run(); err == nil {
-			return `bin\mpv.exe`, nil
+	for _, option := range []string{MPVFeatureInputIPCServer, MPVFeatureHTTPHeaderFields} {
+		if _, ok := options[option]; !ok {
+			info.Missing = append(info.Missing, option)
 		}
+	}
+
+	if len(info.Missing) > 0 {
+		return info, ErrMPVExecutableMissingFeatures
+	}
+
+	return info, nil
+}
+
+// DiscoverMPVExecutable searches for a working mpv executable; if only executables
+// which lack required features can be found, the first one is returned together
+// with `ErrMPVExecutableMissingFeatures`
+func DiscoverMPVExecutable() (*MPVInfo, error) {
+	candidates := []string{}
+	if _, err := os.Stat("/.flatpak-info"); err == nil {
+		candidates = append(
+			candidates,
+			"flatpak-spawn --host mpv",
+			"flatpak-spawn --host flatpak run io.mpv.Mpv",
+		)
 	} else {
-		if err := exec.Command("mpv", "--version").Run(); err == nil {
-			return "mpv", nil
+		if runtime.GOOS == "windows" {
+			candidates = append(
+				candidates,
+				"mpv.exe",
+				`bin\mpv.exe`,
+			)
+		} else {
+			candidates = append(candidates, "mpv")
+		}
+
+		candidates = append(candidates, "flatpak run io.mpv.Mpv")
+	}
+
+	var incomplete *MPVInfo
+	for _, candidate := range candidates {
+		info, err := ProbeMPVExecutable(candidate)
+		if err == nil {
+			return info, nil
+		}
+
+		if errors.Is(err, ErrMPVExecutableMissingFeatures) && incomplete == nil {
+			incomplete = info
 		}
 	}
 
-	if err := exec.Command("flatpak", "run", "io.mpv.Mpv", "--version").Run(); err == nil {
-		return "flatpak run io.mpv.Mpv", nil
+	if incomplete != nil {
+		return incomplete, ErrMPVExecutableMissingFeatures
 	}
 
-	return "", ErrNoWorkingMPVExecutableFound
+	return nil, ErrNoWorkingMPVExecutableFound
 }
 
 func ExecuteMPVRequest(ipcFile string, command func(encoder *json.Encoder, decoder *json.Decoder) error) error {
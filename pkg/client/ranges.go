@@ -0,0 +1,54 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var (
+	ErrRangesNotSupported = errors.New("server does not support range requests")
+)
+
+// HTTPReaderAt reads parts of a file from the gateway with range requests
+type HTTPReaderAt struct {
+	ctx    context.Context
+	client *GatewayClient
+	url    string
+}
+
+func NewHTTPReaderAt(ctx context.Context, client *GatewayClient, url string) *HTTPReaderAt {
+	return &HTTPReaderAt{
+		ctx:    ctx,
+		client: client,
+		url:    url,
+	}
+}
+
+func (r *HTTPReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	res, err := r.client.Get(r.ctx, r.url, off, off+int64(len(p))-1)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Close()
+
+	if res.Offset != off {
+		return 0, ErrRangesNotSupported
+	}
+
+	n, err := io.ReadFull(res, p)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return n, io.EOF
+	}
+
+	return n, err
+}
+
+// Size returns the size of the file
+func (r *HTTPReaderAt) Size() (int64, error) {
+	return r.client.Size(r.ctx, r.url)
+}
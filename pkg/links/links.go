@@ -0,0 +1,106 @@
+package links
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const (
+	Scheme = "multiplex"
+
+	ActionJoin = "join" // ActionJoin joins a session with a stream code, e.g. `multiplex://join/<community>:<password>:<key>`
+	ActionPlay = "play" // ActionPlay starts a session for a magnet link, e.g. `multiplex://play?magnet=<magnet>&path=<path>`
+)
+
+var (
+	ErrInvalidLink       = errors.New("invalid link")
+	ErrUnsupportedAction = errors.New("unsupported link action")
+	ErrInvalidStreamCode = errors.New("invalid stream code")
+	ErrInvalidMagnet     = errors.New("invalid magnet link")
+)
+
+// Link is a parsed `multiplex://` link
+type Link struct {
+	Action     string
+	StreamCode string // Stream code of the session to join
+	Magnet     string // Magnet link of the torrent to play
+	Path       string // Path of the media in the torrent to play; optional
+}
+
+// Parse parses a `multiplex://` link
+func Parse(raw string) (*Link, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidLink, err)
+	}
+
+	if u.Scheme != Scheme {
+		return nil, fmt.Errorf("%w: scheme is not %v", ErrInvalidLink, Scheme)
+	}
+
+	switch u.Host {
+	case ActionJoin:
+		streamCode := strings.Trim(u.Path, "/")
+		if err := ValidateStreamCode(streamCode); err != nil {
+			return nil, err
+		}
+
+		return &Link{
+			Action:     ActionJoin,
+			StreamCode: streamCode,
+		}, nil
+
+	case ActionPlay:
+		q := u.Query()
+
+		magnet, err := url.Parse(q.Get("magnet"))
+		if err != nil || magnet.Scheme != "magnet" {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidMagnet, q.Get("magnet"))
+		}
+
+		return &Link{
+			Action: ActionPlay,
+			Magnet: q.Get("magnet"),
+			Path:   q.Get("path"),
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedAction, u.Host)
+	}
+}
+
+// ValidateStreamCode checks that a stream code has the `<community>:<password>:<key>` format
+func ValidateStreamCode(streamCode string) error {
+	parts := strings.Split(streamCode, ":")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return fmt.Errorf("%w: %v", ErrInvalidStreamCode, streamCode)
+	}
+
+	return nil
+}
+
+// NewJoinLink returns a link which joins the session of a stream code
+func NewJoinLink(streamCode string) string {
+	return (&url.URL{
+		Scheme: Scheme,
+		Host:   ActionJoin,
+		Path:   "/" + streamCode,
+	}).String()
+}
+
+// NewPlayLink returns a link which starts a session for a magnet link; `path` can be empty
+func NewPlayLink(magnet, path string) string {
+	q := url.Values{}
+	q.Set("magnet", magnet)
+	if path != "" {
+		q.Set("path", path)
+	}
+
+	return (&url.URL{
+		Scheme:   Scheme,
+		Host:     ActionPlay,
+		RawQuery: q.Encode(),
+	}).String()
+}
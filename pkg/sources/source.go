@@ -0,0 +1,328 @@
+package sources
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/anacrolix/torrent/metainfo"
+	api "github.com/pojntfx/multiplex/pkg/api/webrtc/v1"
+	mpvClient "github.com/pojntfx/multiplex/pkg/client"
+	"github.com/pojntfx/multiplex/pkg/subtitles"
+)
+
+type Type string
+
+const (
+	TypeTorrent Type = api.SourceTorrent
+	TypeURL     Type = api.SourceURL
+	TypeFile    Type = api.SourceFile
+)
+
+var (
+	ErrUnsupportedSource = errors.New("unsupported media source")
+	ErrUnsupportedURL    = errors.New("only HTTP and HTTPS URLs can be played")
+	ErrNotAFile          = errors.New("media is not a regular file")
+	ErrMissingHash       = errors.New("media source does not contain a content hash")
+	ErrNoLocalCopy       = errors.New("no local copy of the media has been selected")
+	ErrHashMismatch      = errors.New("media is not the same as the media of the session")
+)
+
+// Source describes where the media of a session comes from. Torrents are streamed through the gateway
+// and identified by their info hash; URLs and files are played directly and identified by a content hash,
+// so that peers can verify that they play the same media.
+type Source struct {
+	Type   Type
+	Magnet string // Magnet link, if the source is a torrent
+	URL    string // URL of the media, if the source is a URL
+	File   string // Path of the local copy of the media, if the source is a file; every peer selects their own copy
+	Hash   string // Content hash of the media, if the source is a URL or a file
+	Size   int64  // Size of the media, if the source is a URL or a file
+}
+
+func NewTorrentSource(magnet string) Source {
+	return Source{
+		Type:   TypeTorrent,
+		Magnet: magnet,
+	}
+}
+
+func validateURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: %v", ErrUnsupportedURL, rawURL)
+	}
+
+	return nil
+}
+
+// NewURLSource creates a source for a plain HTTP(S) URL. Its content hash is computed with range requests;
+// media from servers which don't support them can still be played, but peers can't verify it.
+func NewURLSource(ctx context.Context, client *mpvClient.GatewayClient, rawURL string) (Source, error) {
+	if err := validateURL(rawURL); err != nil {
+		return Source{}, err
+	}
+
+	hash, size, err := HashURL(ctx, client, rawURL)
+	if err != nil && !errors.Is(err, mpvClient.ErrRangesNotSupported) {
+		return Source{}, err
+	}
+
+	return Source{
+		Type: TypeURL,
+		URL:  rawURL,
+		Hash: hash,
+		Size: size,
+	}, nil
+}
+
+// NewFileSource creates a source for a local file
+func NewFileSource(file string) (Source, error) {
+	file, err := filepath.Abs(file)
+	if err != nil {
+		return Source{}, err
+	}
+
+	hash, size, err := HashFile(file)
+	if err != nil {
+		return Source{}, err
+	}
+
+	return Source{
+		Type: TypeFile,
+		File: file,
+		Hash: hash,
+		Size: size,
+	}, nil
+}
+
+// HashURL computes the content hash of the media behind a URL with range requests
+func HashURL(ctx context.Context, client *mpvClient.GatewayClient, url string) (string, int64, error) {
+	r := mpvClient.NewHTTPReaderAt(ctx, client, url)
+
+	size, err := r.Size()
+	if err != nil {
+		return "", 0, err
+	}
+
+	hash, err := hashMedia(r, size)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return hash, size, nil
+}
+
+// HashFile computes the content hash of a local file
+func HashFile(file string) (string, int64, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return "", 0, err
+	}
+
+	if !stat.Mode().IsRegular() {
+		return "", 0, fmt.Errorf("%w: %v", ErrNotAFile, file)
+	}
+
+	hash, err := hashMedia(f, stat.Size())
+	if err != nil {
+		return "", 0, err
+	}
+
+	return hash, stat.Size(), nil
+}
+
+// hashMedia computes the movie hash of media (see `subtitles.Hash`); media which is too small to have
+// a movie hash is hashed completely instead
+func hashMedia(r io.ReaderAt, size int64) (string, error) {
+	if size >= subtitles.HashChunkSize {
+		return subtitles.Hash(r, size)
+	}
+
+	content := make([]byte, size)
+	if _, err := r.ReadAt(content, 0); err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+
+	hash := sha1.Sum(content)
+
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// FromMessage creates a source from the media description which is sent to peers; the local copy of a
+// file has to be selected with `WithFile` before it can be played
+func FromMessage(m api.Magnet) (Source, error) {
+	switch m.Source {
+	case "", api.SourceTorrent:
+		if _, err := metainfo.ParseMagnetUri(m.Magnet); err != nil {
+			return Source{}, err
+		}
+
+		return NewTorrentSource(m.Magnet), nil
+
+	case api.SourceURL:
+		// URLs from peers are passed to the media player, so anything but HTTP(S) is rejected
+		if err := validateURL(m.URL); err != nil {
+			return Source{}, err
+		}
+
+		return Source{
+			Type: TypeURL,
+			URL:  m.URL,
+			Hash: m.Hash,
+			Size: m.Size,
+		}, nil
+
+	case api.SourceFile:
+		if m.Hash == "" {
+			return Source{}, ErrMissingHash
+		}
+
+		return Source{
+			Type: TypeFile,
+			Hash: m.Hash,
+			Size: m.Size,
+		}, nil
+
+	default:
+		return Source{}, fmt.Errorf("%w: %v", ErrUnsupportedSource, m.Source)
+	}
+}
+
+// Message returns the media description which is sent to peers; the path of a local file is never sent
+func (s Source) Message(path, title, description string, subtitles []api.Subtitle) *api.Magnet {
+	return api.NewMedia(string(s.Type), s.Magnet, s.URL, s.Hash, s.Size, path, title, description, subtitles)
+}
+
+// WithFile returns a copy of a file source which plays a local copy of the media
+func (s Source) WithFile(file string) Source {
+	s.File = file
+
+	return s
+}
+
+// Name returns a name for the media of a URL or file, which is used instead of the path of a file in a torrent
+func (s Source) Name() string {
+	switch s.Type {
+	case TypeURL:
+		if u, err := url.Parse(s.URL); err == nil {
+			if name := path.Base(u.Path); name != "." && name != "/" {
+				return name
+			}
+
+			return u.Host
+		}
+
+		return s.URL
+
+	case TypeFile:
+		return filepath.Base(s.File)
+
+	default:
+		return ""
+	}
+}
+
+// ID identifies the media of a source: the info hash for torrents, and the content hash for URLs and files
+func (s Source) ID() (string, error) {
+	switch s.Type {
+	case TypeTorrent:
+		m, err := metainfo.ParseMagnetUri(s.Magnet)
+		if err != nil {
+			return "", err
+		}
+
+		return m.InfoHash.HexString(), nil
+
+	case TypeURL, TypeFile:
+		if s.Hash != "" {
+			return s.Hash, nil
+		}
+
+		// Media from servers which don't support range requests can't be hashed, so the URL identifies it instead
+		id := sha1.Sum([]byte(s.URL))
+
+		return hex.EncodeToString(id[:]), nil
+
+	default:
+		return "", fmt.Errorf("%w: %v", ErrUnsupportedSource, s.Type)
+	}
+}
+
+// StreamURL returns the URL or path which is passed to the media player; `path` is the path of the media in a torrent
+func (s Source) StreamURL(apiAddr, path string) (string, error) {
+	switch s.Type {
+	case TypeTorrent:
+		return mpvClient.StreamURL(apiAddr, s.Magnet, path)
+
+	case TypeURL:
+		return s.URL, nil
+
+	case TypeFile:
+		if s.File == "" {
+			return "", ErrNoLocalCopy
+		}
+
+		return s.File, nil
+
+	default:
+		return "", fmt.Errorf("%w: %v", ErrUnsupportedSource, s.Type)
+	}
+}
+
+// Verify checks that the media of a URL or the local copy of a file has the content hash of the source;
+// torrents are verified by the gateway, and URLs without a content hash can't be verified
+func (s Source) Verify(ctx context.Context, client *mpvClient.GatewayClient) error {
+	var (
+		hash string
+		size int64
+		err  error
+	)
+	switch s.Type {
+	case TypeTorrent:
+		return nil
+
+	case TypeURL:
+		if s.Hash == "" {
+			return nil
+		}
+
+		hash, size, err = HashURL(ctx, client, s.URL)
+
+	case TypeFile:
+		if s.File == "" {
+			return ErrNoLocalCopy
+		}
+
+		hash, size, err = HashFile(s.File)
+
+	default:
+		return fmt.Errorf("%w: %v", ErrUnsupportedSource, s.Type)
+	}
+	if err != nil {
+		return err
+	}
+
+	if hash != s.Hash || size != s.Size {
+		return ErrHashMismatch
+	}
+
+	return nil
+}
@@ -0,0 +1,222 @@
+package subtitles
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// Subtitle files are small, so larger archive entries are most likely not subtitles (or archive bombs)
+	maxArchiveEntrySize = 10 * 1024 * 1024
+
+	// MaxArchiveSize is the maximum size of an archive and of its decompressed content; archives are downloaded
+	// completely before subtitles can be listed, so large archives (i.e. of the media itself) are rejected
+	MaxArchiveSize = 50 * 1024 * 1024
+
+	// Maximum number of entries in an archive, including the ones which aren't subtitles
+	maxArchiveEntries = 1000
+)
+
+var (
+	ErrUnsupportedArchive = errors.New("unsupported archive format")
+	ErrArchiveEntryTooBig = errors.New("archive entry is too big")
+	ErrArchiveTooBig      = errors.New("archive is too big")
+	ErrTooManyEntries     = errors.New("archive has too many entries")
+
+	subtitleExtensions = []string{".srt", ".vtt", ".ass", ".ssa", ".sub"}
+)
+
+// ArchiveEntry is a subtitle file which has been extracted from an archive
+type ArchiveEntry struct {
+	Name string // Path of the entry in the archive
+	Path string // Path of the extracted file
+}
+
+// IsSubtitle reports whether a file name has the extension of a supported subtitle format
+func IsSubtitle(name string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	for _, candidate := range subtitleExtensions {
+		if ext == candidate {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsArchive reports whether a file name has the extension of a supported archive format
+func IsArchive(name string) bool {
+	name = strings.ToLower(name)
+
+	return strings.HasSuffix(name, ".zip") || strings.HasSuffix(name, ".tar") || strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz")
+}
+
+// ExtractArchive extracts all subtitle files from a zip or (gzip-compressed) tar archive into `dir`.
+// Entries are stored under generated names, so paths in the archive can't escape `dir`.
+func ExtractArchive(name string, r io.Reader, dir string) ([]ArchiveEntry, error) {
+	lowerName := strings.ToLower(name)
+
+	r = &limitedReader{r, MaxArchiveSize}
+
+	switch {
+	case strings.HasSuffix(lowerName, ".zip"):
+		return extractZip(r, dir)
+	case strings.HasSuffix(lowerName, ".tar"):
+		return extractTar(r, dir)
+	case strings.HasSuffix(lowerName, ".tar.gz"), strings.HasSuffix(lowerName, ".tgz"):
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer gr.Close()
+
+		return extractTar(&limitedReader{gr, MaxArchiveSize}, dir)
+	default:
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedArchive, name)
+	}
+}
+
+// limitedReader reads at most `n` bytes from `r`, like `io.LimitReader`, but fails with `ErrArchiveTooBig`
+// instead of ending early so that truncated archives aren't mistaken for complete ones
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n < 0 {
+		return 0, ErrArchiveTooBig
+	}
+
+	// One byte more than allowed is read so that archives of exactly the maximum size can be told apart
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return n, ErrArchiveTooBig
+	}
+
+	return n, err
+}
+
+func extractEntry(name string, r io.Reader, dir string, index int) (ArchiveEntry, error) {
+	entry := ArchiveEntry{
+		Name: name,
+		Path: filepath.Join(dir, fmt.Sprintf("%v-%v", index, path.Base(name))),
+	}
+
+	f, err := os.Create(entry.Path)
+	if err != nil {
+		return entry, err
+	}
+	defer f.Close()
+
+	n, err := io.Copy(f, io.LimitReader(r, maxArchiveEntrySize+1))
+	if err != nil {
+		return entry, err
+	}
+
+	if n > maxArchiveEntrySize {
+		_ = os.Remove(entry.Path)
+
+		return entry, fmt.Errorf("%w: %v", ErrArchiveEntryTooBig, name)
+	}
+
+	return entry, nil
+}
+
+func extractTar(r io.Reader, dir string) ([]ArchiveEntry, error) {
+	entries := []ArchiveEntry{}
+
+	tr := tar.NewReader(r)
+	for i := 0; ; i++ {
+		hdr, err := tr.Next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			return nil, err
+		}
+
+		if i >= maxArchiveEntries {
+			return nil, ErrTooManyEntries
+		}
+
+		if hdr.Typeflag != tar.TypeReg || !IsSubtitle(hdr.Name) {
+			continue
+		}
+
+		entry, err := extractEntry(hdr.Name, tr, dir, len(entries))
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+func extractZip(r io.Reader, dir string) ([]ArchiveEntry, error) {
+	// Zip archives have their index at the end, so they need to be read from a file
+	archive, err := os.CreateTemp(dir, "archive-*.zip")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(archive.Name())
+	defer archive.Close()
+
+	size, err := io.Copy(archive, r)
+	if err != nil {
+		return nil, err
+	}
+
+	zr, err := zip.NewReader(archive, size)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(zr.File) > maxArchiveEntries {
+		return nil, ErrTooManyEntries
+	}
+
+	entries := []ArchiveEntry{}
+	for _, file := range zr.File {
+		if file.FileInfo().IsDir() || !IsSubtitle(file.Name) {
+			continue
+		}
+
+		if err := func() error {
+			fr, err := file.Open()
+			if err != nil {
+				return err
+			}
+			defer fr.Close()
+
+			entry, err := extractEntry(file.Name, fr, dir, len(entries))
+			if err != nil {
+				return err
+			}
+
+			entries = append(entries, entry)
+
+			return nil
+		}(); err != nil {
+			return nil, err
+		}
+	}
+
+	return entries, nil
+}
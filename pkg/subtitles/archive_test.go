@@ -0,0 +1,219 @@
+package subtitles
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type archiveFile struct {
+	name    string
+	content string
+}
+
+// zeroReader is an endless stream of zeros, which is used to create large archives without keeping them in memory
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+
+	return len(p), nil
+}
+
+func newZip(t *testing.T, files []archiveFile) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, file := range files {
+		w, err := zw.Create(file.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := io.WriteString(w, file.content); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func newTar(t *testing.T, files []archiveFile) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, file := range files {
+		hdr := &tar.Header{
+			Name:     file.name,
+			Mode:     0600,
+			Size:     int64(len(file.content)),
+			Typeflag: tar.TypeReg,
+		}
+		if strings.HasSuffix(file.name, "/") {
+			hdr.Typeflag = tar.TypeDir
+			hdr.Size = 0
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := io.WriteString(tw, file.content); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func gzipBytes(t *testing.T, r io.Reader) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	if _, err := io.Copy(gw, r); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+// newLargeTar returns a tar archive with a single file of `size` bytes, which is generated while it is being read
+func newLargeTar(t *testing.T, name string, size int64) io.Reader {
+	t.Helper()
+
+	var hdr bytes.Buffer
+	if err := tar.NewWriter(&hdr).WriteHeader(&tar.Header{
+		Name:     name,
+		Mode:     0600,
+		Size:     size,
+		Typeflag: tar.TypeReg,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	return io.MultiReader(&hdr, io.LimitReader(zeroReader{}, size), bytes.NewReader(make([]byte, 1024)))
+}
+
+func TestExtractArchive(t *testing.T) {
+	files := []archiveFile{
+		{"Subs/", ""},
+		{"Subs/English.srt", "1\r\n00:00:01,000 --> 00:00:02,000\r\nHello\r\n"},
+		{"Subs/German.ass", "[Script Info]\r\n"},
+		{"Movie.nfo", "Not a subtitle"},
+		{"../../escape.vtt", "WEBVTT\r\n"},
+	}
+
+	tests := []struct {
+		name    string
+		archive func(t *testing.T) []byte
+	}{
+		{"subtitles.zip", func(t *testing.T) []byte { return newZip(t, files) }},
+		{"subtitles.tar", func(t *testing.T) []byte { return newTar(t, files) }},
+		{"subtitles.tar.gz", func(t *testing.T) []byte { return gzipBytes(t, bytes.NewReader(newTar(t, files))) }},
+		{"Subtitles.TGZ", func(t *testing.T) []byte { return gzipBytes(t, bytes.NewReader(newTar(t, files))) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			entries, err := ExtractArchive(tt.name, bytes.NewReader(tt.archive(t)), dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := []archiveFile{files[1], files[2], files[4]}
+			if len(entries) != len(want) {
+				t.Fatalf("ExtractArchive() returned %v entries, want %v: %+v", len(entries), len(want), entries)
+			}
+
+			for i, entry := range entries {
+				if entry.Name != want[i].name {
+					t.Errorf("entry %v name = %q, want %q", i, entry.Name, want[i].name)
+				}
+
+				if filepath.Dir(entry.Path) != dir {
+					t.Errorf("entry %v was extracted to %q, want it in %q", i, entry.Path, dir)
+				}
+
+				content, err := os.ReadFile(entry.Path)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if string(content) != want[i].content {
+					t.Errorf("entry %v content = %q, want %q", i, content, want[i].content)
+				}
+			}
+		})
+	}
+}
+
+func TestExtractArchiveLimits(t *testing.T) {
+	manyFiles := []archiveFile{}
+	for i := range maxArchiveEntries + 1 {
+		manyFiles = append(manyFiles, archiveFile{fmt.Sprintf("%v.txt", i), ""})
+	}
+
+	tests := []struct {
+		name    string
+		archive func(t *testing.T) io.Reader
+		wantErr error
+	}{
+		{"big.zip", func(t *testing.T) io.Reader {
+			return io.LimitReader(zeroReader{}, MaxArchiveSize+1)
+		}, ErrArchiveTooBig},
+		{"big.tar", func(t *testing.T) io.Reader {
+			return newLargeTar(t, "Movie.mkv", MaxArchiveSize)
+		}, ErrArchiveTooBig},
+		{"bomb.tar.gz", func(t *testing.T) io.Reader {
+			return bytes.NewReader(gzipBytes(t, newLargeTar(t, "Movie.mkv", MaxArchiveSize)))
+		}, ErrArchiveTooBig},
+		{"entry.tar", func(t *testing.T) io.Reader {
+			return newLargeTar(t, "Movie.srt", maxArchiveEntrySize+1)
+		}, ErrArchiveEntryTooBig},
+		{"entry.zip", func(t *testing.T) io.Reader {
+			return bytes.NewReader(newZip(t, []archiveFile{{"Movie.srt", strings.Repeat("a", maxArchiveEntrySize+1)}}))
+		}, ErrArchiveEntryTooBig},
+		{"many.tar", func(t *testing.T) io.Reader {
+			return bytes.NewReader(newTar(t, manyFiles))
+		}, ErrTooManyEntries},
+		{"many.zip", func(t *testing.T) io.Reader {
+			return bytes.NewReader(newZip(t, manyFiles))
+		}, ErrTooManyEntries},
+		{"subtitles.rar", func(t *testing.T) io.Reader {
+			return bytes.NewReader(nil)
+		}, ErrUnsupportedArchive},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ExtractArchive(tt.name, tt.archive(t), t.TempDir()); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ExtractArchive() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
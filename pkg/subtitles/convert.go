@@ -0,0 +1,235 @@
+package subtitles
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultMicroDVDFrameRate is used for MicroDVD content which doesn't declare its frame rate
+	DefaultMicroDVDFrameRate = 23.976
+)
+
+var (
+	ErrUnsupportedConversion = errors.New("unsupported subtitle conversion")
+)
+
+// Cue is a single subtitle
+type Cue struct {
+	Start time.Duration // Time to show the subtitle at
+	End   time.Duration // Time to hide the subtitle at
+	Text  string        // Text of the subtitle, with lines separated by `\n`
+}
+
+// Convert converts UTF-8 subtitle content from one format to another; ASS can't be converted since its styling would get lost
+func Convert(content []byte, from, to Format) ([]byte, error) {
+	if from == to {
+		return content, nil
+	}
+
+	var (
+		cues []Cue
+		err  error
+	)
+	switch from {
+	case FormatSRT:
+		cues, err = parseTimed(content, srtTimingRegex)
+	case FormatVTT:
+		cues, err = parseTimed(content, vttTimingRegex)
+	case FormatMicroDVD:
+		fps, ok := MicroDVDFrameRate(content)
+		if !ok {
+			fps = DefaultMicroDVDFrameRate
+		}
+
+		cues, err = parseMicroDVD(content, fps)
+	default:
+		return nil, fmt.Errorf("%w: from %q to %q", ErrUnsupportedConversion, from, to)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch to {
+	case FormatSRT:
+		return writeSRT(cues), nil
+	case FormatVTT:
+		return writeVTT(cues), nil
+	default:
+		return nil, fmt.Errorf("%w: from %q to %q", ErrUnsupportedConversion, from, to)
+	}
+}
+
+func parseTimestamp(hours, minutes, seconds, milliseconds string) time.Duration {
+	var timestamp time.Duration
+	for _, part := range []struct {
+		value string
+		unit  time.Duration
+	}{
+		{hours, time.Hour},
+		{minutes, time.Minute},
+		{seconds, time.Second},
+		{milliseconds, time.Millisecond},
+	} {
+		if part.value == "" {
+			continue
+		}
+
+		// The regular expressions only match digits, so this can't fail
+		value, _ := strconv.Atoi(part.value)
+		timestamp += time.Duration(value) * part.unit
+	}
+
+	return timestamp
+}
+
+// parseTimed parses formats which consist of blocks with a timing line followed by text, such as SRT and VTT
+func parseTimed(content []byte, timingRegex *regexp.Regexp) ([]Cue, error) {
+	cues := []Cue{}
+
+	var current *Cue
+	scanner := bufio.NewScanner(bytes.NewReader(bytes.TrimPrefix(content, bomUTF8)))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(content)+1)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		if match := timingRegex.FindStringSubmatch(line); match != nil {
+			if current != nil {
+				cues = append(cues, *current)
+			}
+
+			current = &Cue{
+				Start: parseTimestamp(match[1], match[2], match[3], match[4]),
+				End:   parseTimestamp(match[5], match[6], match[7], match[8]),
+			}
+
+			continue
+		}
+
+		if current == nil {
+			continue
+		}
+
+		if strings.TrimSpace(line) == "" {
+			cues = append(cues, *current)
+			current = nil
+
+			continue
+		}
+
+		if current.Text != "" {
+			current.Text += "\n"
+		}
+		current.Text += line
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if current != nil {
+		cues = append(cues, *current)
+	}
+
+	return cues, nil
+}
+
+func parseMicroDVD(content []byte, fps float64) ([]Cue, error) {
+	cues := []Cue{}
+
+	frameToDuration := func(frame string) time.Duration {
+		f, _ := strconv.Atoi(frame)
+
+		return time.Duration(float64(f) / fps * float64(time.Second))
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(bytes.TrimPrefix(content, bomUTF8)))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(content)+1)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if microDVDHeaderRegex.MatchString(line) {
+			continue
+		}
+
+		match := microDVDRegex.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+
+		cue := Cue{
+			Start: frameToDuration(match[1]),
+			Text:  match[3],
+		}
+
+		// An empty end frame means that the subtitle is shown until the next one
+		if match[2] != "" {
+			cue.End = frameToDuration(match[2])
+		}
+
+		// Lines are separated with `|`; formatting tags such as `{y:i}` are dropped
+		lines := strings.Split(cue.Text, "|")
+		for i, l := range lines {
+			for strings.HasPrefix(l, "{") && strings.Contains(l, "}") {
+				l = l[strings.Index(l, "}")+1:]
+			}
+
+			lines[i] = l
+		}
+		cue.Text = strings.Join(lines, "\n")
+
+		cues = append(cues, cue)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range cues {
+		if cues[i].End != 0 {
+			continue
+		}
+
+		if i+1 < len(cues) {
+			cues[i].End = cues[i+1].Start
+		} else {
+			cues[i].End = cues[i].Start + 5*time.Second
+		}
+	}
+
+	return cues, nil
+}
+
+func formatTimestamp(timestamp time.Duration, separator string) string {
+	return fmt.Sprintf(
+		"%02d:%02d:%02d%v%03d",
+		int(timestamp.Hours()),
+		int(timestamp.Minutes())%60,
+		int(timestamp.Seconds())%60,
+		separator,
+		timestamp.Milliseconds()%1000,
+	)
+}
+
+func writeSRT(cues []Cue) []byte {
+	var out bytes.Buffer
+	for i, cue := range cues {
+		fmt.Fprintf(&out, "%v\n%v --> %v\n%v\n\n", i+1, formatTimestamp(cue.Start, ","), formatTimestamp(cue.End, ","), cue.Text)
+	}
+
+	return out.Bytes()
+}
+
+func writeVTT(cues []Cue) []byte {
+	var out bytes.Buffer
+	out.WriteString("WEBVTT\n\n")
+	for _, cue := range cues {
+		fmt.Fprintf(&out, "%v --> %v\n%v\n\n", formatTimestamp(cue.Start, "."), formatTimestamp(cue.End, "."), cue.Text)
+	}
+
+	return out.Bytes()
+}
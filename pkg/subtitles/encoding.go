@@ -0,0 +1,124 @@
+package subtitles
+
+import (
+	"bytes"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// Encoding is a character encoding of a subtitle file
+type Encoding string
+
+const (
+	EncodingUTF8        Encoding = "utf-8"        // EncodingUTF8 is UTF-8, with or without a byte order mark
+	EncodingUTF16LE     Encoding = "utf-16le"     // EncodingUTF16LE is little-endian UTF-16 with a byte order mark
+	EncodingUTF16BE     Encoding = "utf-16be"     // EncodingUTF16BE is big-endian UTF-16 with a byte order mark
+	EncodingWindows1252 Encoding = "windows-1252" // EncodingWindows1252 is the legacy Western European Windows code page
+	EncodingGBK         Encoding = "gbk"          // EncodingGBK is the legacy Simplified Chinese Windows code page
+)
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+const (
+	// Share of non-ASCII bytes which need to form double-byte characters for content to be detected as GBK
+	gbkThreshold = 0.95
+
+	// Share of double-byte characters which may be part of a word in a Latin script for content to be detected as GBK
+	gbkMaxEmbeddedShare = 0.3
+)
+
+// IsText reports whether content is text instead of a binary format; text in the supported
+// encodings never contains NUL bytes unless it is UTF-16, which is only detected with a byte order mark
+func IsText(content []byte) bool {
+	if bytes.HasPrefix(content, bomUTF16LE) || bytes.HasPrefix(content, bomUTF16BE) {
+		return true
+	}
+
+	return bytes.IndexByte(content, 0) == -1
+}
+
+func isASCIILetter(b byte) bool {
+	return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
+
+// DetectEncoding guesses the character encoding of subtitle content. Byte order marks and valid
+// UTF-8 are detected reliably; everything else is assumed to be Windows-1252, unless nearly all non-ASCII
+// bytes form double-byte characters which mostly aren't part of words in a Latin script, in which case it is
+// assumed to be GBK.
+func DetectEncoding(content []byte) Encoding {
+	switch {
+	case bytes.HasPrefix(content, bomUTF8):
+		return EncodingUTF8
+	case bytes.HasPrefix(content, bomUTF16LE):
+		return EncodingUTF16LE
+	case bytes.HasPrefix(content, bomUTF16BE):
+		return EncodingUTF16BE
+	case utf8.Valid(content):
+		return EncodingUTF8
+	}
+
+	nonASCII, gbk, embedded := 0, 0, 0
+	for i := 0; i < len(content); i++ {
+		if content[i] < 0x80 {
+			continue
+		}
+
+		nonASCII++
+
+		// Most GBK characters in practice are from GB2312, where both bytes are non-ASCII. Accented
+		// letters in Windows-1252 on the other hand are usually surrounded by ASCII.
+		if content[i] >= 0x81 && content[i] <= 0xFE && i+1 < len(content) && content[i+1] >= 0x80 && content[i+1] <= 0xFE {
+			nonASCII++
+			gbk += 2
+
+			// Adjacent accented letters in Windows-1252, such as "ção" in Portuguese, look like double-byte
+			// characters too, but they are surrounded by other letters
+			if (i > 0 && isASCIILetter(content[i-1])) || (i+2 < len(content) && isASCIILetter(content[i+2])) {
+				embedded++
+			}
+
+			i++
+		}
+	}
+
+	if nonASCII > 0 && float64(gbk)/float64(nonASCII) >= gbkThreshold && float64(embedded) <= float64(gbk/2)*gbkMaxEmbeddedShare {
+		return EncodingGBK
+	}
+
+	return EncodingWindows1252
+}
+
+func (e Encoding) decoder() *encoding.Decoder {
+	switch e {
+	case EncodingUTF16LE:
+		return unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM).NewDecoder()
+	case EncodingUTF16BE:
+		return unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM).NewDecoder()
+	case EncodingWindows1252:
+		return charmap.Windows1252.NewDecoder()
+	case EncodingGBK:
+		return simplifiedchinese.GBK.NewDecoder()
+	default:
+		return unicode.UTF8BOM.NewDecoder()
+	}
+}
+
+// ToUTF8 converts subtitle content to UTF-8 without a byte order mark and returns the detected encoding
+func ToUTF8(content []byte) ([]byte, Encoding, error) {
+	enc := DetectEncoding(content)
+
+	converted, err := enc.decoder().Bytes(content)
+	if err != nil {
+		return nil, enc, err
+	}
+
+	return converted, enc, nil
+}
@@ -0,0 +1,150 @@
+package subtitles
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"unicode/utf8"
+)
+
+func readTestdata(t *testing.T, name string) []byte {
+	t.Helper()
+
+	content, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return content
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		file         string
+		wantEncoding Encoding
+		wantFormat   Format
+		wantText     string
+	}{
+		{"utf8.srt", EncodingUTF8, FormatSRT, "C'était un été très chaud."},
+		{"utf8-bom.srt", EncodingUTF8, FormatSRT, "Où est la bibliothèque ?"},
+		{"utf16le.srt", EncodingUTF16LE, FormatSRT, "Ça va, merci. À bientôt !"},
+		{"utf16be.srt", EncodingUTF16BE, FormatSRT, "Ça va, merci. À bientôt !"},
+		{"windows1252-fr.srt", EncodingWindows1252, FormatSRT, "C'était un été très chaud."},
+		{"windows1252-pt.srt", EncodingWindows1252, FormatSRT, "A situação da nação é complicada."},
+		{"gbk.srt", EncodingGBK, FormatSRT, "这是一个测试字幕文件。"},
+		{"webvtt.vtt", EncodingUTF8, FormatVTT, "Où est la bibliothèque ?"},
+		{"ass.ass", EncodingWindows1252, FormatASS, "A situação da nação é complicada."},
+		{"microdvd.sub", EncodingWindows1252, FormatMicroDVD, "C'était un été très chaud."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			content := readTestdata(t, tt.file)
+
+			if !IsText(content) {
+				t.Fatalf("IsText() = false, want true")
+			}
+
+			normalized, encoding, format, err := Normalize(content)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if encoding != tt.wantEncoding {
+				t.Errorf("encoding = %q, want %q", encoding, tt.wantEncoding)
+			}
+
+			if format != tt.wantFormat {
+				t.Errorf("format = %q, want %q", format, tt.wantFormat)
+			}
+
+			if !utf8.Valid(normalized) {
+				t.Errorf("normalized content is not valid UTF-8")
+			}
+
+			if bytes.HasPrefix(normalized, bomUTF8) {
+				t.Errorf("normalized content starts with a byte order mark")
+			}
+
+			if !bytes.Contains(normalized, []byte(tt.wantText)) {
+				t.Errorf("normalized content does not contain %q:\n%s", tt.wantText, normalized)
+			}
+		})
+	}
+}
+
+func TestIsText(t *testing.T) {
+	tests := []struct {
+		file string
+		want bool
+	}{
+		{"utf8.srt", true},
+		{"utf16le.srt", true},
+		{"utf16be.srt", true},
+		{"windows1252-pt.srt", true},
+		{"gbk.srt", true},
+		{"microdvd.sub", true},
+		{"vobsub.idx", true},
+		{"vobsub.sub", false},
+		{"pgs.sup", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			if got := IsText(readTestdata(t, tt.file)); got != tt.want {
+				t.Fatalf("IsText() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectEncodingPrefersWindows1252ForLatinText(t *testing.T) {
+	tests := []struct {
+		name    string
+		content []byte
+		want    Encoding
+	}{
+		// "ção" and "ões" are E7 E3 and F5 65 in Windows-1252, which look like a double-byte character
+		{"portuguese words", []byte("informa\xe7\xe3o a\xe7\xe3o na\xe7\xe3o elei\xe7\xf5es"), EncodingWindows1252},
+		{"single accented letters", []byte("caf\xe9 na\xefve"), EncodingWindows1252},
+		{"chinese", []byte("\xc4\xe3\xba\xc3\xa3\xac\xca\xc0\xbd\xe7\xa1\xa3"), EncodingGBK},
+		{"chinese with latin words", []byte("\xc4\xe3\xba\xc3 OK \xca\xc0\xbd\xe7 \xce\xd2\xc3\xc7\xc3\xf7\xcc\xec\xbc\xfb"), EncodingGBK},
+		{"ascii", []byte("plain text"), EncodingUTF8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectEncoding(tt.content); got != tt.want {
+				t.Fatalf("DetectEncoding() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertMicroDVD(t *testing.T) {
+	normalized, _, format, err := Normalize(readTestdata(t, "microdvd.sub"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fps, ok := MicroDVDFrameRate(normalized)
+	if !ok || fps != 25 {
+		t.Fatalf("MicroDVDFrameRate() = %v, %v, want 25, true", fps, ok)
+	}
+
+	converted, err := Convert(normalized, format, FormatSRT)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := DetectFormat(converted); got != FormatSRT {
+		t.Fatalf("DetectFormat() = %q, want %q", got, FormatSRT)
+	}
+
+	for _, want := range []string{"00:00:01,000 --> 00:00:02,480", "00:00:03,000 --> 00:00:04,480", "Où est la bibliothèque ?"} {
+		if !bytes.Contains(converted, []byte(want)) {
+			t.Errorf("converted content does not contain %q:\n%s", want, converted)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package subtitles
+
+import (
+	"bufio"
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Format is a subtitle file format
+type Format string
+
+const (
+	FormatUnknown  Format = ""    // FormatUnknown is any format which could not be recognized
+	FormatSRT      Format = "srt" // FormatSRT is SubRip
+	FormatVTT      Format = "vtt" // FormatVTT is WebVTT
+	FormatASS      Format = "ass" // FormatASS is Advanced SubStation Alpha (and SubStation Alpha)
+	FormatMicroDVD Format = "sub" // FormatMicroDVD is MicroDVD, which uses frame numbers instead of timestamps
+)
+
+var (
+	srtTimingRegex      = regexp.MustCompile(`(\d+):(\d{2}):(\d{2})[,.](\d{3})\s*-->\s*(\d+):(\d{2}):(\d{2})[,.](\d{3})`)
+	vttTimingRegex      = regexp.MustCompile(`(?:(\d+):)?(\d{2}):(\d{2})\.(\d{3})\s*-->\s*(?:(\d+):)?(\d{2}):(\d{2})\.(\d{3})`)
+	microDVDRegex       = regexp.MustCompile(`^\{(\d+)\}\{(\d*)\}(.*)$`)
+	microDVDHeaderRegex = regexp.MustCompile(`^\{1\}\{1\}(\d+(?:\.\d+)?)$`)
+)
+
+// Extension returns the file extension for the format, including the leading dot
+func (f Format) Extension() string {
+	if f == FormatUnknown {
+		return ""
+	}
+
+	return "." + string(f)
+}
+
+// DetectFormat recognizes the format of UTF-8 subtitle content
+func DetectFormat(content []byte) Format {
+	content = bytes.TrimPrefix(content, bomUTF8)
+
+	trimmed := bytes.TrimSpace(content)
+	if bytes.HasPrefix(trimmed, []byte("WEBVTT")) {
+		return FormatVTT
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(trimmed))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(trimmed)+1)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		switch {
+		case strings.EqualFold(line, "[Script Info]"):
+			return FormatASS
+		case microDVDRegex.MatchString(line):
+			return FormatMicroDVD
+		case srtTimingRegex.MatchString(line):
+			return FormatSRT
+		}
+	}
+
+	return FormatUnknown
+}
+
+// MicroDVDFrameRate returns the frame rate declared in the first line of MicroDVD content, if any
+func MicroDVDFrameRate(content []byte) (float64, bool) {
+	firstLine, _, _ := bytes.Cut(bytes.TrimSpace(bytes.TrimPrefix(content, bomUTF8)), []byte("\n"))
+
+	match := microDVDHeaderRegex.FindSubmatch(bytes.TrimSpace(firstLine))
+	if match == nil {
+		return 0, false
+	}
+
+	fps, err := strconv.ParseFloat(string(match[1]), 64)
+	if err != nil || fps <= 0 {
+		return 0, false
+	}
+
+	return fps, true
+}
+
+// Normalize converts subtitle content to UTF-8 and recognizes its format
+func Normalize(content []byte) ([]byte, Encoding, Format, error) {
+	converted, enc, err := ToUTF8(content)
+	if err != nil {
+		return nil, enc, FormatUnknown, err
+	}
+
+	return converted, enc, DetectFormat(converted), nil
+}
@@ -0,0 +1,41 @@
+package subtitles
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	// HashChunkSize is the number of bytes at the start and end of a file which are included in its hash
+	HashChunkSize = 64 * 1024
+)
+
+var (
+	ErrFileTooSmall = errors.New("file is too small to be hashed")
+)
+
+// Hash computes the 64-bit movie hash used by OpenSubtitles and compatible providers, which is the
+// file size plus the sum of the first and last 64 KiB of the file interpreted as little-endian uint64s.
+// Only the first and last chunks are read, so `r` can be backed by byte-range requests.
+func Hash(r io.ReaderAt, size int64) (string, error) {
+	if size < HashChunkSize {
+		return "", ErrFileTooSmall
+	}
+
+	hash := uint64(size)
+
+	chunk := make([]byte, HashChunkSize)
+	for _, offset := range []int64{0, size - HashChunkSize} {
+		if _, err := r.ReadAt(chunk, offset); err != nil && !errors.Is(err, io.EOF) {
+			return "", err
+		}
+
+		for i := 0; i < len(chunk); i += 8 {
+			hash += binary.LittleEndian.Uint64(chunk[i:])
+		}
+	}
+
+	return fmt.Sprintf("%016x", hash), nil
+}
@@ -0,0 +1,181 @@
+package subtitles
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrProviderRequestFailed = errors.New("subtitle provider request failed")
+)
+
+// SearchResult is a subtitle file which is available from a provider
+type SearchResult struct {
+	ID       string // Provider-specific ID of the file
+	Name     string // Name of the file
+	Language string // Language code of the subtitles
+	Release  string // Release the subtitles were made for
+}
+
+// Provider searches and downloads subtitles
+type Provider interface {
+	// SearchByHash searches for subtitles which match a movie hash and size (see `Hash`) in the given ISO 639-1 languages, or in all languages if none are given
+	SearchByHash(ctx context.Context, hash string, size int64, languages []string) ([]SearchResult, error)
+
+	// Download fetches the content of a subtitle file
+	Download(ctx context.Context, result SearchResult) (io.ReadCloser, error)
+}
+
+// HTTPProvider is a client for providers which implement the OpenSubtitles REST API
+type HTTPProvider struct {
+	baseURL   string
+	apiKey    string
+	userAgent string
+	client    *http.Client
+}
+
+func NewHTTPProvider(baseURL, apiKey, userAgent string, client *http.Client) *HTTPProvider {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &HTTPProvider{
+		baseURL:   strings.TrimSuffix(baseURL, "/"),
+		apiKey:    apiKey,
+		userAgent: userAgent,
+		client:    client,
+	}
+}
+
+func (p *HTTPProvider) do(ctx context.Context, method, endpoint string, body any, response any) error {
+	var reqBody io.Reader = http.NoBody
+	if body != nil {
+		content, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+
+		reqBody = bytes.NewReader(content)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, p.baseURL+endpoint, reqBody)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", p.userAgent)
+	if p.apiKey != "" {
+		req.Header.Set("Api-Key", p.apiKey)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: %v %v: %v", ErrProviderRequestFailed, method, endpoint, res.Status)
+	}
+
+	return json.NewDecoder(res.Body).Decode(response)
+}
+
+type searchResponse struct {
+	Data []struct {
+		Attributes struct {
+			Language string `json:"language"`
+			Release  string `json:"release"`
+			Files    []struct {
+				FileID   int    `json:"file_id"`
+				FileName string `json:"file_name"`
+			} `json:"files"`
+		} `json:"attributes"`
+	} `json:"data"`
+}
+
+type downloadRequest struct {
+	FileID int `json:"file_id"`
+}
+
+type downloadResponse struct {
+	Link string `json:"link"`
+}
+
+func (p *HTTPProvider) SearchByHash(ctx context.Context, hash string, size int64, languages []string) ([]SearchResult, error) {
+	query := url.Values{}
+	query.Set("moviehash", hash)
+	if size > 0 {
+		query.Set("moviebytesize", strconv.FormatInt(size, 10))
+	}
+	if len(languages) > 0 {
+		// The API redirects requests whose languages aren't lowercase and sorted
+		codes := strings.Split(strings.ToLower(strings.Join(languages, ",")), ",")
+		slices.Sort(codes)
+
+		query.Set("languages", strings.Join(codes, ","))
+	}
+
+	var response searchResponse
+	if err := p.do(ctx, http.MethodGet, "/subtitles?"+query.Encode(), nil, &response); err != nil {
+		return nil, err
+	}
+
+	results := []SearchResult{}
+	for _, subtitle := range response.Data {
+		for _, file := range subtitle.Attributes.Files {
+			results = append(results, SearchResult{
+				ID:       strconv.Itoa(file.FileID),
+				Name:     file.FileName,
+				Language: subtitle.Attributes.Language,
+				Release:  subtitle.Attributes.Release,
+			})
+		}
+	}
+
+	return results, nil
+}
+
+func (p *HTTPProvider) Download(ctx context.Context, result SearchResult) (io.ReadCloser, error) {
+	fileID, err := strconv.Atoi(result.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var response downloadResponse
+	if err := p.do(ctx, http.MethodPost, "/download", downloadRequest{FileID: fileID}, &response); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, response.Link, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", p.userAgent)
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		_ = res.Body.Close()
+
+		return nil, fmt.Errorf("%w: GET %v: %v", ErrProviderRequestFailed, response.Link, res.Status)
+	}
+
+	return res.Body, nil
+}
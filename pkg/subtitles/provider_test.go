@@ -0,0 +1,127 @@
+package subtitles
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestHTTPProvider(t *testing.T) {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /api/v1/subtitles", func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Api-Key"); got != "test-key" {
+			t.Errorf("Api-Key = %q, want %q", got, "test-key")
+		}
+
+		if got := r.Header.Get("User-Agent"); got != "Multiplex test" {
+			t.Errorf("User-Agent = %q, want %q", got, "Multiplex test")
+		}
+
+		query := r.URL.Query()
+		for key, want := range map[string]string{
+			"moviehash":     "8e245d9679d31e12",
+			"moviebytesize": "12909756",
+			"languages":     "de,en,pt",
+		} {
+			if got := query.Get(key); got != want {
+				t.Errorf("query %v = %q, want %q", key, got, want)
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{
+			"total_count": 2,
+			"data": [
+				{
+					"id": "1",
+					"type": "subtitle",
+					"attributes": {
+						"language": "en",
+						"release": "Movie.2020.1080p",
+						"files": [{"file_id": 123, "file_name": "Movie.2020.en.srt"}]
+					}
+				},
+				{
+					"id": "2",
+					"type": "subtitle",
+					"attributes": {
+						"language": "de",
+						"release": "Movie.2020.720p",
+						"files": [
+							{"file_id": 456, "file_name": "CD1.de.srt"},
+							{"file_id": 789, "file_name": "CD2.de.srt"}
+						]
+					}
+				}
+			]
+		}`))
+	})
+
+	mux.HandleFunc("POST /api/v1/download", func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Content-Type"); got != "application/json" {
+			t.Errorf("Content-Type = %q, want %q", got, "application/json")
+		}
+
+		var req downloadRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+
+		if req.FileID != 123 {
+			http.Error(w, "unknown file", http.StatusNotFound)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(downloadResponse{Link: "http://" + r.Host + "/files/123"})
+	})
+
+	mux.HandleFunc("GET /files/123", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("1\r\n00:00:01,000 --> 00:00:02,000\r\nHello\r\n"))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	provider := NewHTTPProvider(server.URL+"/api/v1/", "test-key", "Multiplex test", server.Client())
+
+	results, err := provider.SearchByHash(context.Background(), "8e245d9679d31e12", 12909756, []string{"pt", "EN", "de"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []SearchResult{
+		{ID: "123", Name: "Movie.2020.en.srt", Language: "en", Release: "Movie.2020.1080p"},
+		{ID: "456", Name: "CD1.de.srt", Language: "de", Release: "Movie.2020.720p"},
+		{ID: "789", Name: "CD2.de.srt", Language: "de", Release: "Movie.2020.720p"},
+	}
+	if !slices.Equal(results, want) {
+		t.Fatalf("SearchByHash() = %+v, want %+v", results, want)
+	}
+
+	content, err := provider.Download(context.Background(), results[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer content.Close()
+
+	got, err := io.ReadAll(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if DetectFormat(got) != FormatSRT {
+		t.Fatalf("Download() returned %q, want SRT subtitles", got)
+	}
+
+	if _, err := provider.Download(context.Background(), results[1]); !errors.Is(err, ErrProviderRequestFailed) {
+		t.Fatalf("Download() error = %v, want %v", err, ErrProviderRequestFailed)
+	}
+}
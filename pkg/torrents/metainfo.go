@@ -0,0 +1,107 @@
+package torrents
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+const (
+	// Torrent files only contain metadata, so larger files are rejected before they are parsed
+	MaxTorrentFileSize = 10 * 1024 * 1024
+)
+
+var (
+	ErrInvalidTorrent     = errors.New("file is not a valid torrent file")
+	ErrTorrentTooLarge    = errors.New("torrent file is too large")
+	ErrTorrentWithoutInfo = errors.New("torrent file does not contain an info dictionary")
+	ErrTorrentV2Only      = errors.New("torrent file only contains a BitTorrent v2 info hash, which is not supported")
+	ErrEmptyTrackerTier   = errors.New("torrent file contains an empty tracker tier")
+	ErrInvalidTracker     = errors.New("torrent file contains an invalid tracker URL")
+)
+
+var supportedTrackerSchemes = map[string]struct{}{
+	"http":  {},
+	"https": {},
+	"udp":   {},
+	"ws":    {},
+	"wss":   {},
+}
+
+// Torrent is the metadata of a torrent file
+type Torrent struct {
+	Name     string   // Name of the torrent from its info dictionary
+	InfoHash string   // Hex-encoded BitTorrent v1 info hash
+	Trackers []string // Trackers of all tiers, without duplicates
+	Magnet   string   // Magnet link which includes all trackers and web seeds, so that it can be shared instead of the file
+}
+
+// Load parses a torrent file and derives a magnet link from it. Trackers of all tiers of the announce list
+// are added to the magnet link, so torrents with multiple trackers can be shared without losing any of them.
+func Load(r io.Reader) (*Torrent, error) {
+	content, err := io.ReadAll(io.LimitReader(r, MaxTorrentFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(content) > MaxTorrentFileSize {
+		return nil, fmt.Errorf("%w: more than %v bytes", ErrTorrentTooLarge, MaxTorrentFileSize)
+	}
+
+	mi, err := metainfo.Load(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTorrent, err)
+	}
+
+	if len(mi.InfoBytes) == 0 {
+		return nil, ErrTorrentWithoutInfo
+	}
+
+	info, err := mi.UnmarshalInfo()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTorrent, err)
+	}
+
+	if !info.HasV1() {
+		return nil, ErrTorrentV2Only
+	}
+
+	announceList := mi.UpvertedAnnounceList()
+	for i, tier := range announceList {
+		if len(tier) == 0 {
+			return nil, fmt.Errorf("%w: tier %v", ErrEmptyTrackerTier, i+1)
+		}
+
+		for _, tracker := range tier {
+			if err := validateTracker(tracker); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	infoHash := mi.HashInfoBytes()
+
+	return &Torrent{
+		Name:     info.BestName(),
+		InfoHash: infoHash.HexString(),
+		Trackers: announceList.DistinctValues(),
+		Magnet:   mi.Magnet(&infoHash, &info).String(),
+	}, nil
+}
+
+func validateTracker(tracker string) error {
+	u, err := url.Parse(tracker)
+	if err != nil {
+		return fmt.Errorf("%w: %v: %v", ErrInvalidTracker, tracker, err)
+	}
+
+	if _, ok := supportedTrackerSchemes[u.Scheme]; !ok || u.Host == "" {
+		return fmt.Errorf("%w: %v", ErrInvalidTracker, tracker)
+	}
+
+	return nil
+}